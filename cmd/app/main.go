@@ -1,35 +1,69 @@
 package main
 
 import (
+	"context"
+	"fmt"
 	"log"
+	"net"
 	"net/http"
 	"order-service/config"
 	"order-service/internal/cache"
 	"order-service/internal/db"
+	"order-service/internal/graphqlapi"
+	"order-service/internal/grpcapi"
 	"order-service/internal/handlers"
+	"order-service/internal/ingest"
 	"order-service/internal/kafka"
+	"order-service/internal/retention"
+	"order-service/internal/stream"
+	"order-service/internal/validation"
 	"order-service/test"
 	"os"
 	"os/signal"
+	"strings"
 	"syscall"
 )
 
 func main() {
 	cfg := config.Load()
 
-	database, err := db.NewDB(cfg)
+	defaultMode, err := validation.ParseMode(cfg.ValidationRuleMode)
+	if err != nil {
+		log.Fatalf("Invalid VALIDATION_RULE_MODE: %v", err)
+	}
+	ruleModes, err := validation.ParseRuleModes(cfg.ValidationRules)
+	if err != nil {
+		log.Fatalf("Invalid VALIDATION_RULES: %v", err)
+	}
+	validation.SetRuleEngine(validation.NewRuleEngine(validation.Rules, ruleModes, defaultMode))
+	validation.SetAllowedLocales(cfg.ValidationLocales)
+	if err := validation.SetPhoneRegion(cfg.ValidationPhoneRegion); err != nil {
+		log.Fatalf("Invalid VALIDATION_PHONE_REGION: %v", err)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	if cfg.ValidationProfilesFile != "" {
+		err := validation.WatchProfiles(ctx, cfg.ValidationProfilesFile, cfg.ValidationProfilesReload)
+		if err != nil {
+			log.Fatalf("Failed to load validation profiles: %v", err)
+		}
+	}
+
+	store, databases, err := openStore(cfg)
 	if err != nil {
 		log.Fatalf("Failed to initialize database: %v", err)
 	}
-	defer database.Close()
+	defer store.Close()
 
-	exists, err := database.OrderExists("b563feb7b2b84b6test")
+	exists, err := store.OrderExistsContext(ctx, "b563feb7b2b84b6test")
 	if err != nil {
 		log.Fatal(err)
 	}
 
 	if !exists {
-		if err := database.LoadTestData(); err != nil {
+		if err := db.LoadTestData(store); err != nil {
 			log.Fatal(err)
 		}
 		log.Println("Test data loaded successfully")
@@ -38,7 +72,7 @@ func main() {
 	}
 
 	c := cache.NewCache()
-	orders, err := database.GetAllOrders()
+	orders, err := store.GetAllOrdersContext(ctx)
 	if err != nil {
 		log.Printf("Failed to restore cache from DB: %v", err)
 	} else {
@@ -46,12 +80,48 @@ func main() {
 		log.Printf("Restored %d orders to cache", len(orders))
 	}
 
-	consumer := kafka.NewConsumer(cfg, database, c)
+	hub := stream.NewHub(cfg.StreamReplaySize, cfg.StreamClientBuffer)
+	pipeline := ingest.NewPipeline(cfg)
+	consumer := kafka.NewConsumer(cfg, pipeline, store, c, hub)
 	consumer.Start()
 	defer consumer.Close()
 
-	orderHandler := handlers.NewOrderHandler(c, database)
+	eventConsumer := kafka.NewEventConsumer(cfg, store, c, hub)
+	eventConsumer.Start()
+	defer eventConsumer.Close()
+
+	for _, database := range databases {
+		relay := kafka.NewOutboxRelay(cfg, database)
+		relay.Start()
+		defer relay.Close()
+
+		retentionJob := retention.NewJob(cfg, database, c)
+		retentionJob.Start()
+		defer retentionJob.Close()
+	}
+
+	orderHandler := handlers.NewOrderHandler(c, store, pipeline, hub, cfg.BatchMaxSize)
+	idempotency := handlers.NewIdempotency(cfg.IdempotencyTTL)
 	http.HandleFunc("/order/", orderHandler.GetOrder)
+	http.HandleFunc("DELETE /order/{uid}", orderHandler.DeleteOrder)
+	http.HandleFunc("POST /orders", idempotency.Wrap(orderHandler.CreateOrder))
+	http.HandleFunc("PUT /orders/{uid}", idempotency.Wrap(orderHandler.PutOrder))
+	http.HandleFunc("POST /orders/batch", orderHandler.BatchGetOrders)
+	http.HandleFunc("GET /customers/{id}/orders", orderHandler.GetCustomerOrders)
+	http.HandleFunc("PATCH /orders/{uid}/status", orderHandler.UpdateOrderStatus)
+	http.HandleFunc("GET /orders/{uid}/status", orderHandler.GetOrderStatus)
+	http.Handle("GET /orders/stream", handlers.NewStreamHandler(hub, cfg.StreamHeartbeat, cfg.StreamWriteTimeout))
+	http.Handle("GET /orders/ws", handlers.NewWatchHandler(hub, c, store, cfg.WSMaxSubscriptions, cfg.WSPingInterval))
+	graphqlHandler, err := graphqlapi.NewHandler(c, store, graphqlapi.Limits{
+		MaxDepth:      cfg.GraphQLMaxDepth,
+		MaxComplexity: cfg.GraphQLMaxComplexity,
+		MaxPageSize:   cfg.GraphQLMaxPageSize,
+	})
+	if err != nil {
+		log.Fatalf("Failed to build GraphQL schema: %v", err)
+	}
+	http.Handle("/graphql", graphqlHandler)
+	http.HandleFunc("GET /schema/order.json", handlers.SchemaHandler)
 	http.HandleFunc("/", handlers.StaticHandler)
 
 	go func() {
@@ -61,6 +131,19 @@ func main() {
 		}
 	}()
 
+	grpcServer := grpcapi.NewServer(c, store, hub, cfg.BatchMaxSize)
+	go func() {
+		lis, err := net.Listen("tcp", ":"+cfg.GRPCPort)
+		if err != nil {
+			log.Fatalf("gRPC server failed: %v", err)
+		}
+		log.Printf("Starting gRPC server on :%s", cfg.GRPCPort)
+		if err := grpcServer.Serve(lis); err != nil {
+			log.Fatalf("gRPC server failed: %v", err)
+		}
+	}()
+	defer grpcServer.GracefulStop()
+
 	test.Test()
 
 	sigCh := make(chan os.Signal, 1)
@@ -68,3 +151,37 @@ func main() {
 	<-sigCh
 	log.Println("Shutting down...")
 }
+
+// openStore connects to the single database, or to every shard when
+// DB_SHARDS is set. Shards are read from their primaries: DB_REPLICAS lists
+// replicas of the single database and is ignored in sharded mode.
+func openStore(cfg *config.Config) (db.Store, []*db.Database, error) {
+	if len(cfg.DBShards) == 0 {
+		database, err := db.NewDB(cfg)
+		if err != nil {
+			return nil, nil, err
+		}
+		return database, []*db.Database{database}, nil
+	}
+	if len(cfg.DBReplicas) > 0 {
+		log.Printf("DB_REPLICAS is ignored when DB_SHARDS is set; shards are read from their primaries")
+	}
+
+	shards := make([]db.Store, 0, len(cfg.DBShards))
+	var databases []*db.Database
+	for i, dsn := range cfg.DBShards {
+		if strings.HasPrefix(dsn, "memory://") {
+			shards = append(shards, db.NewMemoryStore())
+			continue
+		}
+		database, err := db.Open(cfg, dsn)
+		if err != nil {
+			db.NewRouter(cfg.DBShardCacheSize, shards...).Close()
+			return nil, nil, fmt.Errorf("shard %d: %w", i, err)
+		}
+		shards = append(shards, database)
+		databases = append(databases, database)
+	}
+	log.Printf("Routing orders across %d shards", len(shards))
+	return db.NewRouter(cfg.DBShardCacheSize, shards...), databases, nil
+}
@@ -0,0 +1,73 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"log"
+	"order-service/config"
+	"order-service/internal/db"
+	"sort"
+	"time"
+)
+
+// order-bench compares the latency of the single-statement order lookup with
+// the previous five round-trip implementation against the configured database.
+func main() {
+	uid := flag.String("uid", "b563feb7b2b84b6test", "order uid to load")
+	iterations := flag.Int("n", 1000, "number of lookups per implementation")
+	flag.Parse()
+
+	cfg := config.Load()
+	cfg.DBReplicas = nil
+
+	database, err := db.NewDB(cfg)
+	if err != nil {
+		log.Fatalf("Failed to initialize database: %v", err)
+	}
+	defer database.Close()
+
+	if _, err := database.GetOrderByUID(*uid); err != nil {
+		log.Fatalf("Failed to load order %s: %v", *uid, err)
+	}
+
+	single := measure(*iterations, func() error {
+		_, err := database.GetOrderByUID(*uid)
+		return err
+	})
+	legacy := measure(*iterations, func() error {
+		_, err := database.LegacyGetOrderByUIDContext(context.Background(), *uid)
+		return err
+	})
+
+	report("single query", single)
+	report("five queries", legacy)
+	fmt.Printf("speedup (mean): %.2fx\n", float64(mean(legacy))/float64(mean(single)))
+}
+
+func measure(n int, fn func() error) []time.Duration {
+	samples := make([]time.Duration, 0, n)
+	for i := 0; i < n; i++ {
+		start := time.Now()
+		if err := fn(); err != nil {
+			log.Fatalf("Lookup failed: %v", err)
+		}
+		samples = append(samples, time.Since(start))
+	}
+	sort.Slice(samples, func(i, j int) bool { return samples[i] < samples[j] })
+	return samples
+}
+
+func mean(samples []time.Duration) time.Duration {
+	var total time.Duration
+	for _, s := range samples {
+		total += s
+	}
+	return total / time.Duration(len(samples))
+}
+
+func report(name string, samples []time.Duration) {
+	p := func(q float64) time.Duration { return samples[int(q*float64(len(samples)-1))] }
+	fmt.Printf("%-13s mean=%-10v p50=%-10v p95=%-10v p99=%v\n",
+		name, mean(samples), p(0.50), p(0.95), p(0.99))
+}
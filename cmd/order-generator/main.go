@@ -92,7 +92,7 @@ func (g *OrderGenerator) GenerateOrder() Order {
 
 	delivery := Delivery{
 		Name:    gofakeit.Name(),
-		Phone:   gofakeit.Phone(),
+		Phone:   "+1" + gofakeit.Phone(),
 		Zip:     gofakeit.Zip(),
 		City:    gofakeit.City(),
 		Address: gofakeit.Street() + ", " + gofakeit.Digit(),
@@ -100,31 +100,14 @@ func (g *OrderGenerator) GenerateOrder() Order {
 		Email:   gofakeit.Email(),
 	}
 
-	amount := gofakeit.Number(10000, 500000)
-	deliveryCost := gofakeit.Number(1000, 10000)
-	goodsTotal := amount - deliveryCost
-	if goodsTotal < 0 {
-		goodsTotal = amount - 5000
-	}
-
-	payment := Payment{
-		Transaction:  orderUID,
-		RequestID:    gofakeit.UUID(),
-		Currency:     "USD",
-		Provider:     "wbpay",
-		Amount:       amount,
-		PaymentDt:    time.Now().Unix(),
-		Bank:         "alpha",
-		DeliveryCost: deliveryCost,
-		GoodsTotal:   goodsTotal,
-		CustomFee:    gofakeit.Number(0, 2000),
-	}
-
 	itemCount := rand.Intn(3) + 1
 	items := make([]Item, itemCount)
+	goodsTotal := 0
 	for i := 0; i < itemCount; i++ {
 		price := gofakeit.Number(1000, 50000)
-		sale := gofakeit.Number(0, 5000)
+		sale := gofakeit.Number(0, 50)
+		totalPrice := price * (100 - sale) / 100
+		goodsTotal += totalPrice
 
 		items[i] = Item{
 			ChrtID:      gofakeit.Number(1000000, 9999999),
@@ -134,13 +117,29 @@ func (g *OrderGenerator) GenerateOrder() Order {
 			Name:        gofakeit.ProductName(),
 			Sale:        sale,
 			Size:        strconv.Itoa(gofakeit.Number(1, 10)),
-			TotalPrice:  price - sale,
+			TotalPrice:  totalPrice,
 			NmID:        gofakeit.Number(100000, 999999),
 			Brand:       gofakeit.Company(),
 			Status:      gofakeit.Number(100, 400),
 		}
 	}
 
+	deliveryCost := gofakeit.Number(1000, 10000)
+	customFee := gofakeit.Number(0, 2000)
+
+	payment := Payment{
+		Transaction:  orderUID,
+		RequestID:    gofakeit.UUID(),
+		Currency:     "USD",
+		Provider:     "wbpay",
+		Amount:       goodsTotal + deliveryCost + customFee,
+		PaymentDt:    time.Now().Unix(),
+		Bank:         "alpha",
+		DeliveryCost: deliveryCost,
+		GoodsTotal:   goodsTotal,
+		CustomFee:    customFee,
+	}
+
 	return Order{
 		OrderUID:          orderUID,
 		TrackNumber:       trackNumber,
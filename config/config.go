@@ -1,31 +1,180 @@
 package config
 
 import (
+	"log"
 	"os"
+	"strconv"
 	"strings"
+	"time"
 )
 
 type Config struct {
-	DBHost       string
-	DBPort       string
-	DBUser       string
-	DBPassword   string
-	DBName       string
-	KafkaBrokers []string
-	KafkaTopic   string
-	HTTPPort     string
+	DBHost     string
+	DBPort     string
+	DBUser     string
+	DBPassword string
+	DBName     string
+	DBShards   []string
+	DBURL      string
+
+	DBShardCacheSize int
+
+	DBSSLMode     string
+	DBSSLRootCert string
+	DBSSLCert     string
+	DBSSLKey      string
+
+	DBMaxOpenConns     int
+	DBMaxIdleConns     int
+	DBConnMaxLifetime  time.Duration
+	DBConnMaxIdleTime  time.Duration
+	DBStatementTimeout time.Duration
+	DBConnectAttempts  int
+	DBConnectBackoff   time.Duration
+
+	DBReplicas             []string
+	DBReplicaCheckInterval time.Duration
+	DBReadYourWritesWindow time.Duration
+
+	DBSaveTimeout   time.Duration
+	DBGetTimeout    time.Duration
+	DBListTimeout   time.Duration
+	DBExistsTimeout time.Duration
+	DBDeleteTimeout time.Duration
+
+	KafkaBrokers          []string
+	KafkaTopic            string
+	KafkaDLQTopic         string
+	KafkaSchemaValidation bool
+	KafkaStrictDecoding   bool
+	KafkaSchemaVersion    int
+
+	KafkaEventsTopic           string
+	KafkaEventsRetryBackoff    time.Duration
+	KafkaEventsNotFoundRetries int
+	HTTPPort                   string
+	GRPCPort                   string
+	IdempotencyTTL             time.Duration
+	BatchMaxSize               int
+
+	StreamReplaySize   int
+	StreamClientBuffer int
+	StreamHeartbeat    time.Duration
+	StreamWriteTimeout time.Duration
+
+	WSMaxSubscriptions int
+	WSPingInterval     time.Duration
+
+	GraphQLMaxDepth      int
+	GraphQLMaxComplexity int
+	GraphQLMaxPageSize   int
+
+	SchemaRegistryURL     string
+	SchemaRegistryTimeout time.Duration
+
+	OutboxTopic        string
+	OutboxPollInterval time.Duration
+	OutboxBatchSize    int
+	OutboxRetention    time.Duration
+
+	RetentionMaxAge     time.Duration
+	RetentionInterval   time.Duration
+	RetentionBatchSize  int
+	RetentionMode       string
+	RetentionArchiveDir string
+
+	ValidationRuleMode    string
+	ValidationRules       string
+	ValidationLocales     []string
+	ValidationPhoneRegion string
+
+	ValidationProfilesFile   string
+	ValidationProfilesReload time.Duration
 }
 
 func Load() *Config {
 	return &Config{
-		DBHost:       getEnv("DB_HOST", "localhost"),
-		DBPort:       getEnv("DB_PORT", "5432"),
-		DBUser:       getEnv("DB_USER", "user"),
-		DBPassword:   getEnv("DB_PASSWORD", "password"),
-		DBName:       getEnv("DB_NAME", "orders_db"),
-		KafkaBrokers: strings.Split(getEnv("KAFKA_BROKERS", "redpanda:9092"), ","),
-		KafkaTopic:   getEnv("KAFKA_TOPIC", "orders"),
-		HTTPPort:     getEnv("HTTP_PORT", "8080"),
+		DBHost:     getEnv("DB_HOST", "localhost"),
+		DBPort:     getEnv("DB_PORT", "5432"),
+		DBUser:     getEnv("DB_USER", "user"),
+		DBPassword: getEnv("DB_PASSWORD", "password"),
+		DBName:     getEnv("DB_NAME", "orders_db"),
+		DBShards:   getEnvList("DB_SHARDS"),
+		DBURL:      getEnv("DB_URL", os.Getenv("DATABASE_URL")),
+
+		DBShardCacheSize: getEnvInt("DB_SHARD_CACHE_SIZE", 100000),
+
+		DBSSLMode:     getEnv("DB_SSLMODE", ""),
+		DBSSLRootCert: getEnv("DB_SSLROOTCERT", ""),
+		DBSSLCert:     getEnv("DB_SSLCERT", ""),
+		DBSSLKey:      getEnv("DB_SSLKEY", ""),
+
+		DBMaxOpenConns:     getEnvInt("DB_MAX_OPEN_CONNS", 25),
+		DBMaxIdleConns:     getEnvInt("DB_MAX_IDLE_CONNS", 25),
+		DBConnMaxLifetime:  getEnvDuration("DB_CONN_MAX_LIFETIME", 5*time.Minute),
+		DBConnMaxIdleTime:  getEnvDuration("DB_CONN_MAX_IDLE_TIME", 0),
+		DBStatementTimeout: getEnvDuration("DB_STATEMENT_TIMEOUT", 0),
+		DBConnectAttempts:  getEnvInt("DB_CONNECT_ATTEMPTS", 10),
+		DBConnectBackoff:   getEnvDuration("DB_CONNECT_BACKOFF", time.Second),
+
+		DBReplicas:             getEnvList("DB_REPLICAS"),
+		DBReplicaCheckInterval: getEnvDuration("DB_REPLICA_CHECK_INTERVAL", 5*time.Second),
+		DBReadYourWritesWindow: getEnvDuration("DB_READ_YOUR_WRITES_WINDOW", 10*time.Second),
+
+		DBSaveTimeout:   getEnvDuration("DB_SAVE_TIMEOUT", 5*time.Second),
+		DBGetTimeout:    getEnvDuration("DB_GET_TIMEOUT", 5*time.Second),
+		DBListTimeout:   getEnvDuration("DB_LIST_TIMEOUT", 10*time.Second),
+		DBExistsTimeout: getEnvDuration("DB_EXISTS_TIMEOUT", 5*time.Second),
+		DBDeleteTimeout: getEnvDuration("DB_DELETE_TIMEOUT", 5*time.Second),
+
+		KafkaBrokers:          strings.Split(getEnv("KAFKA_BROKERS", "redpanda:9092"), ","),
+		KafkaTopic:            getEnv("KAFKA_TOPIC", "orders"),
+		KafkaDLQTopic:         getEnv("KAFKA_DLQ_TOPIC", "orders-dlq"),
+		KafkaSchemaValidation: getEnvBool("KAFKA_SCHEMA_VALIDATION", false),
+		KafkaStrictDecoding:   getEnvBool("KAFKA_STRICT_DECODING", false),
+		KafkaSchemaVersion:    getEnvInt("KAFKA_SCHEMA_VERSION", 2),
+
+		KafkaEventsTopic:           getEnv("KAFKA_EVENTS_TOPIC", "order-events"),
+		KafkaEventsRetryBackoff:    getEnvDuration("KAFKA_EVENTS_RETRY_BACKOFF", time.Second),
+		KafkaEventsNotFoundRetries: getEnvInt("KAFKA_EVENTS_NOT_FOUND_RETRIES", 5),
+		HTTPPort:                   getEnv("HTTP_PORT", "8080"),
+		GRPCPort:                   getEnv("GRPC_PORT", "9090"),
+		IdempotencyTTL:             getEnvDuration("IDEMPOTENCY_TTL", 24*time.Hour),
+		BatchMaxSize:               getEnvInt("BATCH_MAX_SIZE", 500),
+
+		StreamReplaySize:   getEnvInt("STREAM_REPLAY_SIZE", 1000),
+		StreamClientBuffer: getEnvInt("STREAM_CLIENT_BUFFER", 64),
+		StreamHeartbeat:    getEnvDuration("STREAM_HEARTBEAT", 15*time.Second),
+		StreamWriteTimeout: getEnvDuration("STREAM_WRITE_TIMEOUT", 10*time.Second),
+
+		WSMaxSubscriptions: getEnvInt("WS_MAX_SUBSCRIPTIONS", 100),
+		WSPingInterval:     getEnvDuration("WS_PING_INTERVAL", 30*time.Second),
+
+		GraphQLMaxDepth:      getEnvInt("GRAPHQL_MAX_DEPTH", 8),
+		GraphQLMaxComplexity: getEnvInt("GRAPHQL_MAX_COMPLEXITY", 5000),
+		GraphQLMaxPageSize:   getEnvInt("GRAPHQL_MAX_PAGE_SIZE", 100),
+
+		SchemaRegistryURL:     getEnv("SCHEMA_REGISTRY_URL", ""),
+		SchemaRegistryTimeout: getEnvDuration("SCHEMA_REGISTRY_TIMEOUT", 5*time.Second),
+
+		OutboxTopic:        getEnv("OUTBOX_TOPIC", "order-notifications"),
+		OutboxPollInterval: getEnvDuration("OUTBOX_POLL_INTERVAL", time.Second),
+		OutboxBatchSize:    getEnvInt("OUTBOX_BATCH_SIZE", 100),
+		OutboxRetention:    getEnvDuration("OUTBOX_RETENTION", 24*time.Hour),
+
+		RetentionMaxAge:     getEnvDuration("RETENTION_MAX_AGE", 0),
+		RetentionInterval:   getEnvDuration("RETENTION_INTERVAL", time.Hour),
+		RetentionBatchSize:  getEnvInt("RETENTION_BATCH_SIZE", 500),
+		RetentionMode:       getEnv("RETENTION_MODE", "table"),
+		RetentionArchiveDir: getEnv("RETENTION_ARCHIVE_DIR", "archive"),
+
+		ValidationRuleMode:    getEnv("VALIDATION_RULE_MODE", "strict"),
+		ValidationRules:       getEnv("VALIDATION_RULES", ""),
+		ValidationLocales:     strings.Split(getEnv("VALIDATION_LOCALES", "en,ru,pt"), ","),
+		ValidationPhoneRegion: getEnv("VALIDATION_PHONE_REGION", ""),
+
+		ValidationProfilesFile:   getEnv("VALIDATION_PROFILES_FILE", ""),
+		ValidationProfilesReload: getEnvDuration("VALIDATION_PROFILES_RELOAD", 10*time.Second),
 	}
 }
 
@@ -35,3 +184,52 @@ func getEnv(key, defaultValue string) string {
 	}
 	return defaultValue
 }
+
+func getEnvList(key string) []string {
+	var list []string
+	for _, v := range strings.Split(os.Getenv(key), ",") {
+		if v = strings.TrimSpace(v); v != "" {
+			list = append(list, v)
+		}
+	}
+	return list
+}
+
+func getEnvBool(key string, defaultValue bool) bool {
+	value, exists := os.LookupEnv(key)
+	if !exists {
+		return defaultValue
+	}
+	b, err := strconv.ParseBool(value)
+	if err != nil {
+		log.Printf("Invalid %s=%q, using default %t: %v", key, value, defaultValue, err)
+		return defaultValue
+	}
+	return b
+}
+
+func getEnvInt(key string, defaultValue int) int {
+	value, exists := os.LookupEnv(key)
+	if !exists {
+		return defaultValue
+	}
+	n, err := strconv.Atoi(value)
+	if err != nil {
+		log.Printf("Invalid %s=%q, using default %d: %v", key, value, defaultValue, err)
+		return defaultValue
+	}
+	return n
+}
+
+func getEnvDuration(key string, defaultValue time.Duration) time.Duration {
+	value, exists := os.LookupEnv(key)
+	if !exists {
+		return defaultValue
+	}
+	d, err := time.ParseDuration(value)
+	if err != nil {
+		log.Printf("Invalid %s=%q, using default %v: %v", key, value, defaultValue, err)
+		return defaultValue
+	}
+	return d
+}
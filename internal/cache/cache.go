@@ -44,6 +44,16 @@ func (c *Cache) Get(uid string) (*db.Order, bool) {
 	return order, exists
 }
 
+func (c *Cache) Delete(uid string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if _, exists := c.items[uid]; exists {
+		delete(c.items, uid)
+		c.removeFromSlice(uid)
+	}
+}
+
 func (c *Cache) Restore(orders []db.Order) {
 	c.mu.Lock()
 	defer c.mu.Unlock()
@@ -0,0 +1,356 @@
+package codec
+
+import (
+	"context"
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"order-service/internal/db"
+	"reflect"
+	"strings"
+	"time"
+
+	"github.com/linkedin/goavro/v2"
+)
+
+// Avro decodes orders written with the Confluent wire format: a zero magic
+// byte, the big-endian schema ID and the Avro binary body. The writer schema
+// is fetched from the registry and must describe the order model.
+type Avro struct {
+	Registry *Registry
+	Strict   bool
+	// SchemaID is the writer schema used by Encode.
+	SchemaID uint32
+}
+
+const confluentHeaderSize = 5
+
+// IsConfluentFramed reports whether data starts with the Confluent magic
+// byte. JSON payloads never do, so it is safe to sniff unlabeled messages.
+func IsConfluentFramed(data []byte) bool {
+	return len(data) >= confluentHeaderSize && data[0] == 0
+}
+
+func (Avro) ContentType() string {
+	return ContentTypeAvro
+}
+
+func (c Avro) Decode(ctx context.Context, data []byte) (*db.Order, error) {
+	if !IsConfluentFramed(data) {
+		return nil, errors.New("avro message is missing the Confluent header")
+	}
+	id := binary.BigEndian.Uint32(data[1:confluentHeaderSize])
+	s, err := c.Registry.schema(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+
+	native, _, err := s.codec.NativeFromBinary(data[confluentHeaderSize:])
+	if err != nil {
+		return nil, fmt.Errorf("failed to decode avro order with schema %d: %w", id, err)
+	}
+	doc, err := json.Marshal(s.toPlain(s.root, native))
+	if err != nil {
+		return nil, err
+	}
+	return DecodeJSON(doc, c.Strict)
+}
+
+func (c Avro) Encode(ctx context.Context, order *db.Order) ([]byte, error) {
+	s, err := c.Registry.schema(ctx, c.SchemaID)
+	if err != nil {
+		return nil, err
+	}
+
+	data, err := json.Marshal(order)
+	if err != nil {
+		return nil, err
+	}
+	var doc any
+	if err := json.Unmarshal(data, &doc); err != nil {
+		return nil, err
+	}
+	native, err := s.toNative(s.root, doc)
+	if err != nil {
+		return nil, err
+	}
+
+	buf := make([]byte, confluentHeaderSize)
+	binary.BigEndian.PutUint32(buf[1:], c.SchemaID)
+	return s.codec.BinaryFromNative(buf, native)
+}
+
+func parseAvroSchema(spec string) (*avroSchema, error) {
+	codec, err := goavro.NewCodec(spec)
+	if err != nil {
+		return nil, fmt.Errorf("invalid avro schema: %w", err)
+	}
+
+	s := &avroSchema{codec: codec, names: make(map[string]map[string]any)}
+	if err := json.Unmarshal([]byte(spec), &s.root); err != nil {
+		return nil, err
+	}
+	s.collectNames(s.root, "")
+
+	if problems := s.check(s.root, reflect.TypeOf(db.Order{}), ""); len(problems) > 0 {
+		return nil, fmt.Errorf("schema does not match the order model: %s", strings.Join(problems, "; "))
+	}
+	return s, nil
+}
+
+// collectNames indexes named records by short and full name so that fields
+// referring to an earlier record by name can be resolved.
+func (s *avroSchema) collectNames(node any, namespace string) {
+	switch n := node.(type) {
+	case []any:
+		for _, branch := range n {
+			s.collectNames(branch, namespace)
+		}
+	case map[string]any:
+		switch n["type"] {
+		case "record":
+			name, _ := n["name"].(string)
+			if ns, ok := n["namespace"].(string); ok {
+				namespace = ns
+			}
+			full := name
+			if namespace != "" && !strings.Contains(name, ".") {
+				full = namespace + "." + name
+			}
+			n["fullname"] = full
+			s.names[name] = n
+			s.names[full] = n
+			fields, _ := n["fields"].([]any)
+			for _, f := range fields {
+				if fm, ok := f.(map[string]any); ok {
+					s.collectNames(fm["type"], namespace)
+				}
+			}
+		case "array":
+			s.collectNames(n["items"], namespace)
+		default:
+			if inner, ok := n["type"].(map[string]any); ok {
+				s.collectNames(inner, namespace)
+			}
+		}
+	}
+}
+
+// resolve replaces a reference to a named record with its definition and
+// unwraps {"type": "string"}-style primitives.
+func (s *avroSchema) resolve(node any) any {
+	switch n := node.(type) {
+	case string:
+		if def, ok := s.names[n]; ok {
+			return def
+		}
+	case map[string]any:
+		if t, ok := n["type"].(string); ok && n["logicalType"] == nil {
+			switch t {
+			case "record", "array", "enum", "map", "fixed":
+			default:
+				return s.resolve(t)
+			}
+		}
+	}
+	return node
+}
+
+func (s *avroSchema) typeName(node any) string {
+	switch n := s.resolve(node).(type) {
+	case string:
+		return n
+	case map[string]any:
+		if full, ok := n["fullname"].(string); ok {
+			return full
+		}
+		if t, ok := n["type"].(string); ok {
+			return t
+		}
+	}
+	return ""
+}
+
+func (s *avroSchema) check(node any, t reflect.Type, path string) []string {
+	node = s.resolve(node)
+	if union, ok := node.([]any); ok {
+		var branches []any
+		for _, b := range union {
+			if b != "null" {
+				branches = append(branches, b)
+			}
+		}
+		if len(branches) != 1 {
+			return []string{fmt.Sprintf("%s: unions must be a single type or nullable", path)}
+		}
+		return s.check(branches[0], t, path)
+	}
+
+	avroType := s.typeName(node)
+	if m, ok := node.(map[string]any); ok {
+		if logical, ok := m["logicalType"].(string); ok {
+			avroType = logical
+		}
+	}
+
+	switch {
+	case t == timeType:
+		switch avroType {
+		case "string", "timestamp-millis", "timestamp-micros":
+			return nil
+		}
+	case t.Kind() == reflect.Struct:
+		if record, ok := node.(map[string]any); ok && record["type"] == "record" {
+			return s.checkRecord(record, t, path)
+		}
+	case t.Kind() == reflect.Slice:
+		if arr, ok := node.(map[string]any); ok && arr["type"] == "array" {
+			return s.check(arr["items"], t.Elem(), path+"[]")
+		}
+	case t.Kind() == reflect.String:
+		if avroType == "string" || avroType == "enum" {
+			return nil
+		}
+	case t.Kind() >= reflect.Int && t.Kind() <= reflect.Int64:
+		if avroType == "int" || avroType == "long" {
+			return nil
+		}
+	}
+	return []string{fmt.Sprintf("%s: avro type %s does not fit %s", path, avroType, t)}
+}
+
+func (s *avroSchema) checkRecord(record map[string]any, t reflect.Type, path string) []string {
+	fields := make(map[string]any)
+	list, _ := record["fields"].([]any)
+	for _, f := range list {
+		if fm, ok := f.(map[string]any); ok {
+			name, _ := fm["name"].(string)
+			fields[name] = fm["type"]
+		}
+	}
+
+	var problems []string
+	for i := 0; i < t.NumField(); i++ {
+		f := t.Field(i)
+		name := strings.SplitN(f.Tag.Get("json"), ",", 2)[0]
+		if name == "" || name == "-" || !f.IsExported() {
+			continue
+		}
+		p := joinPath(path, name)
+		fieldType, ok := fields[name]
+		if !ok {
+			if strings.Contains(f.Tag.Get("validate"), "required") {
+				problems = append(problems, p+": missing")
+			}
+			continue
+		}
+		delete(fields, name)
+		problems = append(problems, s.check(fieldType, f.Type, p)...)
+	}
+	for name := range fields {
+		problems = append(problems, joinPath(path, name)+": unknown field")
+	}
+	return problems
+}
+
+// toPlain turns goavro's native form into the JSON shape of the order:
+// union wrappers are dropped, null fields are left out as if absent and
+// timestamps stay time.Time.
+func (s *avroSchema) toPlain(node any, value any) any {
+	node = s.resolve(node)
+	switch n := node.(type) {
+	case []any:
+		wrapped, ok := value.(map[string]any)
+		if !ok || len(wrapped) != 1 {
+			return value
+		}
+		for name, inner := range wrapped {
+			for _, branch := range n {
+				if s.typeName(branch) == name {
+					return s.toPlain(branch, inner)
+				}
+			}
+			return inner
+		}
+	case map[string]any:
+		switch n["type"] {
+		case "record":
+			in, _ := value.(map[string]any)
+			out := make(map[string]any, len(in))
+			fields, _ := n["fields"].([]any)
+			for _, f := range fields {
+				fm, _ := f.(map[string]any)
+				name, _ := fm["name"].(string)
+				if v := s.toPlain(fm["type"], in[name]); v != nil {
+					out[name] = v
+				}
+			}
+			return out
+		case "array":
+			in, _ := value.([]any)
+			out := make([]any, len(in))
+			for i, elem := range in {
+				out[i] = s.toPlain(n["items"], elem)
+			}
+			return out
+		}
+	}
+	return value
+}
+
+// toNative is the inverse of toPlain for a document produced by encoding an
+// order as JSON.
+func (s *avroSchema) toNative(node any, value any) (any, error) {
+	node = s.resolve(node)
+	switch n := node.(type) {
+	case []any:
+		if value == nil {
+			return nil, nil
+		}
+		for _, branch := range n {
+			if branch == "null" {
+				continue
+			}
+			native, err := s.toNative(branch, value)
+			if err != nil {
+				return nil, err
+			}
+			return goavro.Union(s.typeName(branch), native), nil
+		}
+		return nil, errors.New("union has no non-null branch")
+	case map[string]any:
+		switch n["type"] {
+		case "record":
+			in, _ := value.(map[string]any)
+			out := make(map[string]any, len(in))
+			fields, _ := n["fields"].([]any)
+			for _, f := range fields {
+				fm, _ := f.(map[string]any)
+				name, _ := fm["name"].(string)
+				native, err := s.toNative(fm["type"], in[name])
+				if err != nil {
+					return nil, fmt.Errorf("%s: %w", name, err)
+				}
+				out[name] = native
+			}
+			return out, nil
+		case "array":
+			in, _ := value.([]any)
+			out := make([]any, len(in))
+			for i, elem := range in {
+				native, err := s.toNative(n["items"], elem)
+				if err != nil {
+					return nil, err
+				}
+				out[i] = native
+			}
+			return out, nil
+		}
+		if logical, _ := n["logicalType"].(string); strings.HasPrefix(logical, "timestamp-") {
+			str, _ := value.(string)
+			return time.Parse(time.RFC3339Nano, str)
+		}
+	}
+	return value, nil
+}
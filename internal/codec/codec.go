@@ -0,0 +1,83 @@
+package codec
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"mime"
+	"order-service/internal/db"
+)
+
+const (
+	ContentTypeJSON     = "application/json"
+	ContentTypeProtobuf = "application/x-protobuf"
+	ContentTypeAvro     = "application/vnd.confluent.avro"
+)
+
+// HeaderContentType is the Kafka message header that names the encoding of
+// the value; messages without it are JSON.
+const HeaderContentType = "content-type"
+
+// Codec converts orders to and from one wire format. ctx bounds any lookup
+// the codec needs, such as fetching a schema from the registry.
+type Codec interface {
+	ContentType() string
+	Encode(ctx context.Context, order *db.Order) ([]byte, error)
+	Decode(ctx context.Context, data []byte) (*db.Order, error)
+}
+
+type JSON struct {
+	Strict bool
+}
+
+func (JSON) ContentType() string {
+	return ContentTypeJSON
+}
+
+func (JSON) Encode(ctx context.Context, order *db.Order) ([]byte, error) {
+	return json.Marshal(order)
+}
+
+func (c JSON) Decode(ctx context.Context, data []byte) (*db.Order, error) {
+	return DecodeJSON(data, c.Strict)
+}
+
+// Options configures the codecs ForContentType returns.
+type Options struct {
+	Strict bool
+	// Registry is required to decode Avro; without it Avro messages are
+	// rejected.
+	Registry *Registry
+}
+
+// ForContentType picks the codec for a message. Without a content type,
+// Confluent-framed data is Avro and anything else is JSON.
+func ForContentType(contentType string, data []byte, opts Options) (Codec, error) {
+	if contentType == "" {
+		if IsConfluentFramed(data) {
+			return avroCodec(opts)
+		}
+		return JSON{Strict: opts.Strict}, nil
+	}
+	mediaType, _, err := mime.ParseMediaType(contentType)
+	if err != nil {
+		return nil, fmt.Errorf("invalid content type %q: %w", contentType, err)
+	}
+	switch mediaType {
+	case ContentTypeJSON:
+		return JSON{Strict: opts.Strict}, nil
+	case ContentTypeProtobuf, "application/protobuf", "application/vnd.google.protobuf":
+		return Protobuf{}, nil
+	case ContentTypeAvro, "application/avro", "avro/binary":
+		return avroCodec(opts)
+	}
+	return nil, fmt.Errorf("unsupported content type %q", contentType)
+}
+
+func avroCodec(opts Options) (Codec, error) {
+	if opts.Registry == nil {
+		return nil, errors.New("avro message received but no schema registry is configured")
+	}
+	return Avro{Registry: opts.Registry, Strict: opts.Strict}, nil
+}
@@ -0,0 +1,108 @@
+package codec
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"order-service/internal/db"
+	"order-service/internal/validation"
+	"reflect"
+	"sort"
+	"strings"
+	"time"
+)
+
+var timeType = reflect.TypeOf(time.Time{})
+
+// DecodeJSON decodes an order message. In strict mode every field of the
+// model except omitempty ones has to be present and non-null, so an explicit
+// 0 can be told apart from a missing value, and unknown fields are rejected;
+// the violations are returned as a *validation.ValidationError.
+func DecodeJSON(data []byte, strict bool) (*db.Order, error) {
+	if strict {
+		dec := json.NewDecoder(bytes.NewReader(data))
+		dec.UseNumber()
+		var doc any
+		if err := dec.Decode(&doc); err != nil {
+			return nil, err
+		}
+		if violations := checkFields(doc, reflect.TypeOf(db.Order{}), ""); len(violations) > 0 {
+			return nil, &validation.ValidationError{Violations: violations}
+		}
+	}
+
+	var order db.Order
+	if err := json.Unmarshal(data, &order); err != nil {
+		return nil, err
+	}
+	return &order, nil
+}
+
+func checkFields(doc any, t reflect.Type, path string) []validation.Violation {
+	switch {
+	case t == timeType:
+		return nil
+	case t.Kind() == reflect.Slice:
+		list, ok := doc.([]any)
+		if !ok {
+			return nil
+		}
+		var violations []validation.Violation
+		for i, elem := range list {
+			violations = append(violations, checkFields(elem, t.Elem(), fmt.Sprintf("%s[%d]", path, i))...)
+		}
+		return violations
+	case t.Kind() != reflect.Struct:
+		return nil
+	}
+
+	obj, ok := doc.(map[string]any)
+	if !ok {
+		return nil
+	}
+
+	var violations []validation.Violation
+	known := make(map[string]bool, t.NumField())
+	for i := 0; i < t.NumField(); i++ {
+		f := t.Field(i)
+		name, opts, _ := strings.Cut(f.Tag.Get("json"), ",")
+		if name == "" || name == "-" || !f.IsExported() {
+			continue
+		}
+		known[name] = true
+		p := joinPath(path, name)
+		optional := strings.Contains(opts, "omitempty")
+
+		value, present := obj[name]
+		switch {
+		case !present && optional:
+		case !present:
+			violations = append(violations, validation.Violation{Path: p, Rule: "strict:missing", Message: "is missing"})
+		case value == nil:
+			violations = append(violations, validation.Violation{Path: p, Rule: "strict:null", Message: "must not be null"})
+		default:
+			violations = append(violations, checkFields(value, f.Type, p)...)
+		}
+	}
+
+	var unknown []string
+	for k := range obj {
+		if !known[k] {
+			unknown = append(unknown, k)
+		}
+	}
+	sort.Strings(unknown)
+	for _, k := range unknown {
+		violations = append(violations, validation.Violation{
+			Path: joinPath(path, k), Rule: "strict:unknown", Value: obj[k], Message: "unknown field",
+		})
+	}
+	return violations
+}
+
+func joinPath(path, key string) string {
+	if path == "" {
+		return key
+	}
+	return path + "." + key
+}
@@ -0,0 +1,127 @@
+package codec
+
+import (
+	"encoding/json"
+	"errors"
+	"order-service/internal/validation"
+	"os"
+	"reflect"
+	"testing"
+)
+
+// modelDoc returns model.json as a generic document to edit.
+func modelDoc(t *testing.T) map[string]any {
+	t.Helper()
+	data, err := os.ReadFile("../../model.json")
+	if err != nil {
+		t.Fatalf("read model.json: %v", err)
+	}
+	var doc map[string]any
+	if err := json.Unmarshal(data, &doc); err != nil {
+		t.Fatalf("decode model.json: %v", err)
+	}
+	return doc
+}
+
+func TestDecodeJSONStrict(t *testing.T) {
+	item := func(doc map[string]any) map[string]any {
+		return doc["items"].([]any)[0].(map[string]any)
+	}
+	tests := []struct {
+		name string
+		edit func(doc map[string]any)
+		want []string // path rule
+	}{
+		{"valid", func(map[string]any) {}, nil},
+		{"status may be left out", func(doc map[string]any) { delete(doc, "status") }, nil},
+		{"status given", func(doc map[string]any) { doc["status"] = "paid" }, nil},
+		{"explicit zero", func(doc map[string]any) { doc["sm_id"] = 0 }, nil},
+		{"missing field", func(doc map[string]any) { delete(doc, "sm_id") },
+			[]string{"sm_id strict:missing"}},
+		{"missing nested field", func(doc map[string]any) { delete(doc["payment"].(map[string]any), "amount") },
+			[]string{"payment.amount strict:missing"}},
+		{"missing item field", func(doc map[string]any) { delete(item(doc), "chrt_id") },
+			[]string{"items[0].chrt_id strict:missing"}},
+		{"null field", func(doc map[string]any) { doc["delivery"] = nil },
+			[]string{"delivery strict:null"}},
+		{"null optional field", func(doc map[string]any) { doc["status"] = nil },
+			[]string{"status strict:null"}},
+		{"null item field", func(doc map[string]any) { item(doc)["price"] = nil },
+			[]string{"items[0].price strict:null"}},
+		{"unknown fields", func(doc map[string]any) {
+			doc["zeta"] = 1
+			doc["alpha"] = true
+			doc["delivery"].(map[string]any)["floor"] = 3
+		}, []string{"delivery.floor strict:unknown", "alpha strict:unknown", "zeta strict:unknown"}},
+		{"everything at once", func(doc map[string]any) {
+			delete(doc, "locale")
+			doc["entry"] = nil
+			item(doc)["color"] = "red"
+		}, []string{"entry strict:null", "items[0].color strict:unknown", "locale strict:missing"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			doc := modelDoc(t)
+			tt.edit(doc)
+			data, _ := json.Marshal(doc)
+
+			order, err := DecodeJSON(data, true)
+			if tt.want == nil {
+				if err != nil {
+					t.Fatalf("DecodeJSON: %v", err)
+				}
+				if order.OrderUID != "b563feb7b2b84b6test" {
+					t.Errorf("decoded order %q", order.OrderUID)
+				}
+				return
+			}
+			var verr *validation.ValidationError
+			if !errors.As(err, &verr) {
+				t.Fatalf("DecodeJSON error = %v, want a *validation.ValidationError", err)
+			}
+			var got []string
+			for _, v := range verr.Violations {
+				got = append(got, v.Path+" "+v.Rule)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("violations = %q, want %q", got, tt.want)
+			}
+
+			// Lenient mode accepts what strict mode rejects.
+			if _, err := DecodeJSON(data, false); err != nil {
+				t.Errorf("lenient DecodeJSON: %v", err)
+			}
+		})
+	}
+}
+
+func TestDecodeJSONDateCreated(t *testing.T) {
+	for _, strict := range []bool{true, false} {
+		for value, ok := range map[string]bool{
+			"2021-11-26T06:22:19.123Z":  true,
+			"2021-11-26T06:22:19":       true,
+			"2021-11-26 06:22:19+03:00": true,
+			"2021-11-26 06:22:19":       true,
+			"26.11.2021":                false,
+			"2021-11-26":                false,
+		} {
+			doc := modelDoc(t)
+			doc["date_created"] = value
+			data, _ := json.Marshal(doc)
+			_, err := DecodeJSON(data, strict)
+			if (err == nil) != ok {
+				t.Errorf("strict=%t, date_created %q: err = %v, want ok=%t", strict, value, err, ok)
+			}
+		}
+	}
+}
+
+func TestDecodeJSONRejectsMalformedDocuments(t *testing.T) {
+	for _, data := range []string{`{`, `[]`, `"order"`, `{"order_uid": 1}`} {
+		for _, strict := range []bool{true, false} {
+			if _, err := DecodeJSON([]byte(data), strict); err == nil {
+				t.Errorf("DecodeJSON(%s, strict=%t) succeeded, want an error", data, strict)
+			}
+		}
+	}
+}
@@ -0,0 +1,158 @@
+package codec
+
+import (
+	"context"
+	"fmt"
+	"order-service/internal/db"
+	ordersv1 "order-service/proto/ordersv1"
+
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/types/known/timestamppb"
+)
+
+// Protobuf encodes orders as the orders.v1.Order message from
+// proto/order.proto, using the generated types in proto/ordersv1.
+type Protobuf struct{}
+
+func (Protobuf) ContentType() string {
+	return ContentTypeProtobuf
+}
+
+func (Protobuf) Encode(ctx context.Context, order *db.Order) ([]byte, error) {
+	data, err := proto.Marshal(ToProto(order))
+	if err != nil {
+		return nil, fmt.Errorf("failed to encode protobuf order: %w", err)
+	}
+	return data, nil
+}
+
+func (Protobuf) Decode(ctx context.Context, data []byte) (*db.Order, error) {
+	var msg ordersv1.Order
+	if err := proto.Unmarshal(data, &msg); err != nil {
+		return nil, fmt.Errorf("failed to decode protobuf order: %w", err)
+	}
+	return FromProto(&msg), nil
+}
+
+// ToProto converts an order to its orders.v1 message. A zero DateCreated is
+// left unset.
+func ToProto(o *db.Order) *ordersv1.Order {
+	items := make([]*ordersv1.Item, 0, len(o.Items))
+	for _, it := range o.Items {
+		items = append(items, &ordersv1.Item{
+			ChrtId:      int64(it.ChrtID),
+			TrackNumber: it.TrackNumber,
+			Price:       int64(it.Price),
+			Rid:         it.Rid,
+			Name:        it.Name,
+			Sale:        int64(it.Sale),
+			Size:        it.Size,
+			TotalPrice:  int64(it.TotalPrice),
+			NmId:        int64(it.NmID),
+			Brand:       it.Brand,
+			Status:      int64(it.Status),
+		})
+	}
+	msg := &ordersv1.Order{
+		OrderUid:    o.OrderUID,
+		TrackNumber: o.TrackNumber,
+		Entry:       o.Entry,
+		Delivery: &ordersv1.Delivery{
+			Name:    o.Delivery.Name,
+			Phone:   o.Delivery.Phone,
+			Zip:     o.Delivery.Zip,
+			City:    o.Delivery.City,
+			Address: o.Delivery.Address,
+			Region:  o.Delivery.Region,
+			Email:   o.Delivery.Email,
+		},
+		Payment: &ordersv1.Payment{
+			Transaction:  o.Payment.Transaction,
+			RequestId:    o.Payment.RequestID,
+			Currency:     o.Payment.Currency,
+			Provider:     o.Payment.Provider,
+			Amount:       int64(o.Payment.Amount),
+			PaymentDt:    o.Payment.PaymentDt,
+			Bank:         o.Payment.Bank,
+			DeliveryCost: int64(o.Payment.DeliveryCost),
+			GoodsTotal:   int64(o.Payment.GoodsTotal),
+			CustomFee:    int64(o.Payment.CustomFee),
+		},
+		Items:             items,
+		Locale:            o.Locale,
+		InternalSignature: o.InternalSignature,
+		CustomerId:        o.CustomerID,
+		DeliveryService:   o.DeliveryService,
+		Shardkey:          o.Shardkey,
+		SmId:              int64(o.SmID),
+		OofShard:          o.OofShard,
+		Status:            string(o.Status),
+	}
+	if !o.DateCreated.IsZero() {
+		msg.DateCreated = timestamppb.New(o.DateCreated)
+	}
+	return msg
+}
+
+// FromProto converts an orders.v1 message to an order. Missing delivery,
+// payment and date_created fields decode to zero values.
+func FromProto(m *ordersv1.Order) *db.Order {
+	order := &db.Order{
+		OrderUID:          m.GetOrderUid(),
+		TrackNumber:       m.GetTrackNumber(),
+		Entry:             m.GetEntry(),
+		Items:             make([]db.Item, 0, len(m.GetItems())),
+		Locale:            m.GetLocale(),
+		InternalSignature: m.GetInternalSignature(),
+		CustomerID:        m.GetCustomerId(),
+		DeliveryService:   m.GetDeliveryService(),
+		Shardkey:          m.GetShardkey(),
+		SmID:              int(m.GetSmId()),
+		OofShard:          m.GetOofShard(),
+		Status:            db.OrderStatus(m.GetStatus()),
+	}
+	if d := m.GetDelivery(); d != nil {
+		order.Delivery = db.Delivery{
+			Name:    d.GetName(),
+			Phone:   d.GetPhone(),
+			Zip:     d.GetZip(),
+			City:    d.GetCity(),
+			Address: d.GetAddress(),
+			Region:  d.GetRegion(),
+			Email:   d.GetEmail(),
+		}
+	}
+	if p := m.GetPayment(); p != nil {
+		order.Payment = db.Payment{
+			Transaction:  p.GetTransaction(),
+			RequestID:    p.GetRequestId(),
+			Currency:     p.GetCurrency(),
+			Provider:     p.GetProvider(),
+			Amount:       int(p.GetAmount()),
+			PaymentDt:    p.GetPaymentDt(),
+			Bank:         p.GetBank(),
+			DeliveryCost: int(p.GetDeliveryCost()),
+			GoodsTotal:   int(p.GetGoodsTotal()),
+			CustomFee:    int(p.GetCustomFee()),
+		}
+	}
+	for _, it := range m.GetItems() {
+		order.Items = append(order.Items, db.Item{
+			ChrtID:      int(it.GetChrtId()),
+			TrackNumber: it.GetTrackNumber(),
+			Price:       int(it.GetPrice()),
+			Rid:         it.GetRid(),
+			Name:        it.GetName(),
+			Sale:        int(it.GetSale()),
+			Size:        it.GetSize(),
+			TotalPrice:  int(it.GetTotalPrice()),
+			NmID:        int(it.GetNmId()),
+			Brand:       it.GetBrand(),
+			Status:      int(it.GetStatus()),
+		})
+	}
+	if ts := m.GetDateCreated(); ts != nil {
+		order.DateCreated = ts.AsTime()
+	}
+	return order
+}
@@ -0,0 +1,94 @@
+package codec
+
+import (
+	"context"
+	"order-service/internal/db"
+	ordersv1 "order-service/proto/ordersv1"
+	"os"
+	"reflect"
+	"testing"
+
+	"google.golang.org/protobuf/proto"
+)
+
+func modelOrder(t *testing.T) *db.Order {
+	t.Helper()
+	data, err := os.ReadFile("../../model.json")
+	if err != nil {
+		t.Fatalf("read model.json: %v", err)
+	}
+	order, err := DecodeJSON(data, true)
+	if err != nil {
+		t.Fatalf("decode model.json: %v", err)
+	}
+	return order
+}
+
+func TestProtobufRoundTrip(t *testing.T) {
+	ctx := context.Background()
+	multi := modelOrder(t)
+	second := multi.Items[0]
+	second.ChrtID, second.Rid, second.Status = 1, "second", 0
+	multi.Items = append(multi.Items, second)
+	multi.Status = db.StatusShipped
+
+	tests := []struct {
+		name  string
+		order *db.Order
+	}{
+		{"model.json", modelOrder(t)},
+		{"several items and a status", multi},
+		{"zero values", &db.Order{OrderUID: "empty", Items: []db.Item{}}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			data, err := Protobuf{}.Encode(ctx, tt.order)
+			if err != nil {
+				t.Fatalf("encode: %v", err)
+			}
+			got, err := Protobuf{}.Decode(ctx, data)
+			if err != nil {
+				t.Fatalf("decode: %v", err)
+			}
+			if !reflect.DeepEqual(got, tt.order) {
+				t.Errorf("round trip changed the order:\n got %+v\nwant %+v", got, tt.order)
+			}
+		})
+	}
+}
+
+func TestProtobufDecodesGeneratedMessages(t *testing.T) {
+	want := modelOrder(t)
+	data, err := proto.Marshal(ToProto(want))
+	if err != nil {
+		t.Fatalf("marshal: %v", err)
+	}
+	var msg ordersv1.Order
+	if err := proto.Unmarshal(data, &msg); err != nil {
+		t.Fatalf("unmarshal: %v", err)
+	}
+	if msg.GetOrderUid() != want.OrderUID || len(msg.GetItems()) != 1 || msg.GetDateCreated().AsTime() != want.DateCreated {
+		t.Errorf("generated message = %v", &msg)
+	}
+
+	// A message with no nested messages set decodes to zero values.
+	got, err := Protobuf{}.Decode(context.Background(), nil)
+	if err != nil {
+		t.Fatalf("decode empty message: %v", err)
+	}
+	if !reflect.DeepEqual(got, &db.Order{Items: []db.Item{}}) {
+		t.Errorf("empty message decoded to %+v", got)
+	}
+}
+
+func TestProtobufRejectsMalformedData(t *testing.T) {
+	for _, data := range [][]byte{
+		{0x0a, 0x05, 'a'},  // order_uid longer than the message
+		{0x0a, 0x01, 0xff}, // order_uid not valid UTF-8
+		{0xff},             // truncated tag
+	} {
+		if _, err := (Protobuf{}).Decode(context.Background(), data); err == nil {
+			t.Errorf("Decode(%x) succeeded, want an error", data)
+		}
+	}
+}
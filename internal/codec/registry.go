@@ -0,0 +1,135 @@
+package codec
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/linkedin/goavro/v2"
+)
+
+// Registry is a client for a Confluent-compatible schema registry. Schemas
+// are immutable once registered, so every schema is fetched once and kept,
+// and so is the error for one that cannot be used.
+type Registry struct {
+	url    string
+	client *http.Client
+
+	mu      sync.Mutex
+	schemas map[uint32]*avroSchema
+	invalid map[uint32]error
+}
+
+type avroSchema struct {
+	codec *goavro.Codec
+	root  any
+	names map[string]map[string]any
+}
+
+// UnavailableError reports that the registry could not be asked for a
+// schema: the request failed, timed out or got a 5xx or 429 answer. The
+// message itself may be fine, so it should be retried rather than rejected.
+type UnavailableError struct {
+	ID  uint32
+	Err error
+}
+
+func (e *UnavailableError) Error() string {
+	return fmt.Sprintf("schema registry unavailable for schema %d: %v", e.ID, e.Err)
+}
+
+func (e *UnavailableError) Unwrap() error {
+	return e.Err
+}
+
+// IsRetryable reports whether err is a temporary failure to decode a message,
+// as opposed to a message that can never be decoded.
+func IsRetryable(err error) bool {
+	var uerr *UnavailableError
+	return errors.As(err, &uerr)
+}
+
+func NewRegistry(url string, timeout time.Duration) *Registry {
+	return &Registry{
+		url:     strings.TrimSuffix(url, "/"),
+		client:  &http.Client{Timeout: timeout},
+		schemas: make(map[uint32]*avroSchema),
+		invalid: make(map[uint32]error),
+	}
+}
+
+func (r *Registry) schema(ctx context.Context, id uint32) (*avroSchema, error) {
+	r.mu.Lock()
+	s, ok := r.schemas[id]
+	invalid := r.invalid[id]
+	r.mu.Unlock()
+	if ok {
+		return s, nil
+	}
+	if invalid != nil {
+		return nil, invalid
+	}
+
+	reg, err := r.fetch(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+
+	if reg.SchemaType != "" && reg.SchemaType != "AVRO" {
+		err = fmt.Errorf("is %s, not AVRO", reg.SchemaType)
+	} else {
+		s, err = parseAvroSchema(reg.Schema)
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if err != nil {
+		err = fmt.Errorf("schema %d: %w", id, err)
+		r.invalid[id] = err
+		return nil, err
+	}
+	r.schemas[id] = s
+	return s, nil
+}
+
+type registeredSchema struct {
+	Schema     string `json:"schema"`
+	SchemaType string `json:"schemaType"`
+}
+
+// fetch returns the schema registered under id. Failures to reach the
+// registry are returned as *UnavailableError.
+func (r *Registry) fetch(ctx context.Context, id uint32) (*registeredSchema, error) {
+	url := r.url + "/schemas/ids/" + strconv.FormatUint(uint64(id), 10)
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Accept", "application/vnd.schemaregistry.v1+json")
+
+	resp, err := r.client.Do(req)
+	if err != nil {
+		return nil, &UnavailableError{ID: id, Err: err}
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		body, _ := io.ReadAll(io.LimitReader(resp.Body, 512))
+		err := fmt.Errorf("%s: %s", resp.Status, strings.TrimSpace(string(body)))
+		if resp.StatusCode >= 500 || resp.StatusCode == http.StatusTooManyRequests {
+			return nil, &UnavailableError{ID: id, Err: err}
+		}
+		return nil, fmt.Errorf("failed to fetch schema %d: %w", id, err)
+	}
+
+	var reg registeredSchema
+	if err := json.NewDecoder(resp.Body).Decode(&reg); err != nil {
+		return nil, &UnavailableError{ID: id, Err: fmt.Errorf("failed to read response: %w", err)}
+	}
+	return &reg, nil
+}
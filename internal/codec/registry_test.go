@@ -0,0 +1,173 @@
+package codec
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"reflect"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+// fakeRegistry serves /schemas/ids/{id} from a map and counts the lookups of
+// every id. An id without an entry answers with status.
+type fakeRegistry struct {
+	mu       sync.Mutex
+	schemas  map[string]string
+	status   int
+	requests map[string]int
+}
+
+func newFakeRegistry(t *testing.T) (*fakeRegistry, *httptest.Server) {
+	t.Helper()
+	orderSchema, err := os.ReadFile("testdata/order.avsc")
+	if err != nil {
+		t.Fatalf("read order schema: %v", err)
+	}
+	f := &fakeRegistry{
+		schemas:  map[string]string{"1": string(orderSchema)},
+		status:   http.StatusNotFound,
+		requests: make(map[string]int),
+	}
+	srv := httptest.NewServer(f)
+	t.Cleanup(srv.Close)
+	return f, srv
+}
+
+func (f *fakeRegistry) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	id := strings.TrimPrefix(r.URL.Path, "/schemas/ids/")
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.requests[id]++
+	spec, ok := f.schemas[id]
+	if !ok {
+		http.Error(w, `{"error_code":40403,"message":"Schema not found"}`, f.status)
+		return
+	}
+	json.NewEncoder(w).Encode(map[string]string{"schema": spec})
+}
+
+func (f *fakeRegistry) set(id, spec string, status int) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if spec == "" {
+		delete(f.schemas, id)
+	} else {
+		f.schemas[id] = spec
+	}
+	f.status = status
+}
+
+func (f *fakeRegistry) count(id string) int {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return f.requests[id]
+}
+
+func TestAvroRoundTrip(t *testing.T) {
+	f, srv := newFakeRegistry(t)
+	ctx := context.Background()
+	avro := Avro{Registry: NewRegistry(srv.URL, time.Second), Strict: true, SchemaID: 1}
+
+	want := modelOrder(t)
+	data, err := avro.Encode(ctx, want)
+	if err != nil {
+		t.Fatalf("encode: %v", err)
+	}
+	if !IsConfluentFramed(data) || data[4] != 1 {
+		t.Fatalf("encoded header = %x, want magic byte and schema 1", data[:confluentHeaderSize])
+	}
+
+	for range 2 {
+		got, err := avro.Decode(ctx, data)
+		if err != nil {
+			t.Fatalf("decode: %v", err)
+		}
+		if !reflect.DeepEqual(got, want) {
+			t.Errorf("round trip changed the order:\n got %+v\nwant %+v", got, want)
+		}
+	}
+	if n := f.count("1"); n != 1 {
+		t.Errorf("schema fetched %d times, want once", n)
+	}
+}
+
+func TestRegistryOutagesAreRetryable(t *testing.T) {
+	f, srv := newFakeRegistry(t)
+	ctx := context.Background()
+	r := NewRegistry(srv.URL, time.Second)
+
+	f.set("1", "", http.StatusServiceUnavailable)
+	if _, err := r.schema(ctx, 1); !IsRetryable(err) {
+		t.Errorf("503: err = %v, want a retryable error", err)
+	}
+	f.set("1", "", http.StatusTooManyRequests)
+	if _, err := r.schema(ctx, 1); !IsRetryable(err) {
+		t.Errorf("429: err = %v, want a retryable error", err)
+	}
+
+	// An outage is not remembered: the schema is fetched once it is back.
+	spec, _ := os.ReadFile("testdata/order.avsc")
+	f.set("1", string(spec), http.StatusNotFound)
+	if _, err := r.schema(ctx, 1); err != nil {
+		t.Errorf("after the outage: %v", err)
+	}
+
+	down := NewRegistry(srv.URL, time.Second)
+	srv.Close()
+	_, err := down.schema(ctx, 1)
+	var uerr *UnavailableError
+	if !errors.As(err, &uerr) || uerr.ID != 1 {
+		t.Errorf("unreachable registry: err = %v, want *UnavailableError for schema 1", err)
+	}
+}
+
+func TestRegistryTimeoutIsRetryable(t *testing.T) {
+	release := make(chan struct{})
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		<-release
+	}))
+	defer srv.Close()
+	defer close(release)
+
+	_, err := NewRegistry(srv.URL, 50*time.Millisecond).schema(context.Background(), 1)
+	if !IsRetryable(err) {
+		t.Errorf("err = %v, want a retryable error", err)
+	}
+}
+
+func TestRegistryRemembersUnusableSchemas(t *testing.T) {
+	f, srv := newFakeRegistry(t)
+	ctx := context.Background()
+	r := NewRegistry(srv.URL, time.Second)
+	f.set("2", `{"type": "record", "name": "Order", "fields": [{"name": "order_uid", "type": "string"}]}`, http.StatusNotFound)
+	f.set("3", `{"type": "record", "name": `, http.StatusNotFound)
+
+	for _, id := range []uint32{2, 3} {
+		for range 2 {
+			_, err := r.schema(ctx, id)
+			if err == nil || IsRetryable(err) {
+				t.Errorf("schema %d: err = %v, want a permanent error", id, err)
+			}
+		}
+	}
+	if f.count("2") != 1 || f.count("3") != 1 {
+		t.Errorf("unusable schemas fetched %d and %d times, want once each", f.count("2"), f.count("3"))
+	}
+
+	// A schema id the registry does not know is a permanent error for the
+	// message, but is asked again for the next one.
+	for range 2 {
+		if _, err := r.schema(ctx, 4); err == nil || IsRetryable(err) {
+			t.Errorf("unknown schema: err = %v, want a permanent error", err)
+		}
+	}
+	if n := f.count("4"); n != 2 {
+		t.Errorf("unknown schema fetched %d times, want 2", n)
+	}
+}
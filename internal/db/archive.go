@@ -0,0 +1,149 @@
+package db
+
+import (
+	"compress/gzip"
+	"context"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/lib/pq"
+)
+
+func lockExpiredOrders(ctx context.Context, tx *sql.Tx, before time.Time, limit int) ([]string, error) {
+	rows, err := tx.QueryContext(ctx, `
+		SELECT order_uid
+		FROM orders
+		WHERE date_created < $1
+		ORDER BY date_created
+		LIMIT $2
+		FOR UPDATE SKIP LOCKED`, before, limit)
+	if err != nil {
+		return nil, fmt.Errorf("failed to select expired orders: %w", err)
+	}
+	defer rows.Close()
+
+	var uids []string
+	for rows.Next() {
+		var uid string
+		if err := rows.Scan(&uid); err != nil {
+			return nil, fmt.Errorf("failed to scan order uid: %w", err)
+		}
+		uids = append(uids, uid)
+	}
+	return uids, rows.Err()
+}
+
+// orderColumns is listed explicitly because columns added to orders later are
+// appended after archived_at in orders_archive.
+const orderColumns = `order_uid, track_number, entry, locale, internal_signature, customer_id,
+	delivery_service, shardkey, sm_id, date_created, oof_shard, deleted_at, status`
+
+func purgeOrders(ctx context.Context, tx *sql.Tx, uids []string) error {
+	_, err := tx.ExecContext(ctx, "DELETE FROM orders WHERE order_uid = ANY($1)", pq.Array(uids))
+	if err != nil {
+		return fmt.Errorf("failed to purge orders: %w", err)
+	}
+	return nil
+}
+
+// ArchiveOrdersToTables moves up to limit orders created before the given time
+// into the *_archive tables and returns their uids.
+func (d *Database) ArchiveOrdersToTables(ctx context.Context, before time.Time, limit int) ([]string, error) {
+	tx, err := d.Conn.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	uids, err := lockExpiredOrders(ctx, tx, before, limit)
+	if err != nil || len(uids) == 0 {
+		return nil, err
+	}
+
+	statements := []struct{ table, query string }{
+		{"orders", `
+			INSERT INTO orders_archive (` + orderColumns + `, archived_at)
+			SELECT ` + orderColumns + `, NOW() FROM orders WHERE order_uid = ANY($1)`},
+		{"deliveries", "INSERT INTO deliveries_archive SELECT * FROM deliveries WHERE order_uid = ANY($1)"},
+		{"payments", "INSERT INTO payments_archive SELECT * FROM payments WHERE order_uid = ANY($1)"},
+		{"items", "INSERT INTO items_archive SELECT * FROM items WHERE order_uid = ANY($1)"},
+		{"order_status_history", "INSERT INTO order_status_history_archive SELECT * FROM order_status_history WHERE order_uid = ANY($1)"},
+	}
+	for _, st := range statements {
+		if _, err := tx.ExecContext(ctx, st.query, pq.Array(uids)); err != nil {
+			return nil, fmt.Errorf("failed to archive %s: %w", st.table, err)
+		}
+	}
+
+	if err := purgeOrders(ctx, tx, uids); err != nil {
+		return nil, err
+	}
+	if err := tx.Commit(); err != nil {
+		return nil, fmt.Errorf("failed to commit archive: %w", err)
+	}
+	return uids, nil
+}
+
+// ArchiveOrdersToFile writes up to limit orders created before the given time
+// to a gzipped NDJSON file in dir and then purges them. The file is synced
+// before the purge is committed and removed if the purge fails, so a crash can
+// only duplicate archived orders, never lose them.
+func (d *Database) ArchiveOrdersToFile(ctx context.Context, before time.Time, limit int, dir string) ([]string, error) {
+	tx, err := d.Conn.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	uids, err := lockExpiredOrders(ctx, tx, before, limit)
+	if err != nil || len(uids) == 0 {
+		return nil, err
+	}
+
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return nil, fmt.Errorf("failed to create archive dir: %w", err)
+	}
+	name := filepath.Join(dir, fmt.Sprintf("orders-%s.ndjson.gz", time.Now().UTC().Format("20060102T150405.000000000")))
+	f, err := os.OpenFile(name, os.O_CREATE|os.O_EXCL|os.O_WRONLY, 0o644)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create archive file: %w", err)
+	}
+	committed := false
+	defer func() {
+		f.Close()
+		if !committed {
+			os.Remove(name)
+		}
+	}()
+
+	gz := gzip.NewWriter(f)
+	enc := json.NewEncoder(gz)
+	for _, uid := range uids {
+		order, err := loadOrder(ctx, tx, uid, true)
+		if err != nil {
+			return nil, fmt.Errorf("failed to load order %s: %w", uid, err)
+		}
+		if err := enc.Encode(order); err != nil {
+			return nil, fmt.Errorf("failed to write order %s: %w", uid, err)
+		}
+	}
+	if err := gz.Close(); err != nil {
+		return nil, fmt.Errorf("failed to flush archive file: %w", err)
+	}
+	if err := f.Sync(); err != nil {
+		return nil, fmt.Errorf("failed to sync archive file: %w", err)
+	}
+
+	if err := purgeOrders(ctx, tx, uids); err != nil {
+		return nil, err
+	}
+	if err := tx.Commit(); err != nil {
+		return nil, fmt.Errorf("failed to commit archive: %w", err)
+	}
+	committed = true
+	return uids, nil
+}
@@ -0,0 +1,157 @@
+package db
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"sort"
+	"time"
+)
+
+// Favorite is a brand or delivery service and how many times a customer
+// chose it: items for brands, orders for delivery services.
+type Favorite struct {
+	Name  string `json:"name"`
+	Count int    `json:"count"`
+}
+
+// CustomerSummary aggregates every stored order of one customer.
+type CustomerSummary struct {
+	CustomerID               string           `json:"customer_id"`
+	OrderCount               int              `json:"order_count"`
+	TotalSpent               map[string]int64 `json:"total_spent"`
+	FirstOrderAt             *time.Time       `json:"first_order_at,omitempty"`
+	LastOrderAt              *time.Time       `json:"last_order_at,omitempty"`
+	FavoriteBrands           []Favorite       `json:"favorite_brands"`
+	FavoriteDeliveryServices []Favorite       `json:"favorite_delivery_services"`
+}
+
+func newCustomerSummary(customerID string) *CustomerSummary {
+	return &CustomerSummary{
+		CustomerID:               customerID,
+		TotalSpent:               map[string]int64{},
+		FavoriteBrands:           []Favorite{},
+		FavoriteDeliveryServices: []Favorite{},
+	}
+}
+
+// add folds one order into the summary. Favorites are accumulated as counts
+// and must be finished with topFavorites.
+func (s *CustomerSummary) add(o *Order, brands, services map[string]int) {
+	s.OrderCount++
+	s.TotalSpent[o.Payment.Currency] += int64(o.Payment.Amount)
+	if s.FirstOrderAt == nil || o.DateCreated.Before(*s.FirstOrderAt) {
+		t := o.DateCreated
+		s.FirstOrderAt = &t
+	}
+	if s.LastOrderAt == nil || o.DateCreated.After(*s.LastOrderAt) {
+		t := o.DateCreated
+		s.LastOrderAt = &t
+	}
+	for _, item := range o.Items {
+		brands[item.Brand]++
+	}
+	services[o.DeliveryService]++
+}
+
+// topFavorites orders counts by frequency and then name and keeps the first
+// top of them; top 0 keeps all.
+func topFavorites(counts map[string]int, top int) []Favorite {
+	favorites := make([]Favorite, 0, len(counts))
+	for name, count := range counts {
+		favorites = append(favorites, Favorite{Name: name, Count: count})
+	}
+	sort.Slice(favorites, func(i, j int) bool {
+		if favorites[i].Count != favorites[j].Count {
+			return favorites[i].Count > favorites[j].Count
+		}
+		return favorites[i].Name < favorites[j].Name
+	})
+	if top > 0 && len(favorites) > top {
+		favorites = favorites[:top]
+	}
+	return favorites
+}
+
+// GetCustomerSummaryContext computes the summary in one statement. Every
+// aggregate starts from the customer's rows in idx_orders_customer and joins
+// payments and items by order_uid, so the cost grows with the customer's
+// orders rather than the table. top limits each favorites list; 0 returns
+// all of them.
+func (d *Database) GetCustomerSummaryContext(ctx context.Context, customerID string, top int) (*CustomerSummary, error) {
+	ctx, cancel := context.WithTimeout(ctx, d.timeouts.List)
+	defer cancel()
+
+	limit := sql.NullInt64{Int64: int64(top), Valid: top > 0}
+	const query = `
+		WITH o AS (
+			SELECT order_uid, delivery_service, date_created
+			FROM orders
+			WHERE customer_id = $1 AND deleted_at IS NULL
+		)
+		SELECT
+			(SELECT count(*) FROM o),
+			(SELECT min(date_created) FROM o),
+			(SELECT max(date_created) FROM o),
+			COALESCE((
+				SELECT json_object_agg(currency, total)
+				FROM (
+					SELECT p.currency, sum(p.amount) AS total
+					FROM o JOIN payments p ON p.order_uid = o.order_uid
+					GROUP BY p.currency
+				) t
+			), '{}'::json),
+			COALESCE((
+				SELECT json_agg(json_build_object('name', brand, 'count', n) ORDER BY n DESC, brand)
+				FROM (
+					SELECT i.brand, count(*) AS n
+					FROM o JOIN items i ON i.order_uid = o.order_uid
+					GROUP BY i.brand
+					ORDER BY n DESC, i.brand
+					LIMIT $2
+				) t
+			), '[]'::json),
+			COALESCE((
+				SELECT json_agg(json_build_object('name', delivery_service, 'count', n) ORDER BY n DESC, delivery_service)
+				FROM (
+					SELECT delivery_service, count(*) AS n
+					FROM o
+					GROUP BY delivery_service
+					ORDER BY n DESC, delivery_service
+					LIMIT $2
+				) t
+			), '[]'::json)`
+
+	summary := newCustomerSummary(customerID)
+	var first, last sql.NullTime
+	var spent, brands, services []byte
+	scan := func(conn *sql.DB) error {
+		return conn.QueryRowContext(ctx, query, customerID, limit).
+			Scan(&summary.OrderCount, &first, &last, &spent, &brands, &services)
+	}
+	conn := d.reader("")
+	err := scan(conn)
+	if d.retryOnPrimary(ctx, conn, err) {
+		err = scan(d.Conn)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to get customer summary: %w", err)
+	}
+	if first.Valid {
+		summary.FirstOrderAt = &first.Time
+	}
+	if last.Valid {
+		summary.LastOrderAt = &last.Time
+	}
+	if err := json.Unmarshal(spent, &summary.TotalSpent); err != nil {
+		return nil, fmt.Errorf("failed to decode total spent: %w", err)
+	}
+	if err := json.Unmarshal(brands, &summary.FavoriteBrands); err != nil {
+		return nil, fmt.Errorf("failed to decode favorite brands: %w", err)
+	}
+	if err := json.Unmarshal(services, &summary.FavoriteDeliveryServices); err != nil {
+		return nil, fmt.Errorf("failed to decode favorite delivery services: %w", err)
+	}
+	return summary, nil
+}
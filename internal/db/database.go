@@ -4,6 +4,7 @@ import (
 	"context"
 	"database/sql"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"log"
 	"order-service/config"
@@ -12,41 +13,171 @@ import (
 	_ "github.com/lib/pq"
 )
 
+var ErrOrderNotFound = errors.New("order not found")
+
+// ErrOrderExists is returned by CreateOrderContext when the order is already
+// stored.
+var ErrOrderExists = errors.New("order already exists")
+
 type Database struct {
-	Conn *sql.DB
+	Conn     *sql.DB
+	replicas *replicaSet
+	timeouts Timeouts
 }
 
-func NewDB(cfg *config.Config) (*Database, error) {
-	connStr := fmt.Sprintf(
-		"host=%s port=%s user=%s password=%s dbname=%s sslmode=disable",
-		cfg.DBHost, cfg.DBPort, cfg.DBUser, cfg.DBPassword, cfg.DBName,
-	)
+type Timeouts struct {
+	Save   time.Duration
+	Get    time.Duration
+	List   time.Duration
+	Exists time.Duration
+	Delete time.Duration
+}
 
-	log.Printf("Connecting to database with: %s", connStr)
-	db, err := sql.Open("postgres", connStr)
+func NewDB(cfg *config.Config) (*Database, error) {
+	d, err := Open(cfg, BuildDSN(cfg))
 	if err != nil {
-		return nil, fmt.Errorf("failed to connect to database: %w", err)
+		return nil, err
 	}
-	if err := db.Ping(); err != nil {
-		return nil, fmt.Errorf("database ping failed: %w", err)
+	if len(cfg.DBReplicas) == 0 {
+		return d, nil
 	}
 
-	db.SetMaxOpenConns(25)
-	db.SetMaxIdleConns(25)
-	db.SetConnMaxLifetime(5 * time.Minute)
+	conns := make([]*sql.DB, 0, len(cfg.DBReplicas))
+	names := make([]string, 0, len(cfg.DBReplicas))
+	up := make([]bool, 0, len(cfg.DBReplicas))
+	for i, dsn := range cfg.DBReplicas {
+		conn, err := openConn(cfg, withConnParams(dsn, cfg), 1)
+		if err != nil {
+			// An unreachable replica must not keep the service from starting;
+			// the health check brings it into rotation once it answers.
+			log.Printf("Read replica #%d is not available yet: %v", i, err)
+		}
+		if conn == nil {
+			continue
+		}
+		conns = append(conns, conn)
+		names = append(names, fmt.Sprintf("#%d", i))
+		up = append(up, err == nil)
+	}
+	d.replicas = newReplicaSet(conns, names, up, cfg.DBReplicaCheckInterval, cfg.DBReadYourWritesWindow)
+	log.Printf("Serving reads from %d replicas", len(conns))
+	return d, nil
+}
+
+func Open(cfg *config.Config, connStr string) (*Database, error) {
+	connStr = withConnParams(connStr, cfg)
+	log.Printf("Connecting to database with: %s", RedactDSN(connStr))
+	db, err := openConn(cfg, connStr, cfg.DBConnectAttempts)
+	if err != nil {
+		if db != nil {
+			db.Close()
+		}
+		return nil, err
+	}
 
 	log.Println("Successfully connected to database")
-	return &Database{Conn: db}, nil
+	return &Database{
+		Conn: db,
+		timeouts: Timeouts{
+			Save:   cfg.DBSaveTimeout,
+			Get:    cfg.DBGetTimeout,
+			List:   cfg.DBListTimeout,
+			Exists: cfg.DBExistsTimeout,
+			Delete: cfg.DBDeleteTimeout,
+		},
+	}, nil
+}
+
+// openConn opens a pool for connStr and pings it up to attempts times with
+// exponential backoff, so the service survives a database that is still
+// starting up.
+func openConn(cfg *config.Config, connStr string, attempts int) (*sql.DB, error) {
+	db, err := sql.Open("postgres", connStr)
+	if err != nil {
+		return nil, fmt.Errorf("failed to connect to database: %w", err)
+	}
+
+	db.SetMaxOpenConns(cfg.DBMaxOpenConns)
+	db.SetMaxIdleConns(cfg.DBMaxIdleConns)
+	db.SetConnMaxLifetime(cfg.DBConnMaxLifetime)
+	db.SetConnMaxIdleTime(cfg.DBConnMaxIdleTime)
+
+	backoff := cfg.DBConnectBackoff
+	for attempt := 1; ; attempt++ {
+		ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		err = db.PingContext(ctx)
+		cancel()
+		if err == nil {
+			return db, nil
+		}
+		if attempt >= attempts {
+			return db, fmt.Errorf("database ping failed after %d attempts: %w", attempt, err)
+		}
+		log.Printf("Database ping failed (attempt %d/%d), retrying in %v: %v", attempt, attempts, backoff, err)
+		time.Sleep(backoff)
+		backoff = min(backoff*2, 30*time.Second)
+	}
 }
 
 func (d *Database) Close() error {
+	if d.replicas != nil {
+		d.replicas.Close()
+	}
 	return d.Conn.Close()
 }
 
+// reader returns the connection to serve a read of uid from; an empty uid
+// means the read is not tied to a single order.
+func (d *Database) reader(uid string) *sql.DB {
+	if d.replicas == nil {
+		return d.Conn
+	}
+	if conn := d.replicas.pick(uid); conn != nil {
+		return conn
+	}
+	return d.Conn
+}
+
+func (d *Database) markWritten(uid string) {
+	if d.replicas != nil {
+		d.replicas.markWritten(uid)
+	}
+}
+
+// retryOnPrimary reports whether a read that failed on conn should be retried
+// on the primary, marking the replica down if so. Only a replica that could
+// not be reached qualifies: a query error would fail on the primary as well,
+// and once ctx is done there is no time left to retry.
+func (d *Database) retryOnPrimary(ctx context.Context, conn *sql.DB, err error) bool {
+	if err == nil || conn == d.Conn || ctx.Err() != nil || !isConnError(err) {
+		return false
+	}
+	d.replicas.markDown(conn)
+	return true
+}
+
 func (d *Database) SaveOrder(order *Order) error {
+	return d.SaveOrderContext(context.Background(), order)
+}
+
+// SaveOrderContext inserts the order or replaces a stored one. Saving a
+// soft-deleted order restores it.
+func (d *Database) SaveOrderContext(ctx context.Context, order *Order) error {
+	return d.saveOrder(ctx, order, false)
+}
+
+// CreateOrderContext inserts the order and fails with ErrOrderExists if one
+// with the same UID is stored. A soft-deleted order is restored, as by
+// SaveOrderContext. The check is part of the insert, so of two concurrent
+// creates exactly one succeeds.
+func (d *Database) CreateOrderContext(ctx context.Context, order *Order) error {
+	return d.saveOrder(ctx, order, true)
+}
+
+func (d *Database) saveOrder(ctx context.Context, order *Order, create bool) error {
 	log.Printf("Saving order: %s", order.OrderUID)
 
-	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	ctx, cancel := context.WithTimeout(ctx, d.timeouts.Save)
 	defer cancel()
 
 	tx, err := d.Conn.BeginTx(ctx, nil)
@@ -55,11 +186,17 @@ func (d *Database) SaveOrder(order *Order) error {
 	}
 	defer tx.Rollback()
 
-	result, err := tx.ExecContext(ctx, `
+	// On create a live order is left alone, so no row comes back.
+	onlyDeleted := ""
+	if create {
+		onlyDeleted = "WHERE orders.deleted_at IS NOT NULL"
+	}
+	var inserted bool
+	err = tx.QueryRowContext(ctx, `
 		INSERT INTO orders (
 			order_uid, track_number, entry, locale, internal_signature,
-			customer_id, delivery_service, shardkey, sm_id, date_created, oof_shard
-		) VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11)
+			customer_id, delivery_service, shardkey, sm_id, date_created, oof_shard, status
+		) VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12)
 		ON CONFLICT (order_uid) DO UPDATE SET
 			track_number = EXCLUDED.track_number,
 			entry = EXCLUDED.entry,
@@ -70,16 +207,29 @@ func (d *Database) SaveOrder(order *Order) error {
 			shardkey = EXCLUDED.shardkey,
 			sm_id = EXCLUDED.sm_id,
 			date_created = EXCLUDED.date_created,
-			oof_shard = EXCLUDED.oof_shard`,
+			oof_shard = EXCLUDED.oof_shard,
+			deleted_at = NULL
+		`+onlyDeleted+`
+		RETURNING (xmax = 0), status`,
 		order.OrderUID, order.TrackNumber, order.Entry, order.Locale,
 		order.InternalSignature, order.CustomerID, order.DeliveryService,
-		order.Shardkey, order.SmID, order.DateCreated, order.OofShard)
+		order.Shardkey, order.SmID, order.DateCreated, order.OofShard,
+		DeriveStatus(order.Items)).Scan(&inserted, &order.Status)
+	if create && errors.Is(err, sql.ErrNoRows) {
+		return ErrOrderExists
+	}
 	if err != nil {
 		log.Printf("Error saving order: %v", err)
 		return fmt.Errorf("failed to save order: %w", err)
 	}
-	rows, _ := result.RowsAffected()
-	log.Printf("Orders affected: %d", rows)
+	log.Printf("Order %s inserted: %t", order.OrderUID, inserted)
+
+	if inserted {
+		err := insertStatusHistory(ctx, tx, &StatusChange{OrderUID: order.OrderUID, To: order.Status, Reason: "created"})
+		if err != nil {
+			return err
+		}
+	}
 
 	_, err = tx.ExecContext(ctx, `
 		INSERT INTO deliveries (
@@ -142,123 +292,162 @@ func (d *Database) SaveOrder(order *Order) error {
 		}
 	}
 
-	return tx.Commit()
+	eventType := EventOrderUpdated
+	if inserted {
+		eventType = EventOrderAccepted
+	}
+	if err := insertOutboxEvent(ctx, tx, OrderEvent{Type: eventType, OrderUID: order.OrderUID, Order: order}); err != nil {
+		return err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return err
+	}
+	d.markWritten(order.OrderUID)
+	return nil
 }
 
 func (d *Database) GetOrderByUID(uid string) (*Order, error) {
-	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	return d.GetOrderByUIDContext(context.Background(), uid)
+}
+
+func (d *Database) GetOrderByUIDContext(ctx context.Context, uid string) (*Order, error) {
+	ctx, cancel := context.WithTimeout(ctx, d.timeouts.Get)
 	defer cancel()
 
-	var exists bool
-	err := d.Conn.QueryRowContext(ctx,
-		"SELECT EXISTS(SELECT 1 FROM orders WHERE order_uid = $1)", uid).Scan(&exists)
-	if err != nil {
-		return nil, fmt.Errorf("failed to check order existence: %w", err)
+	return d.readOrder(ctx, uid)
+}
+
+func (d *Database) readOrder(ctx context.Context, uid string) (*Order, error) {
+	conn := d.reader(uid)
+	order, err := getOrder(ctx, conn, uid)
+	if d.retryOnPrimary(ctx, conn, err) {
+		return getOrder(ctx, d.Conn, uid)
 	}
-	if !exists {
-		return nil, fmt.Errorf("order not found")
+	return order, err
+}
+
+func getOrder(ctx context.Context, conn *sql.DB, uid string) (*Order, error) {
+	order, err := loadOrder(ctx, conn, uid, false)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, ErrOrderNotFound
 	}
+	return order, err
+}
 
+type queryer interface {
+	QueryRowContext(ctx context.Context, query string, args ...any) *sql.Row
+}
+
+// orderSelect reads orders with their delivery, payment and items in a single
+// statement, so each row is one consistent snapshot. Callers append the
+// WHERE clause.
+const orderSelect = `
+		SELECT o.order_uid, o.track_number, o.entry, o.locale, o.internal_signature,
+			o.customer_id, o.delivery_service, o.shardkey, o.sm_id, o.date_created, o.oof_shard,
+			o.status,
+			d.name, d.phone, d.zip, d.city, d.address, d.region, d.email,
+			p.transaction, p.request_id, p.currency, p.provider, p.amount, p.payment_dt,
+			p.bank, p.delivery_cost, p.goods_total, p.custom_fee,
+			COALESCE((
+				SELECT json_agg(json_build_object(
+					'chrt_id', i.chrt_id, 'track_number', i.track_number, 'price', i.price,
+					'rid', i.rid, 'name', i.name, 'sale', i.sale, 'size', i.size,
+					'total_price', i.total_price, 'nm_id', i.nm_id, 'brand', i.brand,
+					'status', i.status) ORDER BY i.id)
+				FROM items i
+				WHERE i.order_uid = o.order_uid
+			), '[]'::json)
+		FROM orders o
+		JOIN deliveries d ON d.order_uid = o.order_uid
+		JOIN payments p ON p.order_uid = o.order_uid`
+
+type rowScanner interface {
+	Scan(dest ...any) error
+}
+
+func scanOrder(row rowScanner) (*Order, error) {
 	order := &Order{}
+	var items []byte
 
-	err = d.Conn.QueryRowContext(ctx, `
-		SELECT order_uid, track_number, entry, locale, internal_signature,
-		customer_id, delivery_service, shardkey, sm_id, date_created, oof_shard
-		FROM orders
-		WHERE order_uid = $1`, uid).Scan(
+	err := row.Scan(
 		&order.OrderUID, &order.TrackNumber, &order.Entry, &order.Locale,
 		&order.InternalSignature, &order.CustomerID, &order.DeliveryService,
-		&order.Shardkey, &order.SmID, &order.DateCreated, &order.OofShard)
-	if err != nil {
-		return nil, fmt.Errorf("failed to get order: %w", err)
-	}
-
-	err = d.Conn.QueryRowContext(ctx, `
-		SELECT name, phone, zip, city, address, region, email
-		FROM deliveries
-		WHERE order_uid = $1`, uid).Scan(
+		&order.Shardkey, &order.SmID, &order.DateCreated, &order.OofShard,
+		&order.Status,
 		&order.Delivery.Name, &order.Delivery.Phone, &order.Delivery.Zip,
 		&order.Delivery.City, &order.Delivery.Address, &order.Delivery.Region,
-		&order.Delivery.Email)
-	if err != nil {
-		return nil, fmt.Errorf("failed to get delivery: %w", err)
-	}
-
-	err = d.Conn.QueryRowContext(ctx, `
-		SELECT transaction, request_id, currency, provider, amount, payment_dt,
-			bank, delivery_cost, goods_total, custom_fee
-		FROM payments
-		WHERE order_uid = $1`, uid).Scan(
+		&order.Delivery.Email,
 		&order.Payment.Transaction, &order.Payment.RequestID, &order.Payment.Currency,
 		&order.Payment.Provider, &order.Payment.Amount, &order.Payment.PaymentDt,
 		&order.Payment.Bank, &order.Payment.DeliveryCost, &order.Payment.GoodsTotal,
-		&order.Payment.CustomFee)
-	if err != nil {
-		return nil, fmt.Errorf("failed to get payment: %w", err)
-	}
-
-	rows, err := d.Conn.QueryContext(ctx, `
-		SELECT chrt_id, track_number, price, rid, name, sale, size,
-			total_price, nm_id, brand, status
-		FROM items
-		WHERE order_uid = $1`, uid)
+		&order.Payment.CustomFee,
+		&items)
 	if err != nil {
-		return nil, fmt.Errorf("failed to get items: %w", err)
+		return nil, err
 	}
-	defer rows.Close()
 
 	order.Items = []Item{}
-	for rows.Next() {
-		var item Item
-		err := rows.Scan(
-			&item.ChrtID, &item.TrackNumber, &item.Price, &item.Rid,
-			&item.Name, &item.Sale, &item.Size, &item.TotalPrice,
-			&item.NmID, &item.Brand, &item.Status)
-		if err != nil {
-			return nil, fmt.Errorf("failed to scan item: %w", err)
-		}
-		order.Items = append(order.Items, item)
+	if err := json.Unmarshal(items, &order.Items); err != nil {
+		return nil, fmt.Errorf("failed to decode items: %w", err)
 	}
 
 	return order, nil
 }
 
+// loadOrder reads one order; see orderSelect.
+func loadOrder(ctx context.Context, q queryer, uid string, includeDeleted bool) (*Order, error) {
+	order, err := scanOrder(q.QueryRowContext(ctx, orderSelect+`
+		WHERE o.order_uid = $1 AND ($2 OR o.deleted_at IS NULL)`, uid, includeDeleted))
+	if err != nil {
+		return nil, fmt.Errorf("failed to get order: %w", err)
+	}
+	return order, nil
+}
+
 func (d *Database) GetAllOrders() ([]Order, error) {
-	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	return d.GetAllOrdersContext(context.Background())
+}
+
+// GetAllOrdersContext loads every order that is not deleted, oldest first, in
+// one statement.
+func (d *Database) GetAllOrdersContext(ctx context.Context) ([]Order, error) {
+	ctx, cancel := context.WithTimeout(ctx, d.timeouts.List)
 	defer cancel()
 
-	rows, err := d.Conn.QueryContext(ctx, "SELECT order_uid FROM orders")
+	return d.queryOrders(ctx, orderSelect+`
+		WHERE o.deleted_at IS NULL
+		ORDER BY o.date_created, o.order_uid`)
+}
+
+// queryOrders runs an orderSelect statement on a replica, falling back to the
+// primary, and scans every row.
+func (d *Database) queryOrders(ctx context.Context, query string, args ...any) ([]Order, error) {
+	conn := d.reader("")
+	rows, err := conn.QueryContext(ctx, query, args...)
+	if d.retryOnPrimary(ctx, conn, err) {
+		rows, err = d.Conn.QueryContext(ctx, query, args...)
+	}
 	if err != nil {
-		if err == sql.ErrNoRows {
-			return []Order{}, nil
-		}
 		return nil, fmt.Errorf("failed to get orders: %w", err)
 	}
 	defer rows.Close()
 
-	var orders []Order
+	orders := []Order{}
 	for rows.Next() {
-		var uid string
-		if err := rows.Scan(&uid); err != nil {
-			return nil, fmt.Errorf("failed to scan order uid: %w", err)
-		}
-
-		order, err := d.GetOrderByUID(uid)
+		order, err := scanOrder(rows)
 		if err != nil {
-			log.Printf("Failed to load order %s: %v", uid, err)
-			continue
+			return nil, fmt.Errorf("failed to scan order: %w", err)
 		}
 		orders = append(orders, *order)
 	}
-
-	if len(orders) == 0 {
-		return []Order{}, nil
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("failed to read orders: %w", err)
 	}
-
 	return orders, nil
 }
 
-func (d *Database) LoadTestData() error {
+func LoadTestData(s Store) error {
 	testData := `{
 		"order_uid": "b563feb7b2b84b6test",
 		"track_number": "WBILMTESTTRACK",
@@ -314,11 +503,63 @@ func (d *Database) LoadTestData() error {
 		return fmt.Errorf("failed to unmarshal test data: %w", err)
 	}
 
-	return d.SaveOrder(&order)
+	return s.SaveOrderContext(context.Background(), &order)
 }
 
 func (d *Database) OrderExists(uid string) (bool, error) {
+	return d.OrderExistsContext(context.Background(), uid)
+}
+
+func (d *Database) OrderExistsContext(ctx context.Context, uid string) (bool, error) {
+	ctx, cancel := context.WithTimeout(ctx, d.timeouts.Exists)
+	defer cancel()
+
+	var exists bool
+	err := d.Conn.QueryRowContext(ctx, "SELECT EXISTS(SELECT 1 FROM orders WHERE order_uid = $1 AND deleted_at IS NULL)", uid).Scan(&exists)
+	return exists, err
+}
+
+// HoldsOrderContext reports whether the database has a row for uid, deleted or
+// not. The router uses it to find the shard an order lives on.
+func (d *Database) HoldsOrderContext(ctx context.Context, uid string) (bool, error) {
+	ctx, cancel := context.WithTimeout(ctx, d.timeouts.Exists)
+	defer cancel()
+
 	var exists bool
-	err := d.Conn.QueryRow("SELECT EXISTS(SELECT 1 FROM orders WHERE order_uid = $1)", uid).Scan(&exists)
+	err := d.Conn.QueryRowContext(ctx, "SELECT EXISTS(SELECT 1 FROM orders WHERE order_uid = $1)", uid).Scan(&exists)
 	return exists, err
 }
+
+func (d *Database) DeleteOrder(uid string) error {
+	return d.DeleteOrderContext(context.Background(), uid)
+}
+
+func (d *Database) DeleteOrderContext(ctx context.Context, uid string) error {
+	ctx, cancel := context.WithTimeout(ctx, d.timeouts.Delete)
+	defer cancel()
+
+	tx, err := d.Conn.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	result, err := tx.ExecContext(ctx,
+		"UPDATE orders SET deleted_at = NOW() WHERE order_uid = $1 AND deleted_at IS NULL", uid)
+	if err != nil {
+		return fmt.Errorf("failed to delete order: %w", err)
+	}
+	if rows, _ := result.RowsAffected(); rows == 0 {
+		return ErrOrderNotFound
+	}
+
+	if err := insertOutboxEvent(ctx, tx, OrderEvent{Type: EventOrderDeleted, OrderUID: uid}); err != nil {
+		return err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return err
+	}
+	d.markWritten(uid)
+	return nil
+}
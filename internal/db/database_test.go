@@ -0,0 +1,310 @@
+package db
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"order-service/config"
+	"os"
+	"reflect"
+	"sort"
+	"testing"
+	"time"
+)
+
+// openTestDB connects to TEST_DATABASE_URL, which must point at a database
+// initialized with migrations/init.sql, and empties its tables. Tests and
+// benchmarks that need Postgres are skipped when it is not set.
+func openTestDB(tb testing.TB) *Database {
+	tb.Helper()
+	dsn := os.Getenv("TEST_DATABASE_URL")
+	if dsn == "" {
+		tb.Skip("TEST_DATABASE_URL is not set")
+	}
+	cfg := config.Load()
+	cfg.DBConnectAttempts = 1
+	d, err := Open(cfg, dsn)
+	if err != nil {
+		tb.Fatalf("open test database: %v", err)
+	}
+	tb.Cleanup(func() { d.Close() })
+
+	_, err = d.Conn.Exec(`TRUNCATE orders, deliveries, payments, items, outbox, order_status_history RESTART IDENTITY CASCADE`)
+	if err != nil {
+		tb.Fatalf("truncate test database: %v", err)
+	}
+	return d
+}
+
+// testOrder returns a valid order shaped like model.json.
+func testOrder(uid string) *Order {
+	return &Order{
+		OrderUID:    uid,
+		TrackNumber: "WBILMTESTTRACK",
+		Entry:       "WBIL",
+		Delivery: Delivery{
+			Name:    "Test Testov",
+			Phone:   "+9720000000",
+			Zip:     "2639809",
+			City:    "Kiryat Mozkin",
+			Address: "Ploshad Mira 15",
+			Region:  "Kraiot",
+			Email:   "test@gmail.com",
+		},
+		Payment: Payment{
+			Transaction:  uid,
+			Currency:     "USD",
+			Provider:     "wbpay",
+			Amount:       1817,
+			PaymentDt:    1637907727,
+			Bank:         "alpha",
+			DeliveryCost: 1500,
+			GoodsTotal:   317,
+		},
+		Items: []Item{{
+			ChrtID:      9934930,
+			TrackNumber: "WBILMTESTTRACK",
+			Price:       453,
+			Rid:         "ab4219087a764ae0btest",
+			Name:        "Mascaras",
+			Sale:        30,
+			Size:        "0",
+			TotalPrice:  317,
+			NmID:        2389212,
+			Brand:       "Vivienne Sabo",
+			Status:      202,
+		}},
+		Locale:          "en",
+		CustomerID:      "test",
+		DeliveryService: "meest",
+		Shardkey:        "9",
+		SmID:            99,
+		DateCreated:     time.Date(2021, 11, 26, 6, 22, 19, 0, time.UTC),
+		OofShard:        "1",
+	}
+}
+
+func saveTestOrders(tb testing.TB, store Store, orders ...*Order) {
+	tb.Helper()
+	for _, o := range orders {
+		if err := store.SaveOrderContext(context.Background(), o); err != nil {
+			tb.Fatalf("save order %s: %v", o.OrderUID, err)
+		}
+	}
+}
+
+// testSoftDelete checks that a deleted order is hidden from reads and
+// existence checks and that saving it again restores it.
+func testSoftDelete(t *testing.T, store Store) {
+	ctx := context.Background()
+	saveTestOrders(t, store, testOrder("soft"))
+	if err := store.DeleteOrderContext(ctx, "soft"); err != nil {
+		t.Fatalf("delete: %v", err)
+	}
+
+	if exists, err := store.OrderExistsContext(ctx, "soft"); err != nil || exists {
+		t.Errorf("OrderExists after delete = %t, %v; want false", exists, err)
+	}
+	if _, err := store.GetOrderByUIDContext(ctx, "soft"); !errors.Is(err, ErrOrderNotFound) {
+		t.Errorf("GetOrderByUID after delete: err = %v, want ErrOrderNotFound", err)
+	}
+	if err := store.DeleteOrderContext(ctx, "soft"); !errors.Is(err, ErrOrderNotFound) {
+		t.Errorf("second delete: err = %v, want ErrOrderNotFound", err)
+	}
+
+	saveTestOrders(t, store, testOrder("soft"))
+	if exists, err := store.OrderExistsContext(ctx, "soft"); err != nil || !exists {
+		t.Errorf("OrderExists after save = %t, %v; want true", exists, err)
+	}
+	if _, err := store.GetOrderByUIDContext(ctx, "soft"); err != nil {
+		t.Errorf("GetOrderByUID after save: %v", err)
+	}
+}
+
+func TestDatabaseSoftDelete(t *testing.T) {
+	testSoftDelete(t, openTestDB(t))
+}
+
+// testCreateOrder checks that creating an order fails once it exists, also
+// when several creates race, and succeeds again after it is deleted.
+func testCreateOrder(t *testing.T, store Store) {
+	ctx := context.Background()
+	errs := make(chan error, 8)
+	for range cap(errs) {
+		go func() { errs <- store.CreateOrderContext(ctx, testOrder("create")) }()
+	}
+	created := 0
+	for range cap(errs) {
+		switch err := <-errs; {
+		case err == nil:
+			created++
+		case !errors.Is(err, ErrOrderExists):
+			t.Errorf("create: %v", err)
+		}
+	}
+	if created != 1 {
+		t.Errorf("%d concurrent creates succeeded, want 1", created)
+	}
+
+	if err := store.DeleteOrderContext(ctx, "create"); err != nil {
+		t.Fatalf("delete: %v", err)
+	}
+	if err := store.CreateOrderContext(ctx, testOrder("create")); err != nil {
+		t.Errorf("create after delete: %v", err)
+	}
+	if _, err := store.GetOrderByUIDContext(ctx, "create"); err != nil {
+		t.Errorf("get after create: %v", err)
+	}
+}
+
+// testListOrderRefsOrder pages through orders created at the same moment whose
+// uids sort differently under a linguistic collation than byte by byte, and
+// checks every page follows OrderRef.Before with nothing skipped or repeated.
+func testListOrderRefsOrder(t *testing.T, store Store) {
+	ctx := context.Background()
+	created := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	uids := []string{"a-1", "A_2", "a3", "B1", "b-2", "_c", "Z", "z"}
+	for i, uid := range uids {
+		o := testOrder(uid)
+		o.Shardkey = fmt.Sprint(i)
+		o.DateCreated = created
+		saveTestOrders(t, store, o)
+	}
+	want := make([]OrderRef, len(uids))
+	for i, uid := range uids {
+		want[i] = OrderRef{UID: uid, DateCreated: created}
+	}
+	sort.Slice(want, func(i, j int) bool { return want[i].Before(want[j]) })
+
+	var got []string
+	filter := OrderFilter{Limit: 3}
+	for {
+		refs, err := store.ListOrderRefsContext(ctx, filter)
+		if err != nil {
+			t.Fatalf("list: %v", err)
+		}
+		for _, ref := range refs {
+			got = append(got, ref.UID)
+		}
+		if len(refs) < filter.Limit {
+			break
+		}
+		filter.After = &refs[len(refs)-1]
+	}
+	var wantUIDs []string
+	for _, ref := range want {
+		wantUIDs = append(wantUIDs, ref.UID)
+	}
+	if fmt.Sprint(got) != fmt.Sprint(wantUIDs) {
+		t.Errorf("listed %v, want %v", got, wantUIDs)
+	}
+}
+
+func TestDatabaseListOrderRefsOrder(t *testing.T) {
+	testListOrderRefsOrder(t, openTestDB(t))
+}
+
+func TestRouterListOrderRefsOrder(t *testing.T) {
+	testListOrderRefsOrder(t, newTestRouter(newCountingShards(3)))
+}
+
+func TestDatabaseCreateOrder(t *testing.T) {
+	testCreateOrder(t, openTestDB(t))
+}
+
+func TestDatabaseGetAllOrders(t *testing.T) {
+	d := openTestDB(t)
+	ctx := context.Background()
+	for i := range 3 {
+		o := testOrder(fmt.Sprintf("o%d", i))
+		o.DateCreated = o.DateCreated.Add(time.Duration(-i) * time.Hour)
+		o.Items = append(o.Items, o.Items[0])
+		saveTestOrders(t, d, o)
+	}
+	if err := d.DeleteOrderContext(ctx, "o1"); err != nil {
+		t.Fatalf("delete: %v", err)
+	}
+
+	orders, err := d.GetAllOrdersContext(ctx)
+	if err != nil {
+		t.Fatalf("GetAllOrders: %v", err)
+	}
+	var uids []string
+	for _, o := range orders {
+		uids = append(uids, o.OrderUID)
+		if len(o.Items) != 2 || o.Delivery.Email == "" || o.Payment.Amount == 0 {
+			t.Errorf("order %s loaded incompletely: %+v", o.OrderUID, o)
+		}
+	}
+	if fmt.Sprint(uids) != "[o2 o0]" {
+		t.Errorf("orders = %v, want [o2 o0]", uids)
+	}
+}
+
+func TestDatabaseLegacyGetOrderMatches(t *testing.T) {
+	d := openTestDB(t)
+	saveTestOrders(t, d, testOrder("legacy"))
+	ctx := context.Background()
+
+	want, err := d.GetOrderByUIDContext(ctx, "legacy")
+	if err != nil {
+		t.Fatalf("get: %v", err)
+	}
+	got, err := d.LegacyGetOrderByUIDContext(ctx, "legacy")
+	if err != nil {
+		t.Fatalf("legacy get: %v", err)
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("legacy loader = %+v, want %+v", got, want)
+	}
+	if _, err := d.LegacyGetOrderByUIDContext(ctx, "missing"); !errors.Is(err, ErrOrderNotFound) {
+		t.Errorf("legacy get missing: err = %v, want ErrOrderNotFound", err)
+	}
+}
+
+// BenchmarkGetOrder compares the single-statement lookup with the five-query
+// loader it replaced.
+func BenchmarkGetOrder(b *testing.B) {
+	d := openTestDB(b)
+	saveTestOrders(b, d, testOrder("bench"))
+	ctx := context.Background()
+
+	loaders := []struct {
+		name string
+		load func(context.Context, string) (*Order, error)
+	}{
+		{"five queries", d.LegacyGetOrderByUIDContext},
+		{"single statement", d.GetOrderByUIDContext},
+	}
+	for _, l := range loaders {
+		b.Run(l.name, func(b *testing.B) {
+			for b.Loop() {
+				if _, err := l.load(ctx, "bench"); err != nil {
+					b.Fatal(err)
+				}
+			}
+		})
+	}
+}
+
+func BenchmarkGetAllOrders(b *testing.B) {
+	for _, n := range []int{10, 100, 1000} {
+		b.Run(fmt.Sprintf("orders=%d", n), func(b *testing.B) {
+			d := openTestDB(b)
+			for i := range n {
+				saveTestOrders(b, d, testOrder(fmt.Sprintf("bench-%d", i)))
+			}
+			ctx := context.Background()
+
+			for b.Loop() {
+				orders, err := d.GetAllOrdersContext(ctx)
+				if err != nil {
+					b.Fatal(err)
+				}
+				if len(orders) != n {
+					b.Fatalf("got %d orders, want %d", len(orders), n)
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,87 @@
+package db
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+)
+
+// DeliveryPatch changes some delivery fields of an order; nil fields are
+// left as they are.
+type DeliveryPatch struct {
+	Name    *string `json:"name,omitempty"`
+	Phone   *string `json:"phone,omitempty"`
+	Zip     *string `json:"zip,omitempty"`
+	City    *string `json:"city,omitempty"`
+	Address *string `json:"address,omitempty"`
+	Region  *string `json:"region,omitempty"`
+	Email   *string `json:"email,omitempty"`
+}
+
+func (p *DeliveryPatch) Apply(d *Delivery) {
+	set := func(dst *string, src *string) {
+		if src != nil {
+			*dst = *src
+		}
+	}
+	set(&d.Name, p.Name)
+	set(&d.Phone, p.Phone)
+	set(&d.Zip, p.Zip)
+	set(&d.City, p.City)
+	set(&d.Address, p.Address)
+	set(&d.Region, p.Region)
+	set(&d.Email, p.Email)
+}
+
+// UpdateDeliveryContext applies a delivery patch to a stored order and
+// publishes an order.updated event with the resulting order.
+func (d *Database) UpdateDeliveryContext(ctx context.Context, uid string, patch DeliveryPatch) (*Order, error) {
+	ctx, cancel := context.WithTimeout(ctx, d.timeouts.Save)
+	defer cancel()
+
+	tx, err := d.Conn.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	var locked string
+	err = tx.QueryRowContext(ctx,
+		"SELECT order_uid FROM orders WHERE order_uid = $1 AND deleted_at IS NULL FOR UPDATE", uid).Scan(&locked)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, ErrOrderNotFound
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to lock order: %w", err)
+	}
+
+	_, err = tx.ExecContext(ctx, `
+		UPDATE deliveries SET
+			name = COALESCE($2, name),
+			phone = COALESCE($3, phone),
+			zip = COALESCE($4, zip),
+			city = COALESCE($5, city),
+			address = COALESCE($6, address),
+			region = COALESCE($7, region),
+			email = COALESCE($8, email)
+		WHERE order_uid = $1`,
+		uid, patch.Name, patch.Phone, patch.Zip, patch.City, patch.Address, patch.Region, patch.Email)
+	if err != nil {
+		return nil, fmt.Errorf("failed to update delivery: %w", err)
+	}
+
+	order, err := loadOrder(ctx, tx, uid, false)
+	if err != nil {
+		return nil, err
+	}
+	if err := insertOutboxEvent(ctx, tx, OrderEvent{Type: EventOrderUpdated, OrderUID: uid, Order: order}); err != nil {
+		return nil, err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return nil, err
+	}
+	d.markWritten(uid)
+	return order, nil
+}
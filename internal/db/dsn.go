@@ -0,0 +1,146 @@
+package db
+
+import (
+	"fmt"
+	"net/url"
+	"order-service/config"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+var passwordPattern = regexp.MustCompile(`(password\s*=\s*)('(?:[^'\\]|\\.)*'|\S+)`)
+
+// BuildDSN returns the primary connection string: DB_URL as given, or a
+// key=value DSN assembled from the DB_HOST/DB_PORT/... settings. The assembled
+// DSN disables TLS unless DB_SSLMODE says otherwise; DB_URL and the shard and
+// replica DSNs keep the driver default (require) unless they or DB_SSLMODE
+// set a mode.
+func BuildDSN(cfg *config.Config) string {
+	if cfg.DBURL != "" {
+		return cfg.DBURL
+	}
+	dsn := fmt.Sprintf("host=%s port=%s user=%s password=%s dbname=%s",
+		quoteDSNValue(cfg.DBHost), quoteDSNValue(cfg.DBPort), quoteDSNValue(cfg.DBUser),
+		quoteDSNValue(cfg.DBPassword), quoteDSNValue(cfg.DBName))
+	if cfg.DBSSLMode == "" {
+		dsn += " sslmode=disable"
+	}
+	return dsn
+}
+
+// withConnParams adds the TLS and session settings from cfg to dsn unless the
+// DSN already sets them itself. Settings left empty in cfg are not added.
+func withConnParams(dsn string, cfg *config.Config) string {
+	var params [][2]string
+	add := func(key, value string) {
+		if value != "" {
+			params = append(params, [2]string{key, value})
+		}
+	}
+	add("sslmode", cfg.DBSSLMode)
+	add("sslrootcert", cfg.DBSSLRootCert)
+	add("sslcert", cfg.DBSSLCert)
+	add("sslkey", cfg.DBSSLKey)
+	if cfg.DBStatementTimeout > 0 {
+		add("statement_timeout", strconv.FormatInt(cfg.DBStatementTimeout.Milliseconds(), 10))
+	}
+
+	if isURL(dsn) {
+		u, err := url.Parse(dsn)
+		if err != nil {
+			return dsn
+		}
+		q := u.Query()
+		for _, p := range params {
+			if !q.Has(p[0]) {
+				q.Set(p[0], p[1])
+			}
+		}
+		u.RawQuery = q.Encode()
+		return u.String()
+	}
+
+	for _, p := range params {
+		if !hasDSNKey(dsn, p[0]) {
+			dsn += " " + p[0] + "=" + quoteDSNValue(p[1])
+		}
+	}
+	return dsn
+}
+
+// RedactDSN hides the password in a URL or key=value connection string so it
+// can be logged.
+func RedactDSN(dsn string) string {
+	if isURL(dsn) {
+		u, err := url.Parse(dsn)
+		if err != nil {
+			return "<invalid database url>"
+		}
+		q := u.Query()
+		if q.Has("password") {
+			q.Set("password", "xxxxx")
+			u.RawQuery = q.Encode()
+		}
+		return u.Redacted()
+	}
+	return passwordPattern.ReplaceAllString(dsn, "${1}xxxxx")
+}
+
+// hasDSNKey reports whether the key=value DSN sets key. Values may be quoted,
+// so a key inside a quoted password does not count.
+func hasDSNKey(dsn, key string) bool {
+	i := 0
+	for i < len(dsn) {
+		for i < len(dsn) && isDSNSpace(dsn[i]) {
+			i++
+		}
+		start := i
+		for i < len(dsn) && dsn[i] != '=' && !isDSNSpace(dsn[i]) {
+			i++
+		}
+		name := dsn[start:i]
+		for i < len(dsn) && isDSNSpace(dsn[i]) {
+			i++
+		}
+		if i < len(dsn) && dsn[i] == '=' {
+			i++
+		}
+		for i < len(dsn) && isDSNSpace(dsn[i]) {
+			i++
+		}
+		if i < len(dsn) && dsn[i] == '\'' {
+			for i++; i < len(dsn) && dsn[i] != '\''; i++ {
+				if dsn[i] == '\\' {
+					i++
+				}
+			}
+			i++
+		} else {
+			for i < len(dsn) && !isDSNSpace(dsn[i]) {
+				i++
+			}
+		}
+		if name == key {
+			return true
+		}
+	}
+	return false
+}
+
+func isDSNSpace(b byte) bool {
+	return b == ' ' || b == '\t' || b == '\n' || b == '\r'
+}
+
+func isURL(dsn string) bool {
+	return strings.HasPrefix(dsn, "postgres://") || strings.HasPrefix(dsn, "postgresql://")
+}
+
+func quoteDSNValue(v string) string {
+	if v != "" && !strings.ContainsAny(v, ` '\`) {
+		return v
+	}
+	v = strings.ReplaceAll(v, `\`, `\\`)
+	v = strings.ReplaceAll(v, `'`, `\'`)
+	return "'" + v + "'"
+}
@@ -0,0 +1,99 @@
+package db
+
+import (
+	"order-service/config"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestBuildDSN(t *testing.T) {
+	base := config.Config{DBHost: "db", DBPort: "5432", DBUser: "app", DBPassword: "s3cret", DBName: "orders"}
+	tests := []struct {
+		name string
+		edit func(*config.Config)
+		want string
+	}{
+		{"assembled", func(*config.Config) {},
+			"host=db port=5432 user=app password=s3cret dbname=orders sslmode=disable"},
+		{"quoted password", func(c *config.Config) { c.DBPassword = `it's a pass\word` },
+			`host=db port=5432 user=app password='it\'s a pass\\word' dbname=orders sslmode=disable`},
+		{"empty password", func(c *config.Config) { c.DBPassword = "" },
+			"host=db port=5432 user=app password='' dbname=orders sslmode=disable"},
+		{"explicit sslmode", func(c *config.Config) { c.DBSSLMode = "require" },
+			"host=db port=5432 user=app password=s3cret dbname=orders"},
+		{"url", func(c *config.Config) { c.DBURL = "postgres://app:pw@db/orders" },
+			"postgres://app:pw@db/orders"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg := base
+			tt.edit(&cfg)
+			if got := BuildDSN(&cfg); got != tt.want {
+				t.Errorf("BuildDSN = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestWithConnParams(t *testing.T) {
+	tests := []struct {
+		name string
+		dsn  string
+		cfg  config.Config
+		want string
+	}{
+		{"url keeps the driver default", "postgres://app:pw@db/orders", config.Config{},
+			"postgres://app:pw@db/orders"},
+		{"url gets explicit settings", "postgres://app:pw@db/orders",
+			config.Config{DBSSLMode: "verify-full", DBSSLRootCert: "/ca.pem", DBStatementTimeout: 2 * time.Second},
+			"postgres://app:pw@db/orders?sslmode=verify-full&sslrootcert=%2Fca.pem&statement_timeout=2000"},
+		{"url parameters win", "postgres://app:pw@db/orders?sslmode=require&application_name=x",
+			config.Config{DBSSLMode: "disable"},
+			"postgres://app:pw@db/orders?application_name=x&sslmode=require"},
+		{"key/value keeps the driver default", "host=db dbname=orders", config.Config{},
+			"host=db dbname=orders"},
+		{"key/value gets explicit settings", "host=db dbname=orders",
+			config.Config{DBSSLMode: "verify-ca", DBSSLCert: "/my cert.pem"},
+			"host=db dbname=orders sslmode=verify-ca sslcert='/my cert.pem'"},
+		{"key/value parameters win", "host=db sslmode = require\tdbname=orders",
+			config.Config{DBSSLMode: "disable"},
+			"host=db sslmode = require\tdbname=orders"},
+		{"key inside a quoted password", `host=db password='x sslmode=disable' dbname=orders`,
+			config.Config{DBSSLMode: "require"},
+			`host=db password='x sslmode=disable' dbname=orders sslmode=require`},
+		{"escaped quote in a password", `password='a\' sslmode=off' host=db`,
+			config.Config{DBSSLMode: "require"},
+			`password='a\' sslmode=off' host=db sslmode=require`},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := withConnParams(tt.dsn, &tt.cfg); got != tt.want {
+				t.Errorf("withConnParams = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestRedactDSN(t *testing.T) {
+	tests := []struct {
+		dsn, want string
+	}{
+		{"postgres://app:s3cret@db:5432/orders?sslmode=require", "postgres://app:xxxxx@db:5432/orders?sslmode=require"},
+		{"postgres://db/orders?password=s3cret&user=app", "postgres://db/orders?password=xxxxx&user=app"},
+		{"postgresql://app@db/orders", "postgresql://app@db/orders"},
+		{"host=db password=s3cret dbname=orders", "host=db password=xxxxx dbname=orders"},
+		{"host=db password = s3cret", "host=db password = xxxxx"},
+		{`host=db password='it\'s secret' dbname=orders`, "host=db password=xxxxx dbname=orders"},
+		{"postgres://app:pw@db:bad/", "<invalid database url>"},
+	}
+	for _, tt := range tests {
+		got := RedactDSN(tt.dsn)
+		if got != tt.want {
+			t.Errorf("RedactDSN(%q) = %q, want %q", tt.dsn, got, tt.want)
+		}
+		if strings.Contains(got, "secret") {
+			t.Errorf("RedactDSN(%q) leaks the password: %q", tt.dsn, got)
+		}
+	}
+}
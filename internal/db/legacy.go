@@ -0,0 +1,83 @@
+package db
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+)
+
+// LegacyGetOrderByUIDContext loads an order the way GetOrderByUIDContext did
+// before it became a single statement: an existence check and then one query
+// each for the order, its delivery, its payment and its items. It is kept
+// only as the baseline of BenchmarkGetOrder and cmd/order-bench.
+func (d *Database) LegacyGetOrderByUIDContext(ctx context.Context, uid string) (*Order, error) {
+	ctx, cancel := context.WithTimeout(ctx, d.timeouts.Get)
+	defer cancel()
+
+	exists, err := d.OrderExistsContext(ctx, uid)
+	if err != nil {
+		return nil, fmt.Errorf("failed to check order: %w", err)
+	}
+	if !exists {
+		return nil, ErrOrderNotFound
+	}
+
+	var o Order
+	err = d.Conn.QueryRowContext(ctx, `
+		SELECT order_uid, track_number, entry, locale, internal_signature,
+		customer_id, delivery_service, shardkey, sm_id, date_created, oof_shard, status
+		FROM orders WHERE order_uid = $1`, uid).Scan(
+		&o.OrderUID, &o.TrackNumber, &o.Entry, &o.Locale, &o.InternalSignature,
+		&o.CustomerID, &o.DeliveryService, &o.Shardkey, &o.SmID, &o.DateCreated, &o.OofShard, &o.Status)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, ErrOrderNotFound
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to get order: %w", err)
+	}
+
+	err = d.Conn.QueryRowContext(ctx, `
+		SELECT name, phone, zip, city, address, region, email
+		FROM deliveries WHERE order_uid = $1`, uid).Scan(
+		&o.Delivery.Name, &o.Delivery.Phone, &o.Delivery.Zip, &o.Delivery.City,
+		&o.Delivery.Address, &o.Delivery.Region, &o.Delivery.Email)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get delivery: %w", err)
+	}
+
+	err = d.Conn.QueryRowContext(ctx, `
+		SELECT transaction, request_id, currency, provider, amount, payment_dt,
+			bank, delivery_cost, goods_total, custom_fee
+		FROM payments WHERE order_uid = $1`, uid).Scan(
+		&o.Payment.Transaction, &o.Payment.RequestID, &o.Payment.Currency,
+		&o.Payment.Provider, &o.Payment.Amount, &o.Payment.PaymentDt, &o.Payment.Bank,
+		&o.Payment.DeliveryCost, &o.Payment.GoodsTotal, &o.Payment.CustomFee)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get payment: %w", err)
+	}
+
+	rows, err := d.Conn.QueryContext(ctx, `
+		SELECT chrt_id, track_number, price, rid, name, sale, size,
+			total_price, nm_id, brand, status
+		FROM items WHERE order_uid = $1 ORDER BY id`, uid)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get items: %w", err)
+	}
+	defer rows.Close()
+
+	o.Items = []Item{}
+	for rows.Next() {
+		var item Item
+		if err := rows.Scan(&item.ChrtID, &item.TrackNumber, &item.Price, &item.Rid,
+			&item.Name, &item.Sale, &item.Size, &item.TotalPrice,
+			&item.NmID, &item.Brand, &item.Status); err != nil {
+			return nil, fmt.Errorf("failed to scan item: %w", err)
+		}
+		o.Items = append(o.Items, item)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("failed to get items: %w", err)
+	}
+	return &o, nil
+}
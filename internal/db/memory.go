@@ -0,0 +1,231 @@
+package db
+
+import (
+	"context"
+	"sort"
+	"sync"
+	"time"
+)
+
+// MemoryStore is a Store kept entirely in process memory. It is meant for
+// local runs and for exercising the shard router without Postgres.
+type MemoryStore struct {
+	mu      sync.RWMutex
+	orders  map[string]Order
+	deleted map[string]time.Time
+	history map[string][]StatusChange
+}
+
+func NewMemoryStore() *MemoryStore {
+	return &MemoryStore{
+		orders:  make(map[string]Order),
+		deleted: make(map[string]time.Time),
+		history: make(map[string][]StatusChange),
+	}
+}
+
+func copyOrder(order *Order) Order {
+	c := *order
+	c.Items = append([]Item(nil), order.Items...)
+	return c
+}
+
+func (m *MemoryStore) SaveOrderContext(ctx context.Context, order *Order) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.saveLocked(order)
+	return nil
+}
+
+func (m *MemoryStore) saveLocked(order *Order) {
+	if existing, ok := m.orders[order.OrderUID]; ok {
+		order.Status = existing.Status
+	} else {
+		order.Status = DeriveStatus(order.Items)
+		m.history[order.OrderUID] = []StatusChange{{
+			OrderUID: order.OrderUID, To: order.Status, Reason: "created", ChangedAt: time.Now().UTC(),
+		}}
+	}
+	// Saving a deleted order restores it, as the upsert in Database does.
+	delete(m.deleted, order.OrderUID)
+	m.orders[order.OrderUID] = copyOrder(order)
+}
+
+func (m *MemoryStore) CreateOrderContext(ctx context.Context, order *Order) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if _, ok := m.orders[order.OrderUID]; ok {
+		if _, deleted := m.deleted[order.OrderUID]; !deleted {
+			return ErrOrderExists
+		}
+	}
+	m.saveLocked(order)
+	return nil
+}
+
+func (m *MemoryStore) GetOrderByUIDContext(ctx context.Context, uid string) (*Order, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	order, ok := m.orders[uid]
+	if !ok {
+		return nil, ErrOrderNotFound
+	}
+	if _, deleted := m.deleted[uid]; deleted {
+		return nil, ErrOrderNotFound
+	}
+	c := copyOrder(&order)
+	return &c, nil
+}
+
+func (m *MemoryStore) GetAllOrdersContext(ctx context.Context) ([]Order, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	orders := make([]Order, 0, len(m.orders))
+	for uid, order := range m.orders {
+		if _, deleted := m.deleted[uid]; deleted {
+			continue
+		}
+		orders = append(orders, copyOrder(&order))
+	}
+	sort.Slice(orders, func(i, j int) bool {
+		return orders[i].DateCreated.Before(orders[j].DateCreated)
+	})
+	return orders, nil
+}
+
+func (m *MemoryStore) DeleteOrderContext(ctx context.Context, uid string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if _, ok := m.orders[uid]; !ok {
+		return ErrOrderNotFound
+	}
+	if _, deleted := m.deleted[uid]; deleted {
+		return ErrOrderNotFound
+	}
+	m.deleted[uid] = time.Now()
+	return nil
+}
+
+func (m *MemoryStore) OrderExistsContext(ctx context.Context, uid string) (bool, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	_, ok := m.orders[uid]
+	_, deleted := m.deleted[uid]
+	return ok && !deleted, nil
+}
+
+func (m *MemoryStore) HoldsOrderContext(ctx context.Context, uid string) (bool, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	_, ok := m.orders[uid]
+	return ok, nil
+}
+
+func (m *MemoryStore) UpdateOrderStatusContext(ctx context.Context, uid string, to OrderStatus, reason string) (*StatusChange, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	order, ok := m.orders[uid]
+	if _, deleted := m.deleted[uid]; !ok || deleted {
+		return nil, ErrOrderNotFound
+	}
+	if err := checkTransition(order.Status, to); err != nil {
+		return nil, err
+	}
+	change := StatusChange{OrderUID: uid, From: order.Status, To: to, Reason: reason, ChangedAt: time.Now().UTC()}
+	if order.Status == to {
+		return &change, nil
+	}
+
+	order.Status = to
+	m.orders[uid] = order
+	m.history[uid] = append(m.history[uid], change)
+	return &change, nil
+}
+
+func (m *MemoryStore) GetStatusHistoryContext(ctx context.Context, uid string) ([]StatusChange, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	_, ok := m.orders[uid]
+	if _, deleted := m.deleted[uid]; !ok || deleted {
+		return nil, ErrOrderNotFound
+	}
+	return append([]StatusChange{}, m.history[uid]...), nil
+}
+
+func (m *MemoryStore) UpdateDeliveryContext(ctx context.Context, uid string, patch DeliveryPatch) (*Order, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	order, ok := m.orders[uid]
+	if _, deleted := m.deleted[uid]; !ok || deleted {
+		return nil, ErrOrderNotFound
+	}
+	patch.Apply(&order.Delivery)
+	m.orders[uid] = order
+	c := copyOrder(&order)
+	return &c, nil
+}
+
+func (m *MemoryStore) ListOrderRefsContext(ctx context.Context, filter OrderFilter) ([]OrderRef, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	refs := []OrderRef{}
+	for uid, order := range m.orders {
+		if _, deleted := m.deleted[uid]; deleted || !filter.Match(&order) {
+			continue
+		}
+		ref := OrderRef{UID: uid, DateCreated: order.DateCreated}
+		if filter.After != nil && !filter.After.Before(ref) {
+			continue
+		}
+		refs = append(refs, ref)
+	}
+	sort.Slice(refs, func(i, j int) bool { return refs[i].Before(refs[j]) })
+	if filter.Limit > 0 && len(refs) > filter.Limit {
+		refs = refs[:filter.Limit]
+	}
+	return refs, nil
+}
+
+func (m *MemoryStore) GetOrdersByUIDsContext(ctx context.Context, uids []string) ([]Order, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	orders := []Order{}
+	for _, uid := range uids {
+		order, ok := m.orders[uid]
+		if _, deleted := m.deleted[uid]; !ok || deleted {
+			continue
+		}
+		orders = append(orders, copyOrder(&order))
+	}
+	return orders, nil
+}
+
+func (m *MemoryStore) GetCustomerSummaryContext(ctx context.Context, customerID string, top int) (*CustomerSummary, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	summary := newCustomerSummary(customerID)
+	brands, services := map[string]int{}, map[string]int{}
+	for uid, order := range m.orders {
+		if _, deleted := m.deleted[uid]; deleted || order.CustomerID != customerID {
+			continue
+		}
+		summary.add(&order, brands, services)
+	}
+	summary.FavoriteBrands = topFavorites(brands, top)
+	summary.FavoriteDeliveryServices = topFavorites(services, top)
+	return summary, nil
+}
+
+func (m *MemoryStore) Close() error {
+	return nil
+}
@@ -0,0 +1,11 @@
+package db
+
+import "testing"
+
+func TestMemoryStoreSoftDelete(t *testing.T) {
+	testSoftDelete(t, NewMemoryStore())
+}
+
+func TestMemoryStoreCreateOrder(t *testing.T) {
+	testCreateOrder(t, NewMemoryStore())
+}
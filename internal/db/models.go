@@ -13,7 +13,7 @@ type Order struct {
 	Delivery          Delivery  `json:"delivery" validate:"required"`
 	Payment           Payment   `json:"payment" validate:"required"`
 	Items             []Item    `json:"items" validate:"required,min=1,dive"`
-	Locale            string    `json:"locale" validate:"required,oneof=en ru pt"`
+	Locale            string    `json:"locale" validate:"required,bcp47"`
 	InternalSignature string    `json:"internal_signature" validate:"max=100"`
 	CustomerID        string    `json:"customer_id" validate:"required,alphanum,min=1,max=50"`
 	DeliveryService   string    `json:"delivery_service" validate:"required,alpha,min=1,max=50"`
@@ -21,6 +21,9 @@ type Order struct {
 	SmID              int       `json:"sm_id" validate:"min=0,max=1000"`
 	DateCreated       time.Time `json:"date_created" validate:"required"`
 	OofShard          string    `json:"oof_shard" validate:"required,numeric,min=1,max=10"`
+	// Status is kept by the service: it is set from the item status codes
+	// when an order is first stored and changed only through transitions.
+	Status OrderStatus `json:"status,omitempty" validate:"omitempty,oneof=created paid assembling shipped delivered cancelled returned"`
 }
 
 func (o *Order) UnmarshalJSON(data []byte) error {
@@ -36,18 +39,35 @@ func (o *Order) UnmarshalJSON(data []byte) error {
 		return err
 	}
 
-	parsedTime, err := time.Parse(time.RFC3339, aux.DateCreated)
+	parsedTime, err := ParseDateCreated(aux.DateCreated)
 	if err != nil {
-		return fmt.Errorf("failed to parse date_created: %w", err)
+		return err
 	}
 
 	o.DateCreated = parsedTime
 	return nil
 }
 
+// dateCreatedLayouts are tried in order; timestamps without a zone are UTC.
+var dateCreatedLayouts = []string{
+	time.RFC3339Nano,
+	"2006-01-02T15:04:05.999999999",
+	"2006-01-02 15:04:05.999999999Z07:00",
+	"2006-01-02 15:04:05.999999999",
+}
+
+func ParseDateCreated(s string) (time.Time, error) {
+	for _, layout := range dateCreatedLayouts {
+		if t, err := time.Parse(layout, s); err == nil {
+			return t, nil
+		}
+	}
+	return time.Time{}, fmt.Errorf("failed to parse date_created %q: want RFC 3339 with or without zone", s)
+}
+
 type Delivery struct {
 	Name    string `json:"name" validate:"required,min=1,max=100"`
-	Phone   string `json:"phone" validate:"required,numeric"`
+	Phone   string `json:"phone" validate:"required,e164"`
 	Zip     string `json:"zip" validate:"required,numeric,min=1,max=20"`
 	City    string `json:"city" validate:"required,min=1,max=100"`
 	Address string `json:"address" validate:"required,min=1,max=200"`
@@ -58,7 +78,7 @@ type Delivery struct {
 type Payment struct {
 	Transaction  string `json:"transaction" validate:"required"`
 	RequestID    string `json:"request_id" validate:"max=50"`
-	Currency     string `json:"currency" validate:"required,alpha,min=3"`
+	Currency     string `json:"currency" validate:"required,iso4217"`
 	Provider     string `json:"provider" validate:"required,alpha,min=1,max=50"`
 	Amount       int    `json:"amount" validate:"required,min=0"`
 	PaymentDt    int64  `json:"payment_dt" validate:"required,min=1"`
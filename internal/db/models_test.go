@@ -0,0 +1,73 @@
+package db
+
+import (
+	"encoding/json"
+	"testing"
+	"time"
+)
+
+func TestParseDateCreated(t *testing.T) {
+	msk := time.FixedZone("", 3*60*60)
+	tests := []struct {
+		value string
+		want  time.Time
+	}{
+		{"2021-11-26T06:22:19Z", time.Date(2021, 11, 26, 6, 22, 19, 0, time.UTC)},
+		{"2021-11-26T06:22:19.123456789Z", time.Date(2021, 11, 26, 6, 22, 19, 123456789, time.UTC)},
+		{"2021-11-26T09:22:19+03:00", time.Date(2021, 11, 26, 9, 22, 19, 0, msk)},
+		{"2021-11-26T06:22:19", time.Date(2021, 11, 26, 6, 22, 19, 0, time.UTC)},
+		{"2021-11-26T06:22:19.5", time.Date(2021, 11, 26, 6, 22, 19, 500000000, time.UTC)},
+		{"2021-11-26 09:22:19+03:00", time.Date(2021, 11, 26, 9, 22, 19, 0, msk)},
+		{"2021-11-26 06:22:19", time.Date(2021, 11, 26, 6, 22, 19, 0, time.UTC)},
+		{"2021-11-26 06:22:19.25Z", time.Date(2021, 11, 26, 6, 22, 19, 250000000, time.UTC)},
+	}
+	for _, tt := range tests {
+		got, err := ParseDateCreated(tt.value)
+		if err != nil {
+			t.Errorf("ParseDateCreated(%q): %v", tt.value, err)
+			continue
+		}
+		if !got.Equal(tt.want) {
+			t.Errorf("ParseDateCreated(%q) = %v, want %v", tt.value, got, tt.want)
+		}
+		_, offset := got.Zone()
+		_, wantOffset := tt.want.Zone()
+		if offset != wantOffset {
+			t.Errorf("ParseDateCreated(%q) zone offset = %d, want %d", tt.value, offset, wantOffset)
+		}
+	}
+
+	for _, value := range []string{
+		"",
+		"2021-11-26",
+		"2021-11-26T06:22",
+		"26.11.2021 06:22:19",
+		"2021-11-26T06:22:19 MSK",
+		"2021-13-01T00:00:00Z",
+		"1637907727",
+	} {
+		if got, err := ParseDateCreated(value); err == nil {
+			t.Errorf("ParseDateCreated(%q) = %v, want an error", value, got)
+		}
+	}
+}
+
+func TestOrderUnmarshalDateCreated(t *testing.T) {
+	var o Order
+	if err := json.Unmarshal([]byte(`{"order_uid": "a", "date_created": "2021-11-26 06:22:19"}`), &o); err != nil {
+		t.Fatalf("unmarshal: %v", err)
+	}
+	if want := time.Date(2021, 11, 26, 6, 22, 19, 0, time.UTC); o.OrderUID != "a" || !o.DateCreated.Equal(want) {
+		t.Errorf("order = %s at %v, want a at %v", o.OrderUID, o.DateCreated, want)
+	}
+
+	for _, data := range []string{
+		`{"order_uid": "a", "date_created": "yesterday"}`,
+		`{"order_uid": "a"}`,
+		`{"order_uid": "a", "date_created": 1637907727}`,
+	} {
+		if err := json.Unmarshal([]byte(data), &o); err == nil {
+			t.Errorf("unmarshal %s succeeded, want an error", data)
+		}
+	}
+}
@@ -0,0 +1,136 @@
+package db
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"github.com/lib/pq"
+)
+
+const (
+	EventOrderAccepted = "order.accepted"
+	EventOrderUpdated  = "order.updated"
+	EventOrderDeleted  = "order.deleted"
+
+	EventOrderStatusChanged = "order.status_changed"
+)
+
+type OrderEvent struct {
+	Type       string    `json:"type"`
+	OrderUID   string    `json:"order_uid"`
+	OccurredAt time.Time `json:"occurred_at"`
+	Order      *Order    `json:"order,omitempty"`
+
+	Status *StatusChange `json:"status,omitempty"`
+}
+
+type OutboxEvent struct {
+	ID        int64
+	OrderUID  string
+	EventType string
+	Payload   []byte
+	CreatedAt time.Time
+}
+
+func insertOutboxEvent(ctx context.Context, tx *sql.Tx, event OrderEvent) error {
+	event.OccurredAt = time.Now().UTC()
+	payload, err := json.Marshal(event)
+	if err != nil {
+		return fmt.Errorf("failed to marshal outbox event: %w", err)
+	}
+
+	_, err = tx.ExecContext(ctx, `
+		INSERT INTO outbox (order_uid, event_type, payload)
+		VALUES ($1, $2, $3)`,
+		event.OrderUID, event.Type, payload)
+	if err != nil {
+		return fmt.Errorf("failed to save outbox event: %w", err)
+	}
+	return nil
+}
+
+// ProcessOutbox locks the oldest unpublished events, hands them to publish and
+// marks them published only if publish succeeds, so delivery is at-least-once.
+// Rows already locked by another relay are skipped rather than waited for, so
+// relays publish in parallel. An event is left for a later batch while an
+// earlier event of the same order is still unpublished outside this batch,
+// which keeps each order's events in id order.
+func (d *Database) ProcessOutbox(ctx context.Context, limit int, publish func([]OutboxEvent) error) (int, error) {
+	tx, err := d.Conn.BeginTx(ctx, nil)
+	if err != nil {
+		return 0, fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	rows, err := tx.QueryContext(ctx, `
+		WITH claimed AS (
+			SELECT id, order_uid, event_type, payload, created_at
+			FROM outbox
+			WHERE published_at IS NULL
+			ORDER BY id
+			LIMIT $1
+			FOR UPDATE SKIP LOCKED
+		)
+		SELECT c.id, c.order_uid, c.event_type, c.payload, c.created_at
+		FROM claimed c
+		WHERE NOT EXISTS (
+			SELECT 1 FROM outbox o
+			WHERE o.order_uid = c.order_uid
+				AND o.published_at IS NULL
+				AND o.id < c.id
+				AND o.id NOT IN (SELECT id FROM claimed)
+		)
+		ORDER BY c.id`, limit)
+	if err != nil {
+		return 0, fmt.Errorf("failed to fetch outbox events: %w", err)
+	}
+
+	var events []OutboxEvent
+	for rows.Next() {
+		var e OutboxEvent
+		if err := rows.Scan(&e.ID, &e.OrderUID, &e.EventType, &e.Payload, &e.CreatedAt); err != nil {
+			rows.Close()
+			return 0, fmt.Errorf("failed to scan outbox event: %w", err)
+		}
+		events = append(events, e)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return 0, fmt.Errorf("failed to read outbox events: %w", err)
+	}
+
+	if len(events) == 0 {
+		return 0, nil
+	}
+
+	if err := publish(events); err != nil {
+		return 0, fmt.Errorf("failed to publish outbox events: %w", err)
+	}
+
+	ids := make([]int64, len(events))
+	for i, e := range events {
+		ids[i] = e.ID
+	}
+	_, err = tx.ExecContext(ctx,
+		"UPDATE outbox SET published_at = NOW() WHERE id = ANY($1)", pq.Array(ids))
+	if err != nil {
+		return 0, fmt.Errorf("failed to mark outbox events published: %w", err)
+	}
+
+	if err := tx.Commit(); err != nil {
+		return 0, fmt.Errorf("failed to commit outbox events: %w", err)
+	}
+	return len(events), nil
+}
+
+func (d *Database) PurgeOutbox(ctx context.Context, publishedBefore time.Time) (int64, error) {
+	result, err := d.Conn.ExecContext(ctx,
+		"DELETE FROM outbox WHERE published_at IS NOT NULL AND published_at < $1", publishedBefore)
+	if err != nil {
+		return 0, fmt.Errorf("failed to purge outbox: %w", err)
+	}
+	return result.RowsAffected()
+}
@@ -0,0 +1,68 @@
+package db
+
+import (
+	"context"
+	"testing"
+	"time"
+)
+
+func eventIDs(events []OutboxEvent) []int64 {
+	ids := make([]int64, len(events))
+	for i, e := range events {
+		ids[i] = e.ID
+	}
+	return ids
+}
+
+func TestProcessOutboxSkipsLockedRowsAndKeepsOrderSequence(t *testing.T) {
+	d := openTestDB(t)
+	// Events 1 and 3 belong to order a, event 2 to order b.
+	saveTestOrders(t, d, testOrder("a"), testOrder("b"), testOrder("a"))
+
+	claimed := make(chan []OutboxEvent)
+	release := make(chan struct{})
+	first := make(chan error)
+	go func() {
+		_, err := d.ProcessOutbox(context.Background(), 1, func(events []OutboxEvent) error {
+			claimed <- events
+			<-release
+			return nil
+		})
+		first <- err
+	}()
+	if ids := eventIDs(<-claimed); len(ids) != 1 || ids[0] != 1 {
+		t.Fatalf("first relay claimed %v, want [1]", ids)
+	}
+
+	// The second relay must not wait for the first, and must hold back
+	// event 3 while event 1 of the same order is still unpublished.
+	ctx, cancel := context.WithTimeout(context.Background(), 2*time.Second)
+	defer cancel()
+	var second []OutboxEvent
+	_, err := d.ProcessOutbox(ctx, 10, func(events []OutboxEvent) error {
+		second = events
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("second relay: %v", err)
+	}
+	if ids := eventIDs(second); len(ids) != 1 || ids[0] != 2 {
+		t.Fatalf("second relay published %v, want [2]", ids)
+	}
+
+	close(release)
+	if err := <-first; err != nil {
+		t.Fatalf("first relay: %v", err)
+	}
+
+	var third []OutboxEvent
+	if _, err := d.ProcessOutbox(context.Background(), 10, func(events []OutboxEvent) error {
+		third = events
+		return nil
+	}); err != nil {
+		t.Fatalf("third relay: %v", err)
+	}
+	if ids := eventIDs(third); len(ids) != 1 || ids[0] != 3 {
+		t.Fatalf("third relay published %v, want [3]", ids)
+	}
+}
@@ -0,0 +1,154 @@
+package db
+
+import (
+	"context"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/lib/pq"
+)
+
+var ErrInvalidCursor = errors.New("invalid cursor")
+
+// OrderRef identifies an order in a listing. Listings are ordered newest
+// first by DateCreated and then by UID, which also makes a ref usable as a
+// pagination cursor.
+type OrderRef struct {
+	UID         string
+	DateCreated time.Time
+}
+
+// Before reports whether r sorts before other in a listing. Uids compare
+// byte by byte, as the listing query does with COLLATE "C", so merged shard
+// pages line up with the cursors of each shard.
+func (r OrderRef) Before(other OrderRef) bool {
+	if !r.DateCreated.Equal(other.DateCreated) {
+		return r.DateCreated.After(other.DateCreated)
+	}
+	return r.UID > other.UID
+}
+
+// Cursor encodes the ref for use as an opaque "after" parameter.
+func (r OrderRef) Cursor() string {
+	return base64.RawURLEncoding.EncodeToString([]byte(r.DateCreated.UTC().Format(time.RFC3339Nano) + "|" + r.UID))
+}
+
+// ParseCursor decodes a value returned by OrderRef.Cursor.
+func ParseCursor(cursor string) (*OrderRef, error) {
+	raw, err := base64.RawURLEncoding.DecodeString(cursor)
+	if err != nil {
+		return nil, ErrInvalidCursor
+	}
+	created, uid, ok := strings.Cut(string(raw), "|")
+	if !ok {
+		return nil, ErrInvalidCursor
+	}
+	t, err := time.Parse(time.RFC3339Nano, created)
+	if err != nil {
+		return nil, ErrInvalidCursor
+	}
+	return &OrderRef{UID: uid, DateCreated: t}, nil
+}
+
+// OrderFilter selects orders for a listing. Zero fields match every order;
+// After skips every order up to and including the given ref.
+type OrderFilter struct {
+	CustomerID      string
+	DeliveryService string
+	TrackNumber     string
+	Status          OrderStatus
+	CreatedFrom     time.Time
+	CreatedTo       time.Time
+	After           *OrderRef
+	Limit           int
+}
+
+// Match reports whether an order passes every condition except After and
+// Limit.
+func (f OrderFilter) Match(o *Order) bool {
+	return (f.CustomerID == "" || o.CustomerID == f.CustomerID) &&
+		(f.DeliveryService == "" || o.DeliveryService == f.DeliveryService) &&
+		(f.TrackNumber == "" || o.TrackNumber == f.TrackNumber) &&
+		(f.Status == "" || o.Status == f.Status) &&
+		(f.CreatedFrom.IsZero() || !o.DateCreated.Before(f.CreatedFrom)) &&
+		(f.CreatedTo.IsZero() || o.DateCreated.Before(f.CreatedTo))
+}
+
+// ListOrderRefsContext returns one page of the orders matching filter. It only
+// reads the orders table; load the orders themselves with
+// GetOrdersByUIDsContext.
+func (d *Database) ListOrderRefsContext(ctx context.Context, filter OrderFilter) ([]OrderRef, error) {
+	ctx, cancel := context.WithTimeout(ctx, d.timeouts.List)
+	defer cancel()
+
+	conds := []string{"deleted_at IS NULL"}
+	var args []any
+	arg := func(v any) string {
+		args = append(args, v)
+		return fmt.Sprintf("$%d", len(args))
+	}
+	if filter.CustomerID != "" {
+		conds = append(conds, "customer_id = "+arg(filter.CustomerID))
+	}
+	if filter.DeliveryService != "" {
+		conds = append(conds, "delivery_service = "+arg(filter.DeliveryService))
+	}
+	if filter.TrackNumber != "" {
+		conds = append(conds, "track_number = "+arg(filter.TrackNumber))
+	}
+	if filter.Status != "" {
+		conds = append(conds, "status = "+arg(filter.Status))
+	}
+	if !filter.CreatedFrom.IsZero() {
+		conds = append(conds, "date_created >= "+arg(filter.CreatedFrom))
+	}
+	if !filter.CreatedTo.IsZero() {
+		conds = append(conds, "date_created < "+arg(filter.CreatedTo))
+	}
+	if filter.After != nil {
+		conds = append(conds, fmt.Sprintf(`(date_created, order_uid COLLATE "C") < (%s, %s)`,
+			arg(filter.After.DateCreated), arg(filter.After.UID)))
+	}
+	query := `SELECT order_uid, date_created FROM orders WHERE ` + strings.Join(conds, " AND ") +
+		` ORDER BY date_created DESC, order_uid COLLATE "C" DESC`
+	if filter.Limit > 0 {
+		query += " LIMIT " + arg(filter.Limit)
+	}
+
+	conn := d.reader("")
+	rows, err := conn.QueryContext(ctx, query, args...)
+	if d.retryOnPrimary(ctx, conn, err) {
+		rows, err = d.Conn.QueryContext(ctx, query, args...)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to list orders: %w", err)
+	}
+	defer rows.Close()
+
+	refs := []OrderRef{}
+	for rows.Next() {
+		var ref OrderRef
+		if err := rows.Scan(&ref.UID, &ref.DateCreated); err != nil {
+			return nil, fmt.Errorf("failed to scan order ref: %w", err)
+		}
+		refs = append(refs, ref)
+	}
+	return refs, rows.Err()
+}
+
+// GetOrdersByUIDsContext loads the given orders in one statement. Unknown and
+// deleted orders are left out of the result.
+func (d *Database) GetOrdersByUIDsContext(ctx context.Context, uids []string) ([]Order, error) {
+	if len(uids) == 0 {
+		return []Order{}, nil
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, d.timeouts.List)
+	defer cancel()
+
+	return d.queryOrders(ctx, orderSelect+`
+		WHERE o.order_uid = ANY($1) AND o.deleted_at IS NULL`, pq.Array(uids))
+}
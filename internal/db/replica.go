@@ -0,0 +1,168 @@
+package db
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"errors"
+	"io"
+	"log"
+	"net"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/lib/pq"
+)
+
+type replica struct {
+	name    string
+	conn    *sql.DB
+	healthy atomic.Bool
+}
+
+// replicaSet balances reads over healthy replicas. Orders written through this
+// Database within the read-your-writes window are read from the primary, so a
+// lookup right after ingestion never misses because of replication lag.
+type replicaSet struct {
+	replicas []*replica
+	next     atomic.Uint32
+	window   time.Duration
+
+	mu     sync.Mutex
+	recent map[string]time.Time
+
+	cancel context.CancelFunc
+	done   chan struct{}
+}
+
+// newReplicaSet starts serving reads from the replicas whose startup ping
+// succeeded, as reported in up; the others wait for the health check.
+func newReplicaSet(conns []*sql.DB, names []string, up []bool, checkInterval, window time.Duration) *replicaSet {
+	rs := &replicaSet{
+		window: window,
+		recent: make(map[string]time.Time),
+		done:   make(chan struct{}),
+	}
+	for i, conn := range conns {
+		r := &replica{name: names[i], conn: conn}
+		r.healthy.Store(up[i])
+		rs.replicas = append(rs.replicas, r)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	rs.cancel = cancel
+	go rs.healthLoop(ctx, checkInterval)
+	return rs
+}
+
+func (rs *replicaSet) pick(uid string) *sql.DB {
+	if rs.window > 0 && uid != "" {
+		rs.mu.Lock()
+		written, ok := rs.recent[uid]
+		rs.mu.Unlock()
+		if ok && time.Since(written) < rs.window {
+			return nil
+		}
+	}
+
+	n := len(rs.replicas)
+	start := int(rs.next.Add(1))
+	for i := 0; i < n; i++ {
+		r := rs.replicas[(start+i)%n]
+		if r.healthy.Load() {
+			return r.conn
+		}
+	}
+	return nil
+}
+
+func (rs *replicaSet) markWritten(uid string) {
+	if rs.window <= 0 {
+		return
+	}
+	rs.mu.Lock()
+	rs.recent[uid] = time.Now()
+	rs.mu.Unlock()
+}
+
+func (rs *replicaSet) markDown(conn *sql.DB) {
+	for _, r := range rs.replicas {
+		if r.conn == conn && r.healthy.Swap(false) {
+			log.Printf("Read replica %s marked unhealthy", r.name)
+		}
+	}
+}
+
+// isConnError reports whether err means the server could not be reached or
+// dropped the connection, as opposed to rejecting the statement.
+func isConnError(err error) bool {
+	if errors.Is(err, context.Canceled) || errors.Is(err, context.DeadlineExceeded) {
+		return false
+	}
+	if errors.Is(err, driver.ErrBadConn) || errors.Is(err, sql.ErrConnDone) ||
+		errors.Is(err, io.EOF) || errors.Is(err, io.ErrUnexpectedEOF) {
+		return true
+	}
+	var netErr net.Error
+	if errors.As(err, &netErr) {
+		return true
+	}
+	var pqErr *pq.Error
+	if errors.As(err, &pqErr) {
+		// Class 08 is connection_exception; 57P01-57P03 are the server
+		// shutting down or not yet accepting connections.
+		return pqErr.Code.Class() == "08" ||
+			pqErr.Code == "57P01" || pqErr.Code == "57P02" || pqErr.Code == "57P03"
+	}
+	return false
+}
+
+func (rs *replicaSet) healthLoop(ctx context.Context, interval time.Duration) {
+	defer close(rs.done)
+
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+
+		for _, r := range rs.replicas {
+			pingCtx, cancel := context.WithTimeout(ctx, 2*time.Second)
+			err := r.conn.PingContext(pingCtx)
+			cancel()
+			healthy := err == nil
+			if r.healthy.Swap(healthy) != healthy {
+				if healthy {
+					log.Printf("Read replica %s is healthy again", r.name)
+				} else {
+					log.Printf("Read replica %s marked unhealthy: %v", r.name, err)
+				}
+			}
+		}
+
+		rs.mu.Lock()
+		for uid, written := range rs.recent {
+			if time.Since(written) >= rs.window {
+				delete(rs.recent, uid)
+			}
+		}
+		rs.mu.Unlock()
+	}
+}
+
+func (rs *replicaSet) Close() error {
+	rs.cancel()
+	<-rs.done
+	var firstErr error
+	for _, r := range rs.replicas {
+		if err := r.conn.Close(); err != nil && firstErr == nil {
+			firstErr = err
+		}
+	}
+	return firstErr
+}
@@ -0,0 +1,99 @@
+package db
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"fmt"
+	"io"
+	"net"
+	"syscall"
+	"testing"
+	"time"
+
+	"github.com/lib/pq"
+)
+
+func TestIsConnError(t *testing.T) {
+	tests := []struct {
+		name string
+		err  error
+		want bool
+	}{
+		{"bad conn", driver.ErrBadConn, true},
+		{"conn done", sql.ErrConnDone, true},
+		{"dropped connection", fmt.Errorf("read: %w", io.ErrUnexpectedEOF), true},
+		{"refused", &net.OpError{Op: "dial", Net: "tcp", Err: syscall.ECONNREFUSED}, true},
+		{"connection failure", &pq.Error{Code: "08006"}, true},
+		{"admin shutdown", &pq.Error{Code: "57P01"}, true},
+		{"cannot connect now", &pq.Error{Code: "57P03"}, true},
+		{"syntax error", &pq.Error{Code: "42601"}, false},
+		{"recovery conflict", &pq.Error{Code: "40001"}, false},
+		{"not found", ErrOrderNotFound, false},
+		{"no rows", sql.ErrNoRows, false},
+		{"deadline", context.DeadlineExceeded, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := isConnError(tt.err); got != tt.want {
+				t.Errorf("isConnError(%v) = %t, want %t", tt.err, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestReplicaSetSkipsReplicasDownAtStartup(t *testing.T) {
+	down, _ := sql.Open("postgres", "host=down.invalid")
+	up, _ := sql.Open("postgres", "host=up.invalid")
+	rs := newReplicaSet([]*sql.DB{down, up}, []string{"#0", "#1"}, []bool{false, true}, time.Hour, 0)
+	defer rs.Close()
+
+	for range 4 {
+		if got := rs.pick(""); got != up {
+			t.Fatalf("pick = %p, want the replica that answered at startup %p", got, up)
+		}
+	}
+	rs.markDown(up)
+	if got := rs.pick(""); got != nil {
+		t.Errorf("pick with no healthy replica = %p, want the primary (nil)", got)
+	}
+}
+
+func TestRetryOnPrimary(t *testing.T) {
+	primary, _ := sql.Open("postgres", "host=primary.invalid")
+	replica, _ := sql.Open("postgres", "host=replica.invalid")
+	d := &Database{Conn: primary, replicas: newReplicaSet([]*sql.DB{replica}, []string{"#0"}, []bool{true}, time.Hour, 0)}
+	defer d.Close()
+
+	cancelled, cancel := context.WithCancel(context.Background())
+	cancel()
+	connErr := &net.OpError{Op: "read", Net: "tcp", Err: syscall.ECONNRESET}
+
+	tests := []struct {
+		name string
+		ctx  context.Context
+		conn *sql.DB
+		err  error
+	}{
+		{"success", context.Background(), replica, nil},
+		{"query error", context.Background(), replica, &pq.Error{Code: "42601"}},
+		{"not found", context.Background(), replica, ErrOrderNotFound},
+		{"context done", cancelled, replica, connErr},
+		{"primary failed", context.Background(), primary, connErr},
+	}
+	for _, tt := range tests {
+		if d.retryOnPrimary(tt.ctx, tt.conn, tt.err) {
+			t.Errorf("%s: retried on the primary", tt.name)
+		}
+		if d.reader("") != replica {
+			t.Fatalf("%s: replica marked down", tt.name)
+		}
+	}
+
+	if !d.retryOnPrimary(context.Background(), replica, fmt.Errorf("query: %w", connErr)) {
+		t.Error("connection error on a replica not retried on the primary")
+	}
+	if d.reader("") != primary {
+		t.Error("unreachable replica still serving reads")
+	}
+}
@@ -0,0 +1,427 @@
+package db
+
+import (
+	"container/list"
+	"context"
+	"errors"
+	"fmt"
+	"hash/fnv"
+	"slices"
+	"sort"
+	"strconv"
+	"sync"
+)
+
+// Router spreads orders over several stores. A new order is placed on the
+// shard selected by its shardkey (oof_shard when shardkey is empty) and stays
+// there: saving it again with another shardkey updates it in place, so no
+// stale copy is left on a second shard. The shards themselves record where an
+// order lives, deleted or not; the router only caches the shard of recently
+// used uids so reads and writes by uid go to that shard alone. A uid missing
+// from the cache is looked up on every shard, and a cached shard that no
+// longer holds the order, as after it was archived and saved again through
+// another instance, is looked up anew. List queries are scattered to every
+// shard and gathered.
+type Router struct {
+	shards []Store
+	owners *ownerCache
+}
+
+// NewRouter returns a router over shards that caches the shard of up to
+// cacheSize uids.
+func NewRouter(cacheSize int, shards ...Store) *Router {
+	return &Router{shards: shards, owners: newOwnerCache(cacheSize)}
+}
+
+func (r *Router) Shards() []Store {
+	return r.shards
+}
+
+func (r *Router) ShardIndex(order *Order) int {
+	key := order.Shardkey
+	if key == "" {
+		key = order.OofShard
+	}
+	if n, err := strconv.Atoi(key); err == nil && n >= 0 {
+		return n % len(r.shards)
+	}
+	h := fnv.New32a()
+	h.Write([]byte(key))
+	return int(h.Sum32() % uint32(len(r.shards)))
+}
+
+// each runs fn on every shard in parallel and waits for all of them.
+func (r *Router) each(fn func(i int, shard Store)) {
+	var wg sync.WaitGroup
+	for i, shard := range r.shards {
+		wg.Add(1)
+		go func(i int, shard Store) {
+			defer wg.Done()
+			fn(i, shard)
+		}(i, shard)
+	}
+	wg.Wait()
+}
+
+func (r *Router) remember(idx int, uids ...string) {
+	r.owners.put(idx, uids...)
+}
+
+// owner returns the shard holding uid, or -1 when no shard has it.
+func (r *Router) owner(ctx context.Context, uid string) (int, error) {
+	if idx, ok := r.owners.get(uid); ok {
+		return idx, nil
+	}
+	return r.probe(ctx, uid)
+}
+
+// probe asks every shard whether it holds uid. Deleted orders count, so an
+// order saved again after a delete returns to its shard whatever its shardkey
+// is now.
+func (r *Router) probe(ctx context.Context, uid string) (int, error) {
+	found := make([]bool, len(r.shards))
+	errs := make([]error, len(r.shards))
+	r.each(func(i int, shard Store) {
+		found[i], errs[i] = shard.HoldsOrderContext(ctx, uid)
+	})
+	for i := range r.shards {
+		if errs[i] != nil {
+			return -1, fmt.Errorf("shard %d: %w", i, errs[i])
+		}
+		if found[i] {
+			r.remember(i, uid)
+			return i, nil
+		}
+	}
+	r.owners.forget(uid)
+	return -1, nil
+}
+
+// onOwner runs fn on the shard holding uid.
+func (r *Router) onOwner(ctx context.Context, uid string, fn func(Store) error) error {
+	idx, cached := r.owners.get(uid)
+	if !cached {
+		var err error
+		if idx, err = r.probe(ctx, uid); err != nil {
+			return err
+		}
+	}
+	if idx < 0 {
+		return ErrOrderNotFound
+	}
+	err := fn(r.shards[idx])
+	if errors.Is(err, ErrOrderNotFound) && cached {
+		// The cached shard may be stale; ask again only if the order has
+		// moved.
+		moved, perr := r.probe(ctx, uid)
+		if perr != nil {
+			return perr
+		}
+		if moved >= 0 && moved != idx {
+			idx, err = moved, fn(r.shards[moved])
+		}
+	}
+	if err != nil {
+		if errors.Is(err, ErrOrderNotFound) {
+			return err
+		}
+		return fmt.Errorf("shard %d: %w", idx, err)
+	}
+	return nil
+}
+
+func (r *Router) SaveOrderContext(ctx context.Context, order *Order) error {
+	return r.save(ctx, order, Store.SaveOrderContext)
+}
+
+// CreateOrderContext relies on the shard to reject an existing order: a new
+// UID always maps to the same shard, so concurrent creates meet there.
+func (r *Router) CreateOrderContext(ctx context.Context, order *Order) error {
+	return r.save(ctx, order, Store.CreateOrderContext)
+}
+
+// save writes order to the shard holding it, or to its shardkey shard when no
+// shard does. A cached shard is checked first so that instances with
+// different caches still agree on where the order goes.
+func (r *Router) save(ctx context.Context, order *Order, fn func(Store, context.Context, *Order) error) error {
+	idx, cached := r.owners.get(order.OrderUID)
+	if cached {
+		holds, err := r.shards[idx].HoldsOrderContext(ctx, order.OrderUID)
+		if err != nil {
+			return fmt.Errorf("shard %d: %w", idx, err)
+		}
+		cached = holds
+	}
+	if !cached {
+		var err error
+		if idx, err = r.probe(ctx, order.OrderUID); err != nil {
+			return err
+		}
+	}
+	if idx < 0 {
+		idx = r.ShardIndex(order)
+	}
+	if err := fn(r.shards[idx], ctx, order); err != nil {
+		return fmt.Errorf("shard %d: %w", idx, err)
+	}
+	r.remember(idx, order.OrderUID)
+	return nil
+}
+
+func (r *Router) GetOrderByUIDContext(ctx context.Context, uid string) (*Order, error) {
+	var order *Order
+	err := r.onOwner(ctx, uid, func(s Store) (err error) {
+		order, err = s.GetOrderByUIDContext(ctx, uid)
+		return err
+	})
+	return order, err
+}
+
+func (r *Router) GetAllOrdersContext(ctx context.Context) ([]Order, error) {
+	lists := make([][]Order, len(r.shards))
+	errs := make([]error, len(r.shards))
+	r.each(func(i int, shard Store) {
+		lists[i], errs[i] = shard.GetAllOrdersContext(ctx)
+	})
+
+	orders := []Order{}
+	for i := range r.shards {
+		if errs[i] != nil {
+			return nil, fmt.Errorf("shard %d: %w", i, errs[i])
+		}
+		r.rememberOrders(i, lists[i])
+		orders = append(orders, lists[i]...)
+	}
+	return orders, nil
+}
+
+func (r *Router) rememberOrders(idx int, orders []Order) {
+	uids := make([]string, len(orders))
+	for i := range orders {
+		uids[i] = orders[i].OrderUID
+	}
+	r.remember(idx, uids...)
+}
+
+func (r *Router) DeleteOrderContext(ctx context.Context, uid string) error {
+	return r.onOwner(ctx, uid, func(s Store) error {
+		return s.DeleteOrderContext(ctx, uid)
+	})
+}
+
+func (r *Router) HoldsOrderContext(ctx context.Context, uid string) (bool, error) {
+	idx, err := r.owner(ctx, uid)
+	return idx >= 0, err
+}
+
+func (r *Router) OrderExistsContext(ctx context.Context, uid string) (bool, error) {
+	var exists bool
+	err := r.onOwner(ctx, uid, func(s Store) (err error) {
+		exists, err = s.OrderExistsContext(ctx, uid)
+		return err
+	})
+	if errors.Is(err, ErrOrderNotFound) {
+		return false, nil
+	}
+	return exists, err
+}
+
+func (r *Router) UpdateOrderStatusContext(ctx context.Context, uid string, to OrderStatus, reason string) (*StatusChange, error) {
+	var change *StatusChange
+	err := r.onOwner(ctx, uid, func(s Store) (err error) {
+		change, err = s.UpdateOrderStatusContext(ctx, uid, to, reason)
+		return err
+	})
+	return change, err
+}
+
+func (r *Router) GetStatusHistoryContext(ctx context.Context, uid string) ([]StatusChange, error) {
+	var history []StatusChange
+	err := r.onOwner(ctx, uid, func(s Store) (err error) {
+		history, err = s.GetStatusHistoryContext(ctx, uid)
+		return err
+	})
+	return history, err
+}
+
+func (r *Router) UpdateDeliveryContext(ctx context.Context, uid string, patch DeliveryPatch) (*Order, error) {
+	var order *Order
+	err := r.onOwner(ctx, uid, func(s Store) (err error) {
+		order, err = s.UpdateDeliveryContext(ctx, uid, patch)
+		return err
+	})
+	return order, err
+}
+
+// ListOrderRefsContext asks every shard for a full page and merges them, so
+// a page is correct however the matching orders are spread.
+func (r *Router) ListOrderRefsContext(ctx context.Context, filter OrderFilter) ([]OrderRef, error) {
+	lists := make([][]OrderRef, len(r.shards))
+	errs := make([]error, len(r.shards))
+	r.each(func(i int, shard Store) {
+		lists[i], errs[i] = shard.ListOrderRefsContext(ctx, filter)
+	})
+
+	refs := []OrderRef{}
+	for i := range r.shards {
+		if errs[i] != nil {
+			return nil, fmt.Errorf("shard %d: %w", i, errs[i])
+		}
+		uids := make([]string, len(lists[i]))
+		for j, ref := range lists[i] {
+			uids[j] = ref.UID
+		}
+		r.remember(i, uids...)
+		refs = append(refs, lists[i]...)
+	}
+	sort.Slice(refs, func(i, j int) bool { return refs[i].Before(refs[j]) })
+	if filter.Limit > 0 && len(refs) > filter.Limit {
+		refs = refs[:filter.Limit]
+	}
+	return refs, nil
+}
+
+// GetOrdersByUIDsContext sends each shard the uids it is known to hold plus
+// the uids whose shard is not known yet; each shard returns the orders it
+// holds.
+func (r *Router) GetOrdersByUIDsContext(ctx context.Context, uids []string) ([]Order, error) {
+	known := make([][]string, len(r.shards))
+	var unknown []string
+	for _, uid := range uids {
+		if idx, ok := r.owners.get(uid); ok {
+			known[idx] = append(known[idx], uid)
+		} else {
+			unknown = append(unknown, uid)
+		}
+	}
+
+	lists := make([][]Order, len(r.shards))
+	errs := make([]error, len(r.shards))
+	r.each(func(i int, shard Store) {
+		if ask := slices.Concat(known[i], unknown); len(ask) > 0 {
+			lists[i], errs[i] = shard.GetOrdersByUIDsContext(ctx, ask)
+		}
+	})
+
+	orders := []Order{}
+	for i := range r.shards {
+		if errs[i] != nil {
+			return nil, fmt.Errorf("shard %d: %w", i, errs[i])
+		}
+		r.rememberOrders(i, lists[i])
+		orders = append(orders, lists[i]...)
+	}
+	return orders, nil
+}
+
+// GetCustomerSummaryContext merges the summaries of every shard. Shards return
+// complete favorites lists so the merged ranking is exact.
+func (r *Router) GetCustomerSummaryContext(ctx context.Context, customerID string, top int) (*CustomerSummary, error) {
+	parts := make([]*CustomerSummary, len(r.shards))
+	errs := make([]error, len(r.shards))
+	r.each(func(i int, shard Store) {
+		parts[i], errs[i] = shard.GetCustomerSummaryContext(ctx, customerID, 0)
+	})
+
+	summary := newCustomerSummary(customerID)
+	brands, services := map[string]int{}, map[string]int{}
+	for i, part := range parts {
+		if errs[i] != nil {
+			return nil, fmt.Errorf("shard %d: %w", i, errs[i])
+		}
+		summary.OrderCount += part.OrderCount
+		for currency, amount := range part.TotalSpent {
+			summary.TotalSpent[currency] += amount
+		}
+		if part.FirstOrderAt != nil && (summary.FirstOrderAt == nil || part.FirstOrderAt.Before(*summary.FirstOrderAt)) {
+			summary.FirstOrderAt = part.FirstOrderAt
+		}
+		if part.LastOrderAt != nil && (summary.LastOrderAt == nil || part.LastOrderAt.After(*summary.LastOrderAt)) {
+			summary.LastOrderAt = part.LastOrderAt
+		}
+		for _, f := range part.FavoriteBrands {
+			brands[f.Name] += f.Count
+		}
+		for _, f := range part.FavoriteDeliveryServices {
+			services[f.Name] += f.Count
+		}
+	}
+	summary.FavoriteBrands = topFavorites(brands, top)
+	summary.FavoriteDeliveryServices = topFavorites(services, top)
+	return summary, nil
+}
+
+func (r *Router) Close() error {
+	var errs []error
+	for _, shard := range r.shards {
+		if err := shard.Close(); err != nil {
+			errs = append(errs, err)
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// ownerCache remembers the shards of the most recently used uids, evicting
+// the least recently used one when it is full.
+type ownerCache struct {
+	mu    sync.Mutex
+	size  int
+	lru   *list.List
+	byUID map[string]*list.Element
+}
+
+type ownerEntry struct {
+	uid   string
+	shard int
+}
+
+func newOwnerCache(size int) *ownerCache {
+	return &ownerCache{size: size, lru: list.New(), byUID: make(map[string]*list.Element)}
+}
+
+func (c *ownerCache) get(uid string) (int, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	e, ok := c.byUID[uid]
+	if !ok {
+		return -1, false
+	}
+	c.lru.MoveToFront(e)
+	return e.Value.(*ownerEntry).shard, true
+}
+
+func (c *ownerCache) put(shard int, uids ...string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	for _, uid := range uids {
+		if e, ok := c.byUID[uid]; ok {
+			e.Value.(*ownerEntry).shard = shard
+			c.lru.MoveToFront(e)
+			continue
+		}
+		if c.size < 1 {
+			return
+		}
+		c.byUID[uid] = c.lru.PushFront(&ownerEntry{uid: uid, shard: shard})
+		if c.lru.Len() > c.size {
+			oldest := c.lru.Back()
+			c.lru.Remove(oldest)
+			delete(c.byUID, oldest.Value.(*ownerEntry).uid)
+		}
+	}
+}
+
+func (c *ownerCache) forget(uid string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if e, ok := c.byUID[uid]; ok {
+		c.lru.Remove(e)
+		delete(c.byUID, uid)
+	}
+}
+
+func (c *ownerCache) len() int {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.lru.Len()
+}
@@ -0,0 +1,342 @@
+package db
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// countingStore counts the calls that address a single order, so tests can
+// tell which shards a router asked.
+type countingStore struct {
+	*MemoryStore
+	gets, probes, deletes, updates atomic.Int32
+}
+
+func (c *countingStore) GetOrderByUIDContext(ctx context.Context, uid string) (*Order, error) {
+	c.gets.Add(1)
+	return c.MemoryStore.GetOrderByUIDContext(ctx, uid)
+}
+
+func (c *countingStore) HoldsOrderContext(ctx context.Context, uid string) (bool, error) {
+	c.probes.Add(1)
+	return c.MemoryStore.HoldsOrderContext(ctx, uid)
+}
+
+func (c *countingStore) DeleteOrderContext(ctx context.Context, uid string) error {
+	c.deletes.Add(1)
+	return c.MemoryStore.DeleteOrderContext(ctx, uid)
+}
+
+func (c *countingStore) UpdateOrderStatusContext(ctx context.Context, uid string, to OrderStatus, reason string) (*StatusChange, error) {
+	c.updates.Add(1)
+	return c.MemoryStore.UpdateOrderStatusContext(ctx, uid, to, reason)
+}
+
+func (c *countingStore) reset() {
+	c.gets.Store(0)
+	c.probes.Store(0)
+	c.deletes.Store(0)
+	c.updates.Store(0)
+}
+
+func newCountingShards(n int) []*countingStore {
+	shards := make([]*countingStore, n)
+	for i := range shards {
+		shards[i] = &countingStore{MemoryStore: NewMemoryStore()}
+	}
+	return shards
+}
+
+func newTestRouter(shards []*countingStore) *Router {
+	stores := make([]Store, len(shards))
+	for i, s := range shards {
+		stores[i] = s
+	}
+	return NewRouter(100, stores...)
+}
+
+func shardedOrder(uid, shardkey string) *Order {
+	o := testOrder(uid)
+	o.Shardkey = shardkey
+	return o
+}
+
+func TestRouterShardIndex(t *testing.T) {
+	r := NewRouter(100, NewMemoryStore(), NewMemoryStore(), NewMemoryStore())
+	tests := []struct {
+		shardkey, oofShard string
+		want               int
+	}{
+		{"0", "1", 0},
+		{"4", "1", 1},
+		{"8", "1", 2},
+		{"", "7", 1},
+	}
+	for _, tt := range tests {
+		o := &Order{Shardkey: tt.shardkey, OofShard: tt.oofShard}
+		if got := r.ShardIndex(o); got != tt.want {
+			t.Errorf("ShardIndex(shardkey %q, oof_shard %q) = %d, want %d", tt.shardkey, tt.oofShard, got, tt.want)
+		}
+	}
+	// Non-numeric keys are hashed, always to the same shard.
+	o := &Order{Shardkey: "eu"}
+	if a, b := r.ShardIndex(o), r.ShardIndex(o); a != b || a < 0 || a > 2 {
+		t.Errorf("ShardIndex(eu) = %d then %d", a, b)
+	}
+}
+
+func TestRouterSavesOnShardkeyShard(t *testing.T) {
+	ctx := context.Background()
+	shards := newCountingShards(3)
+	r := newTestRouter(shards)
+	saveTestOrders(t, r, shardedOrder("a", "0"), shardedOrder("b", "1"), shardedOrder("c", "5"))
+
+	for uid, want := range map[string]int{"a": 0, "b": 1, "c": 2} {
+		for i, shard := range shards {
+			exists, _ := shard.MemoryStore.OrderExistsContext(ctx, uid)
+			if exists != (i == want) {
+				t.Errorf("order %s on shard %d = %t, want only on shard %d", uid, i, exists, want)
+			}
+		}
+	}
+}
+
+func TestRouterReadsAndWritesGoToTheOwningShard(t *testing.T) {
+	ctx := context.Background()
+	shards := newCountingShards(3)
+	saveTestOrders(t, newTestRouter(shards), shardedOrder("a", "1"))
+
+	// A fresh router, as after a restart, looks the uid up on every shard
+	// once and then only asks its owner.
+	r := newTestRouter(shards)
+	for _, s := range shards {
+		s.reset()
+	}
+	if _, err := r.GetOrderByUIDContext(ctx, "a"); err != nil {
+		t.Fatalf("first get: %v", err)
+	}
+	for i, s := range shards {
+		if s.probes.Load() != 1 {
+			t.Errorf("shard %d: %d probes on first get, want 1", i, s.probes.Load())
+		}
+	}
+
+	for _, s := range shards {
+		s.reset()
+	}
+	if _, err := r.GetOrderByUIDContext(ctx, "a"); err != nil {
+		t.Fatalf("second get: %v", err)
+	}
+	if _, err := r.UpdateOrderStatusContext(ctx, "a", StatusCancelled, "test"); err != nil {
+		t.Fatalf("update status: %v", err)
+	}
+	if err := r.DeleteOrderContext(ctx, "a"); err != nil {
+		t.Fatalf("delete: %v", err)
+	}
+	for i, s := range shards {
+		calls := s.gets.Load() + s.probes.Load() + s.updates.Load() + s.deletes.Load()
+		if i != 1 && calls != 0 {
+			t.Errorf("shard %d got %d calls for an order on shard 1", i, calls)
+		}
+	}
+	if s := shards[1]; s.gets.Load() != 1 || s.updates.Load() != 1 || s.deletes.Load() != 1 {
+		t.Errorf("shard 1: gets %d, updates %d, deletes %d; want 1 each", s.gets.Load(), s.updates.Load(), s.deletes.Load())
+	}
+
+	if _, err := r.GetOrderByUIDContext(ctx, "a"); !errors.Is(err, ErrOrderNotFound) {
+		t.Errorf("get after delete: err = %v, want ErrOrderNotFound", err)
+	}
+	if _, err := r.GetOrderByUIDContext(ctx, "missing"); !errors.Is(err, ErrOrderNotFound) {
+		t.Errorf("get missing: err = %v, want ErrOrderNotFound", err)
+	}
+}
+
+func TestRouterKeepsOrderOnItsShardWhenShardkeyChanges(t *testing.T) {
+	ctx := context.Background()
+	shards := newCountingShards(3)
+	saveTestOrders(t, newTestRouter(shards), shardedOrder("a", "1"))
+
+	for _, r := range []*Router{newTestRouter(shards), newTestRouter(shards)} {
+		moved := shardedOrder("a", "2")
+		moved.TrackNumber = "MOVED"
+		saveTestOrders(t, r, moved)
+
+		if exists, _ := shards[2].MemoryStore.OrderExistsContext(ctx, "a"); exists {
+			t.Fatal("order copied to the shard of its new shardkey")
+		}
+		got, err := shards[1].MemoryStore.GetOrderByUIDContext(ctx, "a")
+		if err != nil || got.TrackNumber != "MOVED" {
+			t.Fatalf("order on its original shard = %+v, %v; want the update", got, err)
+		}
+	}
+}
+
+func TestRouterKeepsDeletedOrderOnItsShard(t *testing.T) {
+	ctx := context.Background()
+	shards := newCountingShards(3)
+	r := newTestRouter(shards)
+	saveTestOrders(t, r, shardedOrder("a", "1"))
+	if err := r.DeleteOrderContext(ctx, "a"); err != nil {
+		t.Fatalf("delete: %v", err)
+	}
+
+	saveTestOrders(t, newTestRouter(shards), shardedOrder("a", "2"))
+	if held, _ := shards[2].MemoryStore.HoldsOrderContext(ctx, "a"); held {
+		t.Error("restored order copied to the shard of its new shardkey")
+	}
+	if exists, _ := shards[1].MemoryStore.OrderExistsContext(ctx, "a"); !exists {
+		t.Error("order not restored on its original shard")
+	}
+}
+
+// purge drops uid from m as archiving does, leaving no deleted row behind.
+func purge(m *MemoryStore, uid string) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	delete(m.orders, uid)
+	delete(m.deleted, uid)
+}
+
+func TestRouterFollowsOrdersMovedByAnotherInstance(t *testing.T) {
+	ctx := context.Background()
+	shards := newCountingShards(3)
+	a, b := newTestRouter(shards), newTestRouter(shards)
+	saveTestOrders(t, a, shardedOrder("a", "1"))
+	if _, err := b.GetOrderByUIDContext(ctx, "a"); err != nil {
+		t.Fatalf("get: %v", err)
+	}
+
+	// Archived on shard 1, then sent again with a new shardkey.
+	purge(shards[1].MemoryStore, "a")
+	moved := shardedOrder("a", "2")
+	moved.TrackNumber = "MOVED"
+	saveTestOrders(t, a, moved)
+	if held, _ := shards[2].MemoryStore.HoldsOrderContext(ctx, "a"); !held {
+		t.Fatal("order not saved on the shard of its new shardkey")
+	}
+
+	got, err := b.GetOrderByUIDContext(ctx, "a")
+	if err != nil || got.TrackNumber != "MOVED" {
+		t.Fatalf("get through the stale router = %+v, %v; want the moved order", got, err)
+	}
+	saveTestOrders(t, b, shardedOrder("a", "1"))
+	if held, _ := shards[1].MemoryStore.HoldsOrderContext(ctx, "a"); held {
+		t.Error("stale router saved a second copy on the old shard")
+	}
+}
+
+func TestRouterOwnerCacheIsBounded(t *testing.T) {
+	ctx := context.Background()
+	shards := newCountingShards(3)
+	stores := []Store{shards[0], shards[1], shards[2]}
+	r := NewRouter(2, stores...)
+	for i := range 5 {
+		saveTestOrders(t, r, shardedOrder(fmt.Sprintf("o%d", i), fmt.Sprint(i)))
+	}
+	if n := r.owners.len(); n != 2 {
+		t.Errorf("owner cache holds %d uids, want 2", n)
+	}
+
+	// An evicted uid is looked up again; a cached one is not.
+	for _, s := range shards {
+		s.reset()
+	}
+	if _, err := r.GetOrderByUIDContext(ctx, "o4"); err != nil {
+		t.Fatalf("get cached: %v", err)
+	}
+	if _, err := r.GetOrderByUIDContext(ctx, "o0"); err != nil {
+		t.Fatalf("get evicted: %v", err)
+	}
+	for i, s := range shards {
+		if s.probes.Load() != 1 {
+			t.Errorf("shard %d: %d probes, want 1 for the evicted uid", i, s.probes.Load())
+		}
+	}
+}
+
+func TestRouterSoftDelete(t *testing.T) {
+	testSoftDelete(t, newTestRouter(newCountingShards(3)))
+}
+
+func TestRouterCreateOrder(t *testing.T) {
+	testCreateOrder(t, newTestRouter(newCountingShards(3)))
+}
+
+func TestRouterListsAcrossShards(t *testing.T) {
+	ctx := context.Background()
+	r := newTestRouter(newCountingShards(3))
+	base := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	for i := range 9 {
+		o := shardedOrder(fmt.Sprintf("o%d", i), fmt.Sprint(i))
+		o.DateCreated = base.Add(time.Duration(i) * time.Hour)
+		saveTestOrders(t, r, o)
+	}
+
+	all, err := r.GetAllOrdersContext(ctx)
+	if err != nil || len(all) != 9 {
+		t.Fatalf("GetAllOrders = %d orders, %v; want 9", len(all), err)
+	}
+
+	var pages [][]string
+	filter := OrderFilter{Limit: 4}
+	for {
+		refs, err := r.ListOrderRefsContext(ctx, filter)
+		if err != nil {
+			t.Fatalf("list: %v", err)
+		}
+		var page []string
+		for _, ref := range refs {
+			page = append(page, ref.UID)
+		}
+		pages = append(pages, page)
+		if len(refs) < filter.Limit {
+			break
+		}
+		filter.After = &refs[len(refs)-1]
+	}
+	want := "[[o8 o7 o6 o5] [o4 o3 o2 o1] [o0]]"
+	if got := fmt.Sprint(pages); got != want {
+		t.Errorf("pages = %s, want %s", got, want)
+	}
+
+	orders, err := r.GetOrdersByUIDsContext(ctx, []string{"o1", "o5", "missing"})
+	if err != nil {
+		t.Fatalf("GetOrdersByUIDs: %v", err)
+	}
+	got := map[string]bool{}
+	for _, o := range orders {
+		got[o.OrderUID] = true
+	}
+	if len(orders) != 2 || !got["o1"] || !got["o5"] {
+		t.Errorf("GetOrdersByUIDs returned %v, want o1 and o5", got)
+	}
+}
+
+func TestRouterMergesCustomerSummaries(t *testing.T) {
+	ctx := context.Background()
+	r := newTestRouter(newCountingShards(2))
+	for i, brand := range []string{"x", "y", "y"} {
+		o := shardedOrder(fmt.Sprintf("o%d", i), fmt.Sprint(i))
+		o.Items[0].Brand = brand
+		o.DateCreated = time.Date(2024, 1, i+1, 0, 0, 0, 0, time.UTC)
+		saveTestOrders(t, r, o)
+	}
+
+	s, err := r.GetCustomerSummaryContext(ctx, "test", 1)
+	if err != nil {
+		t.Fatalf("summary: %v", err)
+	}
+	if s.OrderCount != 3 || s.TotalSpent["USD"] != 3*1817 {
+		t.Errorf("count %d, spent %v; want 3 and 5451 USD", s.OrderCount, s.TotalSpent)
+	}
+	if fmt.Sprint(s.FavoriteBrands) != "[{y 2}]" {
+		t.Errorf("favorite brands = %v, want [{y 2}]", s.FavoriteBrands)
+	}
+	if s.FirstOrderAt.Day() != 1 || s.LastOrderAt.Day() != 3 {
+		t.Errorf("first %v, last %v; want Jan 1 and Jan 3", s.FirstOrderAt, s.LastOrderAt)
+	}
+}
@@ -0,0 +1,257 @@
+package db
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"slices"
+	"time"
+)
+
+type OrderStatus string
+
+const (
+	StatusCreated    OrderStatus = "created"
+	StatusPaid       OrderStatus = "paid"
+	StatusAssembling OrderStatus = "assembling"
+	StatusShipped    OrderStatus = "shipped"
+	StatusDelivered  OrderStatus = "delivered"
+	StatusCancelled  OrderStatus = "cancelled"
+	StatusReturned   OrderStatus = "returned"
+)
+
+// transitions lists the statuses an order may move to from each status.
+// Cancelled and returned are final.
+var transitions = map[OrderStatus][]OrderStatus{
+	StatusCreated:    {StatusPaid, StatusCancelled},
+	StatusPaid:       {StatusAssembling, StatusCancelled},
+	StatusAssembling: {StatusShipped, StatusCancelled},
+	StatusShipped:    {StatusDelivered, StatusReturned},
+	StatusDelivered:  {StatusReturned},
+	StatusCancelled:  {},
+	StatusReturned:   {},
+}
+
+// progress orders the statuses of an order that is still being fulfilled.
+var progress = []OrderStatus{StatusCreated, StatusPaid, StatusAssembling, StatusShipped, StatusDelivered}
+
+var ErrInvalidStatus = errors.New("invalid order status")
+
+// TransitionError reports a status change the state machine does not allow.
+type TransitionError struct {
+	From, To OrderStatus
+}
+
+func (e *TransitionError) Error() string {
+	return fmt.Sprintf("order status cannot change from %s to %s", e.From, e.To)
+}
+
+// Statuses returns every valid status, in fulfilment order followed by the
+// final ones.
+func Statuses() []OrderStatus {
+	return append(slices.Clone(progress), StatusCancelled, StatusReturned)
+}
+
+func (s OrderStatus) Valid() bool {
+	_, ok := transitions[s]
+	return ok
+}
+
+// Next returns the statuses s may move to.
+func (s OrderStatus) Next() []OrderStatus {
+	return transitions[s]
+}
+
+func (s OrderStatus) CanTransition(to OrderStatus) bool {
+	return slices.Contains(transitions[s], to)
+}
+
+// ItemStatus maps the numeric item status codes used by upstream systems onto
+// the status model by hundreds: 1xx created, 200 paid, 201-299 assembling,
+// 3xx shipped, 4xx delivered, 5xx cancelled and 6xx returned. Unknown codes
+// report false.
+func ItemStatus(code int) (OrderStatus, bool) {
+	switch {
+	case code >= 100 && code < 200:
+		return StatusCreated, true
+	case code == 200:
+		return StatusPaid, true
+	case code > 200 && code < 300:
+		return StatusAssembling, true
+	case code >= 300 && code < 400:
+		return StatusShipped, true
+	case code >= 400 && code < 500:
+		return StatusDelivered, true
+	case code >= 500 && code < 600:
+		return StatusCancelled, true
+	case code >= 600 && code < 700:
+		return StatusReturned, true
+	}
+	return "", false
+}
+
+// DeriveStatus gives a new order the status of its least advanced active
+// item. An order whose items are all cancelled or returned takes that status;
+// an order without recognised item codes is created. It is the only source of
+// the status of a new order: a status sent with the order is ignored. The
+// backfill in migrations/init.sql applies the same rules.
+func DeriveStatus(items []Item) OrderStatus {
+	best := -1
+	var cancelled, returned bool
+	for _, item := range items {
+		s, ok := ItemStatus(item.Status)
+		if !ok {
+			continue
+		}
+		switch s {
+		case StatusCancelled:
+			cancelled = true
+		case StatusReturned:
+			returned = true
+		default:
+			if i := slices.Index(progress, s); best < 0 || i < best {
+				best = i
+			}
+		}
+	}
+	switch {
+	case best >= 0:
+		return progress[best]
+	case returned:
+		return StatusReturned
+	case cancelled:
+		return StatusCancelled
+	}
+	return StatusCreated
+}
+
+type StatusChange struct {
+	OrderUID  string      `json:"order_uid"`
+	From      OrderStatus `json:"from,omitempty"`
+	To        OrderStatus `json:"to"`
+	Reason    string      `json:"reason,omitempty"`
+	ChangedAt time.Time   `json:"changed_at"`
+}
+
+// checkTransition validates a change from one status to another. Moving to
+// the current status is allowed and changes nothing.
+func checkTransition(from, to OrderStatus) error {
+	if !to.Valid() {
+		return fmt.Errorf("%w: %q", ErrInvalidStatus, to)
+	}
+	if from != to && !from.CanTransition(to) {
+		return &TransitionError{From: from, To: to}
+	}
+	return nil
+}
+
+func insertStatusHistory(ctx context.Context, tx *sql.Tx, change *StatusChange) error {
+	var from sql.NullString
+	if change.From != "" {
+		from = sql.NullString{String: string(change.From), Valid: true}
+	}
+	err := tx.QueryRowContext(ctx, `
+		INSERT INTO order_status_history (order_uid, from_status, to_status, reason)
+		VALUES ($1, $2, $3, $4)
+		RETURNING changed_at`,
+		change.OrderUID, from, change.To, change.Reason).Scan(&change.ChangedAt)
+	if err != nil {
+		return fmt.Errorf("failed to save status history: %w", err)
+	}
+	return nil
+}
+
+// UpdateOrderStatusContext moves an order to a new status if the state
+// machine allows it, records the change in the history and publishes an
+// order.status_changed event.
+func (d *Database) UpdateOrderStatusContext(ctx context.Context, uid string, to OrderStatus, reason string) (*StatusChange, error) {
+	ctx, cancel := context.WithTimeout(ctx, d.timeouts.Save)
+	defer cancel()
+
+	tx, err := d.Conn.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	var from OrderStatus
+	err = tx.QueryRowContext(ctx,
+		"SELECT status FROM orders WHERE order_uid = $1 AND deleted_at IS NULL FOR UPDATE", uid).Scan(&from)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, ErrOrderNotFound
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to get order status: %w", err)
+	}
+
+	if err := checkTransition(from, to); err != nil {
+		return nil, err
+	}
+	change := &StatusChange{OrderUID: uid, From: from, To: to, Reason: reason, ChangedAt: time.Now().UTC()}
+	if from == to {
+		return change, nil
+	}
+
+	if _, err := tx.ExecContext(ctx, "UPDATE orders SET status = $1 WHERE order_uid = $2", to, uid); err != nil {
+		return nil, fmt.Errorf("failed to update order status: %w", err)
+	}
+	if err := insertStatusHistory(ctx, tx, change); err != nil {
+		return nil, err
+	}
+	if err := insertOutboxEvent(ctx, tx, OrderEvent{Type: EventOrderStatusChanged, OrderUID: uid, Status: change}); err != nil {
+		return nil, err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return nil, err
+	}
+	d.markWritten(uid)
+	return change, nil
+}
+
+// GetStatusHistoryContext returns the status changes of an order, oldest
+// first.
+func (d *Database) GetStatusHistoryContext(ctx context.Context, uid string) ([]StatusChange, error) {
+	ctx, cancel := context.WithTimeout(ctx, d.timeouts.Get)
+	defer cancel()
+
+	rows, err := d.reader(uid).QueryContext(ctx, `
+		SELECT h.from_status, h.to_status, h.reason, h.changed_at
+		FROM orders o
+		LEFT JOIN order_status_history h ON h.order_uid = o.order_uid
+		WHERE o.order_uid = $1 AND o.deleted_at IS NULL
+		ORDER BY h.id`, uid)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get status history: %w", err)
+	}
+	defer rows.Close()
+
+	found := false
+	history := []StatusChange{}
+	for rows.Next() {
+		found = true
+		var from, to, reason sql.NullString
+		var changedAt sql.NullTime
+		if err := rows.Scan(&from, &to, &reason, &changedAt); err != nil {
+			return nil, fmt.Errorf("failed to scan status history: %w", err)
+		}
+		if !to.Valid {
+			continue
+		}
+		history = append(history, StatusChange{
+			OrderUID:  uid,
+			From:      OrderStatus(from.String),
+			To:        OrderStatus(to.String),
+			Reason:    reason.String,
+			ChangedAt: changedAt.Time,
+		})
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	if !found {
+		return nil, ErrOrderNotFound
+	}
+	return history, nil
+}
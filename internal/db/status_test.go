@@ -0,0 +1,138 @@
+package db
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"testing"
+)
+
+func TestCheckTransition(t *testing.T) {
+	allowed := map[OrderStatus]string{
+		StatusCreated:    "[paid cancelled]",
+		StatusPaid:       "[assembling cancelled]",
+		StatusAssembling: "[shipped cancelled]",
+		StatusShipped:    "[delivered returned]",
+		StatusDelivered:  "[returned]",
+		StatusCancelled:  "[]",
+		StatusReturned:   "[]",
+	}
+	if len(Statuses()) != len(allowed) {
+		t.Fatalf("Statuses() = %v, want the %d statuses of the table", Statuses(), len(allowed))
+	}
+	for _, from := range Statuses() {
+		if got := fmt.Sprint(from.Next()); got != allowed[from] {
+			t.Errorf("%s.Next() = %s, want %s", from, got, allowed[from])
+		}
+		for _, to := range Statuses() {
+			err := checkTransition(from, to)
+			var terr *TransitionError
+			switch {
+			case from == to || from.CanTransition(to):
+				if err != nil {
+					t.Errorf("%s -> %s: %v", from, to, err)
+				}
+			case !errors.As(err, &terr) || terr.From != from || terr.To != to:
+				t.Errorf("%s -> %s: err = %v, want a TransitionError", from, to, err)
+			}
+		}
+	}
+
+	if err := checkTransition(StatusCreated, "lost"); !errors.Is(err, ErrInvalidStatus) {
+		t.Errorf("created -> lost: err = %v, want ErrInvalidStatus", err)
+	}
+}
+
+func TestDeriveStatus(t *testing.T) {
+	items := func(codes ...int) []Item {
+		list := make([]Item, len(codes))
+		for i, code := range codes {
+			list[i].Status = code
+		}
+		return list
+	}
+	tests := []struct {
+		items []Item
+		want  OrderStatus
+	}{
+		{nil, StatusCreated},
+		{items(0, 999), StatusCreated},
+		{items(150), StatusCreated},
+		{items(200), StatusPaid},
+		{items(202), StatusAssembling},
+		{items(301, 202), StatusAssembling},
+		{items(401, 301), StatusShipped},
+		{items(410), StatusDelivered},
+		{items(500, 410), StatusDelivered},
+		{items(500, 600), StatusReturned},
+		{items(500, 0), StatusCancelled},
+	}
+	for _, tt := range tests {
+		if got := DeriveStatus(tt.items); got != tt.want {
+			t.Errorf("DeriveStatus(%v) = %s, want %s", tt.items, got, tt.want)
+		}
+	}
+}
+
+// testStatusLifecycle checks that a new order takes its status from its items
+// whatever status it was sent with, and then only changes through allowed
+// transitions, each recorded in the history.
+func testStatusLifecycle(t *testing.T, store Store) {
+	ctx := context.Background()
+	order := testOrder("status")
+	order.Status = StatusDelivered
+	saveTestOrders(t, store, order)
+
+	stored, err := store.GetOrderByUIDContext(ctx, "status")
+	if err != nil {
+		t.Fatalf("get: %v", err)
+	}
+	if stored.Status != StatusAssembling {
+		t.Fatalf("new order status = %s, want %s from item status 202", stored.Status, StatusAssembling)
+	}
+
+	if _, err := store.UpdateOrderStatusContext(ctx, "status", StatusShipped, "picked up"); err != nil {
+		t.Fatalf("assembling -> shipped: %v", err)
+	}
+	var terr *TransitionError
+	if _, err := store.UpdateOrderStatusContext(ctx, "status", StatusPaid, ""); !errors.As(err, &terr) {
+		t.Errorf("shipped -> paid: err = %v, want a TransitionError", err)
+	}
+	if _, err := store.UpdateOrderStatusContext(ctx, "status", "lost", ""); !errors.Is(err, ErrInvalidStatus) {
+		t.Errorf("shipped -> lost: err = %v, want ErrInvalidStatus", err)
+	}
+	if change, err := store.UpdateOrderStatusContext(ctx, "status", StatusShipped, ""); err != nil || change.From != change.To {
+		t.Errorf("shipped -> shipped = %+v, %v; want a no-op", change, err)
+	}
+	if _, err := store.UpdateOrderStatusContext(ctx, "missing", StatusPaid, ""); !errors.Is(err, ErrOrderNotFound) {
+		t.Errorf("missing order: err = %v, want ErrOrderNotFound", err)
+	}
+
+	// Saving the order again does not derive its status anew.
+	order = testOrder("status")
+	order.Status = StatusCreated
+	saveTestOrders(t, store, order)
+	if stored, _ := store.GetOrderByUIDContext(ctx, "status"); stored.Status != StatusShipped {
+		t.Errorf("status after resave = %s, want %s", stored.Status, StatusShipped)
+	}
+
+	history, err := store.GetStatusHistoryContext(ctx, "status")
+	if err != nil {
+		t.Fatalf("history: %v", err)
+	}
+	var got []string
+	for _, h := range history {
+		got = append(got, fmt.Sprintf("%s>%s:%s", h.From, h.To, h.Reason))
+	}
+	if want := "[>assembling:created assembling>shipped:picked up]"; fmt.Sprint(got) != want {
+		t.Errorf("history = %v, want %s", got, want)
+	}
+}
+
+func TestMemoryStoreStatusLifecycle(t *testing.T) {
+	testStatusLifecycle(t, NewMemoryStore())
+}
+
+func TestDatabaseStatusLifecycle(t *testing.T) {
+	testStatusLifecycle(t, openTestDB(t))
+}
@@ -0,0 +1,20 @@
+package db
+
+import "context"
+
+type Store interface {
+	SaveOrderContext(ctx context.Context, order *Order) error
+	CreateOrderContext(ctx context.Context, order *Order) error
+	GetOrderByUIDContext(ctx context.Context, uid string) (*Order, error)
+	GetAllOrdersContext(ctx context.Context) ([]Order, error)
+	DeleteOrderContext(ctx context.Context, uid string) error
+	OrderExistsContext(ctx context.Context, uid string) (bool, error)
+	HoldsOrderContext(ctx context.Context, uid string) (bool, error)
+	UpdateOrderStatusContext(ctx context.Context, uid string, to OrderStatus, reason string) (*StatusChange, error)
+	GetStatusHistoryContext(ctx context.Context, uid string) ([]StatusChange, error)
+	UpdateDeliveryContext(ctx context.Context, uid string, patch DeliveryPatch) (*Order, error)
+	ListOrderRefsContext(ctx context.Context, filter OrderFilter) ([]OrderRef, error)
+	GetOrdersByUIDsContext(ctx context.Context, uids []string) ([]Order, error)
+	GetCustomerSummaryContext(ctx context.Context, customerID string, top int) (*CustomerSummary, error)
+	Close() error
+}
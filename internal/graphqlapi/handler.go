@@ -0,0 +1,107 @@
+// Package graphqlapi serves a read-only GraphQL schema over orders, their
+// deliveries, payments and items.
+package graphqlapi
+
+import (
+	"context"
+	"encoding/json"
+	"log"
+	"net/http"
+	"order-service/internal/cache"
+	"order-service/internal/db"
+
+	"github.com/graphql-go/graphql"
+	"github.com/graphql-go/graphql/gqlerrors"
+	"github.com/graphql-go/graphql/language/parser"
+	"github.com/graphql-go/graphql/language/source"
+)
+
+type Handler struct {
+	schema graphql.Schema
+	cache  *cache.Cache
+	db     db.Store
+	limits Limits
+}
+
+func NewHandler(cache *cache.Cache, store db.Store, limits Limits) (*Handler, error) {
+	schema, err := newSchema(store, limits.MaxPageSize)
+	if err != nil {
+		return nil, err
+	}
+	return &Handler{schema: schema, cache: cache, db: store, limits: limits}, nil
+}
+
+type request struct {
+	Query         string         `json:"query"`
+	Variables     map[string]any `json:"variables"`
+	OperationName string         `json:"operationName"`
+}
+
+// ServeHTTP accepts POST with a JSON body or GET with query, variables and
+// operationName parameters. Queries that do not parse, fail validation or
+// exceed the limits are rejected with 400 before anything is loaded.
+func (h *Handler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	var req request
+	switch r.Method {
+	case http.MethodGet:
+		req.Query = r.URL.Query().Get("query")
+		req.OperationName = r.URL.Query().Get("operationName")
+		if v := r.URL.Query().Get("variables"); v != "" {
+			if err := json.Unmarshal([]byte(v), &req.Variables); err != nil {
+				respond(w, http.StatusBadRequest, errorResult("invalid variables"))
+				return
+			}
+		}
+	case http.MethodPost:
+		if err := json.NewDecoder(http.MaxBytesReader(w, r.Body, 1<<20)).Decode(&req); err != nil {
+			respond(w, http.StatusBadRequest, errorResult("invalid request body"))
+			return
+		}
+	default:
+		w.Header().Set("Allow", "GET, POST")
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	doc, err := parser.Parse(parser.ParseParams{Source: source.NewSource(&source.Source{
+		Body: []byte(req.Query),
+		Name: "GraphQL request",
+	})})
+	if err != nil {
+		respond(w, http.StatusBadRequest, &graphql.Result{Errors: gqlerrors.FormatErrors(err)})
+		return
+	}
+	if vr := graphql.ValidateDocument(&h.schema, doc, nil); !vr.IsValid {
+		respond(w, http.StatusBadRequest, &graphql.Result{Errors: vr.Errors})
+		return
+	}
+	if err := checkLimits(&h.schema, doc, req.Variables, h.limits); err != nil {
+		respond(w, http.StatusBadRequest, errorResult(err.Error()))
+		return
+	}
+
+	ctx := context.WithValue(r.Context(), loaderKey{}, newOrderLoader(h.cache, h.db))
+	result := graphql.Execute(graphql.ExecuteParams{
+		Schema:        h.schema,
+		AST:           doc,
+		OperationName: req.OperationName,
+		Args:          req.Variables,
+		Context:       ctx,
+	})
+	if result.HasErrors() {
+		log.Printf("GraphQL query failed: %v", result.Errors)
+	}
+	respond(w, http.StatusOK, result)
+}
+
+func errorResult(message string) *graphql.Result {
+	return &graphql.Result{Errors: []gqlerrors.FormattedError{gqlerrors.NewFormattedError(message)}}
+}
+
+func respond(w http.ResponseWriter, status int, result *graphql.Result) {
+	w.Header().Set("Content-type", "application/json")
+	w.WriteHeader(status)
+	if err := json.NewEncoder(w).Encode(result); err != nil {
+		log.Printf("Failed to encode GraphQL response: %v", err)
+	}
+}
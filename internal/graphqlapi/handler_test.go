@@ -0,0 +1,185 @@
+package graphqlapi
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"order-service/internal/cache"
+	"order-service/internal/db"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/graphql-go/graphql/language/parser"
+	"github.com/graphql-go/graphql/language/source"
+)
+
+// countingStore counts the store calls a query causes.
+type countingStore struct {
+	*db.MemoryStore
+	lists, batches, gets int
+}
+
+func (c *countingStore) ListOrderRefsContext(ctx context.Context, filter db.OrderFilter) ([]db.OrderRef, error) {
+	c.lists++
+	return c.MemoryStore.ListOrderRefsContext(ctx, filter)
+}
+
+func (c *countingStore) GetOrdersByUIDsContext(ctx context.Context, uids []string) ([]db.Order, error) {
+	c.batches++
+	return c.MemoryStore.GetOrdersByUIDsContext(ctx, uids)
+}
+
+func (c *countingStore) GetOrderByUIDContext(ctx context.Context, uid string) (*db.Order, error) {
+	c.gets++
+	return c.MemoryStore.GetOrderByUIDContext(ctx, uid)
+}
+
+var testLimits = Limits{MaxDepth: 4, MaxComplexity: 200, MaxPageSize: 50}
+
+// newTestHandler stores n orders o0..o(n-1), each an hour newer than the one
+// before.
+func newTestHandler(t *testing.T, n int, limits Limits) (*Handler, *countingStore) {
+	t.Helper()
+	store := &countingStore{MemoryStore: db.NewMemoryStore()}
+	base := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	for i := range n {
+		order := &db.Order{
+			OrderUID:    fmt.Sprintf("o%d", i),
+			CustomerID:  "c",
+			Delivery:    db.Delivery{City: "Moscow"},
+			Items:       []db.Item{{Name: "item"}},
+			DateCreated: base.Add(time.Duration(i) * time.Hour),
+		}
+		if err := store.SaveOrderContext(context.Background(), order); err != nil {
+			t.Fatal(err)
+		}
+	}
+	h, err := NewHandler(cache.NewCache(), store, limits)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return h, store
+}
+
+type result struct {
+	Data   map[string]json.RawMessage `json:"data"`
+	Errors []struct {
+		Message string `json:"message"`
+	} `json:"errors"`
+}
+
+func query(t *testing.T, h *Handler, q string, vars map[string]any) (int, result) {
+	t.Helper()
+	body, _ := json.Marshal(map[string]any{"query": q, "variables": vars})
+	rec := httptest.NewRecorder()
+	h.ServeHTTP(rec, httptest.NewRequest(http.MethodPost, "/graphql", strings.NewReader(string(body))))
+	var res result
+	if err := json.Unmarshal(rec.Body.Bytes(), &res); err != nil {
+		t.Fatalf("decode response %s: %v", rec.Body, err)
+	}
+	return rec.Code, res
+}
+
+func TestQueriesOverTheLimitsAreRejected(t *testing.T) {
+	tests := []struct {
+		name  string
+		query string
+		vars  map[string]any
+		want  string
+	}{
+		{"too deep", `{ orders { nodes { items { name } delivery { city } } page_info { end_cursor } } }`, nil, "depth exceeds the limit of 3"},
+		{"too deep through a fragment", `{ orders { nodes { ...o } } } fragment o on Order { items { name } }`, nil, "depth exceeds the limit of 3"},
+		{"introspection too deep", `{ __schema { types { fields { name } } } }`, nil, "depth exceeds the limit of 3"},
+		{"too complex", `{ orders(first: 50) { nodes { order_uid customer_id status } } }`, nil, "complexity 152 exceeds the limit of 100"},
+		{"too complex through a variable", `query($n: Int) { orders(first: $n) { nodes { order_uid customer_id status } } }`, map[string]any{"n": 40}, "complexity 122 exceeds the limit of 100"},
+		{"page size capped before costing", `{ orders(first: 1000) { nodes { order_uid customer_id } } }`, nil, "complexity 102 exceeds the limit of 100"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			h, store := newTestHandler(t, 3, Limits{MaxDepth: 3, MaxComplexity: 100, MaxPageSize: 50})
+			code, res := query(t, h, tt.query, tt.vars)
+			if code != http.StatusBadRequest || len(res.Errors) != 1 || !strings.Contains(res.Errors[0].Message, tt.want) {
+				t.Errorf("got %d %+v, want 400 with %q", code, res.Errors, tt.want)
+			}
+			if store.lists+store.batches+store.gets != 0 {
+				t.Error("rejected query reached the store")
+			}
+		})
+	}
+
+	h, _ := newTestHandler(t, 3, Limits{MaxDepth: 3, MaxComplexity: 100, MaxPageSize: 50})
+	if code, res := query(t, h, `{ orders(first: 10) { nodes { order_uid } } }`, nil); code != http.StatusOK || len(res.Errors) != 0 {
+		t.Errorf("query within the limits: %d %+v", code, res.Errors)
+	}
+}
+
+func TestQueryCost(t *testing.T) {
+	schema, err := newSchema(db.NewMemoryStore(), 50)
+	if err != nil {
+		t.Fatal(err)
+	}
+	tests := []struct {
+		name  string
+		query string
+		want  int
+	}{
+		{"page", `{ orders(first: 5) { nodes { order_uid } page_info { has_next_page } } }`, 9},
+		{"default page", `{ orders { nodes { order_uid } } }`, 22},
+		{"aliased page", `{ recent: orders(first: 5) { nodes { order_uid } } }`, 7},
+		{"unpaged list", `{ order(order_uid: "o0") { items { name price } } }`, 22},
+		{"unpaged list in a page", `{ orders(first: 5) { nodes { items { name } } } }`, 57},
+		{"fragment", `{ orders(first: 2) { nodes { ...o } } } fragment o on Order { order_uid delivery { city } }`, 8},
+		{"typename", `{ __typename order(order_uid: "o0") { __typename } }`, 3},
+		{"introspection", `{ __schema { types { name fields { name } } } }`, 122},
+	}
+	for _, tt := range tests {
+		doc, err := parser.Parse(parser.ParseParams{Source: source.NewSource(&source.Source{Body: []byte(tt.query)})})
+		if err != nil {
+			t.Fatalf("%s: %v", tt.name, err)
+		}
+		err = checkLimits(&schema, doc, nil, Limits{MaxDepth: 10, MaxPageSize: 50})
+		if want := fmt.Sprintf("query complexity %d exceeds the limit of 0", tt.want); err == nil || err.Error() != want {
+			t.Errorf("%s: err = %v, want %q", tt.name, err, want)
+		}
+	}
+}
+
+func TestOrdersAreLoadedInOneStoreCall(t *testing.T) {
+	h, store := newTestHandler(t, 10, testLimits)
+	code, res := query(t, h, `{ orders(first: 10) { nodes { order_uid delivery { city } items { name } } page_info { has_next_page } } }`, nil)
+	if code != http.StatusOK || len(res.Errors) != 0 {
+		t.Fatalf("query: %d %+v", code, res.Errors)
+	}
+	var orders struct {
+		Nodes []struct {
+			OrderUID string `json:"order_uid"`
+		} `json:"nodes"`
+	}
+	json.Unmarshal(res.Data["orders"], &orders)
+	if len(orders.Nodes) != 10 || orders.Nodes[0].OrderUID != "o9" {
+		t.Errorf("nodes = %+v, want 10 orders newest first", orders.Nodes)
+	}
+	if store.lists != 1 || store.batches != 1 || store.gets != 0 {
+		t.Errorf("store calls: %d lists, %d batch loads, %d single gets; want 1, 1, 0", store.lists, store.batches, store.gets)
+	}
+}
+
+func TestAliasedOrdersShareOneStoreCall(t *testing.T) {
+	h, store := newTestHandler(t, 3, testLimits)
+	order, _ := store.MemoryStore.GetOrderByUIDContext(context.Background(), "o0")
+	h.cache.Set(order)
+
+	code, res := query(t, h, `{ a: order(order_uid: "o0") { order_uid } b: order(order_uid: "o1") { order_uid } c: order(order_uid: "o2") { order_uid } d: order(order_uid: "nope") { order_uid } }`, nil)
+	if code != http.StatusOK || len(res.Errors) != 0 {
+		t.Fatalf("query: %d %+v", code, res.Errors)
+	}
+	if string(res.Data["d"]) != "null" || !strings.Contains(string(res.Data["c"]), "o2") {
+		t.Errorf("data = %s", res.Data)
+	}
+	if store.batches != 1 || store.gets != 0 {
+		t.Errorf("store calls: %d batch loads, %d single gets; want 1, 0", store.batches, store.gets)
+	}
+}
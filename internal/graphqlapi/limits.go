@@ -0,0 +1,205 @@
+package graphqlapi
+
+import (
+	"fmt"
+	"slices"
+	"strconv"
+
+	"github.com/graphql-go/graphql"
+	"github.com/graphql-go/graphql/language/ast"
+)
+
+// Limits bound the work one query may cause before it is executed.
+type Limits struct {
+	MaxDepth      int
+	MaxComplexity int
+	MaxPageSize   int
+}
+
+// unboundedListSize is the number of elements assumed for a list the schema
+// does not page, such as an order's items or the introspection type lists.
+const unboundedListSize = 10
+
+// checkLimits walks every operation in doc against schema, introspection
+// fields included. Each field costs 1 and the fields selected under a list
+// cost once per element: a list below a field with a first argument holds a
+// page of that size, any other list unboundedListSize elements. The document
+// must already have passed validation.
+func checkLimits(schema *graphql.Schema, doc *ast.Document, vars map[string]any, limits Limits) error {
+	fragments := make(map[string]*ast.FragmentDefinition)
+	for _, def := range doc.Definitions {
+		if frag, ok := def.(*ast.FragmentDefinition); ok {
+			fragments[frag.Name.Value] = frag
+		}
+	}
+
+	w := &limitWalker{schema: schema, fragments: fragments, vars: vars, limits: limits}
+	for _, def := range doc.Definitions {
+		op, ok := def.(*ast.OperationDefinition)
+		if !ok {
+			continue
+		}
+		var root graphql.Type = schema.QueryType()
+		switch op.Operation {
+		case ast.OperationTypeMutation:
+			root = schema.MutationType()
+		case ast.OperationTypeSubscription:
+			root = schema.SubscriptionType()
+		}
+		cost, err := w.cost(op.SelectionSet, root, 0, 0, nil)
+		if err != nil {
+			return err
+		}
+		if cost > limits.MaxComplexity {
+			return fmt.Errorf("query complexity %d exceeds the limit of %d", cost, limits.MaxComplexity)
+		}
+	}
+	return nil
+}
+
+type limitWalker struct {
+	schema    *graphql.Schema
+	fragments map[string]*ast.FragmentDefinition
+	vars      map[string]any
+	limits    Limits
+}
+
+// cost returns the cost of set selected on parent. page is the page size set
+// by the nearest field above with a first argument, or 0 when there is none
+// or a list has already used it.
+func (w *limitWalker) cost(set *ast.SelectionSet, parent graphql.Type, depth, page int, visiting []string) (int, error) {
+	if set == nil {
+		return 0, nil
+	}
+
+	total := 0
+	for _, sel := range set.Selections {
+		switch s := sel.(type) {
+		case *ast.Field:
+			if depth+1 > w.limits.MaxDepth {
+				return 0, fmt.Errorf("query depth exceeds the limit of %d", w.limits.MaxDepth)
+			}
+			def := fieldDefinition(parent, s.Name.Value)
+			if def == nil {
+				continue
+			}
+			childPage := page
+			if hasArgument(def, "first") {
+				childPage = pageSize(w.intArgument(s, "first"), w.limits.MaxPageSize)
+			}
+			multiplier := 1
+			if isList(def.Type) {
+				multiplier = unboundedListSize
+				if childPage > 0 {
+					multiplier = childPage
+				}
+				childPage = 0
+			}
+			child, err := w.cost(s.SelectionSet, namedType(def.Type), depth+1, childPage, visiting)
+			if err != nil {
+				return 0, err
+			}
+			total += 1 + multiplier*child
+		case *ast.InlineFragment:
+			child, err := w.cost(s.SelectionSet, w.typeCondition(s.TypeCondition, parent), depth, page, visiting)
+			if err != nil {
+				return 0, err
+			}
+			total += child
+		case *ast.FragmentSpread:
+			name := s.Name.Value
+			frag, ok := w.fragments[name]
+			if !ok || slices.Contains(visiting, name) {
+				// Validation reports unknown and cyclic fragments.
+				continue
+			}
+			child, err := w.cost(frag.SelectionSet, w.typeCondition(frag.TypeCondition, parent), depth, page, append(slices.Clip(visiting), name))
+			if err != nil {
+				return 0, err
+			}
+			total += child
+		}
+	}
+	return total, nil
+}
+
+func (w *limitWalker) typeCondition(cond *ast.Named, parent graphql.Type) graphql.Type {
+	if cond == nil {
+		return parent
+	}
+	return w.schema.Type(cond.Name.Value)
+}
+
+// fielder is implemented by the object and interface types.
+type fielder interface {
+	Fields() graphql.FieldDefinitionMap
+}
+
+// fieldDefinition looks name up on parent, including the introspection
+// fields every query may select.
+func fieldDefinition(parent graphql.Type, name string) *graphql.FieldDefinition {
+	switch name {
+	case graphql.SchemaMetaFieldDef.Name:
+		return graphql.SchemaMetaFieldDef
+	case graphql.TypeMetaFieldDef.Name:
+		return graphql.TypeMetaFieldDef
+	case graphql.TypeNameMetaFieldDef.Name:
+		return graphql.TypeNameMetaFieldDef
+	}
+	if t, ok := parent.(fielder); ok {
+		return t.Fields()[name]
+	}
+	return nil
+}
+
+func hasArgument(def *graphql.FieldDefinition, name string) bool {
+	for _, arg := range def.Args {
+		if arg.Name() == name {
+			return true
+		}
+	}
+	return false
+}
+
+// namedType strips the list and non-null wrappers off t.
+func namedType(t graphql.Type) graphql.Type {
+	for {
+		switch wrapper := t.(type) {
+		case *graphql.NonNull:
+			t = wrapper.OfType
+		case *graphql.List:
+			t = wrapper.OfType
+		default:
+			return t
+		}
+	}
+}
+
+func isList(t graphql.Type) bool {
+	if nonNull, ok := t.(*graphql.NonNull); ok {
+		t = nonNull.OfType
+	}
+	_, ok := t.(*graphql.List)
+	return ok
+}
+
+func (w *limitWalker) intArgument(field *ast.Field, name string) int {
+	for _, arg := range field.Arguments {
+		if arg.Name.Value != name {
+			continue
+		}
+		switch v := arg.Value.(type) {
+		case *ast.IntValue:
+			n, _ := strconv.Atoi(v.Value)
+			return n
+		case *ast.Variable:
+			switch n := w.vars[v.Name.Value].(type) {
+			case float64:
+				return int(n)
+			case int:
+				return n
+			}
+		}
+	}
+	return 0
+}
@@ -0,0 +1,82 @@
+package graphqlapi
+
+import (
+	"context"
+	"order-service/internal/cache"
+	"order-service/internal/db"
+	"sync"
+)
+
+// orderLoader batches order lookups made while resolving one request. Load
+// only queues the UID and returns a thunk; graphql-go runs thunks after the
+// whole level is resolved, so the first thunk fetches every queued order in
+// one store call instead of one query per order.
+type orderLoader struct {
+	cache *cache.Cache
+	db    db.Store
+
+	mu      sync.Mutex
+	pending []string
+	orders  map[string]*db.Order
+	err     error
+}
+
+func newOrderLoader(cache *cache.Cache, store db.Store) *orderLoader {
+	return &orderLoader{cache: cache, db: store, orders: make(map[string]*db.Order)}
+}
+
+func (l *orderLoader) Load(ctx context.Context, uid string) func() (any, error) {
+	l.mu.Lock()
+	if _, ok := l.orders[uid]; !ok {
+		l.pending = append(l.pending, uid)
+	}
+	l.mu.Unlock()
+
+	return func() (any, error) {
+		l.mu.Lock()
+		defer l.mu.Unlock()
+		if _, ok := l.orders[uid]; !ok && l.err == nil {
+			l.flush(ctx)
+		}
+		if l.err != nil {
+			return nil, l.err
+		}
+		if order := l.orders[uid]; order != nil {
+			return order, nil
+		}
+		return nil, nil
+	}
+}
+
+// flush resolves every pending UID, cache first. UIDs the store does not
+// know are recorded as nil so they are not fetched again.
+func (l *orderLoader) flush(ctx context.Context) {
+	var missing []string
+	for _, uid := range l.pending {
+		if _, ok := l.orders[uid]; ok {
+			continue
+		}
+		if order, ok := l.cache.Get(uid); ok {
+			l.orders[uid] = order
+			continue
+		}
+		l.orders[uid] = nil
+		missing = append(missing, uid)
+	}
+	l.pending = l.pending[:0]
+	if len(missing) == 0 {
+		return
+	}
+
+	orders, err := l.db.GetOrdersByUIDsContext(ctx, missing)
+	if err != nil {
+		for _, uid := range missing {
+			delete(l.orders, uid)
+		}
+		l.err = err
+		return
+	}
+	for i := range orders {
+		l.orders[orders[i].OrderUID] = &orders[i]
+	}
+}
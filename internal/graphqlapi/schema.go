@@ -0,0 +1,206 @@
+package graphqlapi
+
+import (
+	"context"
+	"order-service/internal/db"
+	"time"
+
+	"github.com/graphql-go/graphql"
+)
+
+const defaultPageSize = 20
+
+type loaderKey struct{}
+
+func loaderFrom(ctx context.Context) *orderLoader {
+	return ctx.Value(loaderKey{}).(*orderLoader)
+}
+
+// Field names follow the JSON returned by GET /order/{uid}, so the default
+// resolver reads them straight from the db structs by their json tags.
+var deliveryType = graphql.NewObject(graphql.ObjectConfig{
+	Name: "Delivery",
+	Fields: graphql.Fields{
+		"name":    &graphql.Field{Type: graphql.String},
+		"phone":   &graphql.Field{Type: graphql.String},
+		"zip":     &graphql.Field{Type: graphql.String},
+		"city":    &graphql.Field{Type: graphql.String},
+		"address": &graphql.Field{Type: graphql.String},
+		"region":  &graphql.Field{Type: graphql.String},
+		"email":   &graphql.Field{Type: graphql.String},
+	},
+})
+
+var paymentType = graphql.NewObject(graphql.ObjectConfig{
+	Name: "Payment",
+	Fields: graphql.Fields{
+		"transaction":   &graphql.Field{Type: graphql.String},
+		"request_id":    &graphql.Field{Type: graphql.String},
+		"currency":      &graphql.Field{Type: graphql.String},
+		"provider":      &graphql.Field{Type: graphql.String},
+		"amount":        &graphql.Field{Type: graphql.Int},
+		"payment_dt":    &graphql.Field{Type: graphql.Int},
+		"bank":          &graphql.Field{Type: graphql.String},
+		"delivery_cost": &graphql.Field{Type: graphql.Int},
+		"goods_total":   &graphql.Field{Type: graphql.Int},
+		"custom_fee":    &graphql.Field{Type: graphql.Int},
+	},
+})
+
+var itemType = graphql.NewObject(graphql.ObjectConfig{
+	Name: "Item",
+	Fields: graphql.Fields{
+		"chrt_id":      &graphql.Field{Type: graphql.Int},
+		"track_number": &graphql.Field{Type: graphql.String},
+		"price":        &graphql.Field{Type: graphql.Int},
+		"rid":          &graphql.Field{Type: graphql.String},
+		"name":         &graphql.Field{Type: graphql.String},
+		"sale":         &graphql.Field{Type: graphql.Int},
+		"size":         &graphql.Field{Type: graphql.String},
+		"total_price":  &graphql.Field{Type: graphql.Int},
+		"nm_id":        &graphql.Field{Type: graphql.Int},
+		"brand":        &graphql.Field{Type: graphql.String},
+		"status":       &graphql.Field{Type: graphql.Int},
+	},
+})
+
+var orderType = graphql.NewObject(graphql.ObjectConfig{
+	Name: "Order",
+	Fields: graphql.Fields{
+		"order_uid":          &graphql.Field{Type: graphql.NewNonNull(graphql.String)},
+		"track_number":       &graphql.Field{Type: graphql.String},
+		"entry":              &graphql.Field{Type: graphql.String},
+		"delivery":           &graphql.Field{Type: deliveryType},
+		"payment":            &graphql.Field{Type: paymentType},
+		"items":              &graphql.Field{Type: graphql.NewList(graphql.NewNonNull(itemType))},
+		"locale":             &graphql.Field{Type: graphql.String},
+		"internal_signature": &graphql.Field{Type: graphql.String},
+		"customer_id":        &graphql.Field{Type: graphql.String},
+		"delivery_service":   &graphql.Field{Type: graphql.String},
+		"shardkey":           &graphql.Field{Type: graphql.String},
+		"sm_id":              &graphql.Field{Type: graphql.Int},
+		"date_created":       &graphql.Field{Type: graphql.DateTime},
+		"oof_shard":          &graphql.Field{Type: graphql.String},
+		"status":             &graphql.Field{Type: graphql.String},
+	},
+})
+
+var pageInfoType = graphql.NewObject(graphql.ObjectConfig{
+	Name: "PageInfo",
+	Fields: graphql.Fields{
+		"end_cursor":    &graphql.Field{Type: graphql.String},
+		"has_next_page": &graphql.Field{Type: graphql.NewNonNull(graphql.Boolean)},
+	},
+})
+
+var orderConnectionType = graphql.NewObject(graphql.ObjectConfig{
+	Name: "OrderConnection",
+	Fields: graphql.Fields{
+		"nodes":     &graphql.Field{Type: graphql.NewNonNull(graphql.NewList(orderType))},
+		"page_info": &graphql.Field{Type: graphql.NewNonNull(pageInfoType)},
+	},
+})
+
+var orderFilterType = graphql.NewInputObject(graphql.InputObjectConfig{
+	Name: "OrderFilter",
+	Fields: graphql.InputObjectConfigFieldMap{
+		"customer_id":      &graphql.InputObjectFieldConfig{Type: graphql.String},
+		"delivery_service": &graphql.InputObjectFieldConfig{Type: graphql.String},
+		"status":           &graphql.InputObjectFieldConfig{Type: graphql.String},
+		"created_from":     &graphql.InputObjectFieldConfig{Type: graphql.DateTime},
+		"created_to":       &graphql.InputObjectFieldConfig{Type: graphql.DateTime},
+	},
+})
+
+type connection struct {
+	Nodes    []any    `json:"nodes"`
+	PageInfo pageInfo `json:"page_info"`
+}
+
+type pageInfo struct {
+	EndCursor   string `json:"end_cursor,omitempty"`
+	HasNextPage bool   `json:"has_next_page"`
+}
+
+func newSchema(store db.Store, maxPageSize int) (graphql.Schema, error) {
+	query := graphql.NewObject(graphql.ObjectConfig{
+		Name: "Query",
+		Fields: graphql.Fields{
+			"order": &graphql.Field{
+				Type: orderType,
+				Args: graphql.FieldConfigArgument{
+					"order_uid": &graphql.ArgumentConfig{Type: graphql.NewNonNull(graphql.String)},
+				},
+				Resolve: func(p graphql.ResolveParams) (any, error) {
+					return loaderFrom(p.Context).Load(p.Context, p.Args["order_uid"].(string)), nil
+				},
+			},
+			"orders": &graphql.Field{
+				Type:        graphql.NewNonNull(orderConnectionType),
+				Description: "Orders newest first. Pass page_info.end_cursor as after to get the next page.",
+				Args: graphql.FieldConfigArgument{
+					"filter": &graphql.ArgumentConfig{Type: orderFilterType},
+					"first":  &graphql.ArgumentConfig{Type: graphql.Int, DefaultValue: defaultPageSize},
+					"after":  &graphql.ArgumentConfig{Type: graphql.String},
+				},
+				Resolve: func(p graphql.ResolveParams) (any, error) {
+					return resolveOrders(p, store, maxPageSize)
+				},
+			},
+		},
+	})
+	return graphql.NewSchema(graphql.SchemaConfig{Query: query})
+}
+
+func resolveOrders(p graphql.ResolveParams, store db.Store, maxPageSize int) (any, error) {
+	filter := db.OrderFilter{Limit: pageSize(p.Args["first"].(int), maxPageSize) + 1}
+	if f, ok := p.Args["filter"].(map[string]any); ok {
+		filter.CustomerID, _ = f["customer_id"].(string)
+		filter.DeliveryService, _ = f["delivery_service"].(string)
+		status, _ := f["status"].(string)
+		filter.Status = db.OrderStatus(status)
+		if t, ok := f["created_from"].(time.Time); ok {
+			filter.CreatedFrom = t
+		}
+		if t, ok := f["created_to"].(time.Time); ok {
+			filter.CreatedTo = t
+		}
+	}
+	if after, ok := p.Args["after"].(string); ok && after != "" {
+		ref, err := db.ParseCursor(after)
+		if err != nil {
+			return nil, err
+		}
+		filter.After = ref
+	}
+
+	refs, err := store.ListOrderRefsContext(p.Context, filter)
+	if err != nil {
+		return nil, err
+	}
+
+	conn := &connection{Nodes: []any{}}
+	if len(refs) == filter.Limit {
+		refs = refs[:len(refs)-1]
+		conn.PageInfo.HasNextPage = true
+	}
+	loader := loaderFrom(p.Context)
+	for _, ref := range refs {
+		conn.Nodes = append(conn.Nodes, loader.Load(p.Context, ref.UID))
+	}
+	if len(refs) > 0 {
+		conn.PageInfo.EndCursor = refs[len(refs)-1].Cursor()
+	}
+	return conn, nil
+}
+
+// pageSize applies the default to a missing page size and caps it.
+func pageSize(first, max int) int {
+	if first <= 0 {
+		first = defaultPageSize
+	}
+	if first > max {
+		first = max
+	}
+	return first
+}
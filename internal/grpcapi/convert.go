@@ -0,0 +1,30 @@
+package grpcapi
+
+import (
+	"order-service/internal/codec"
+	"order-service/internal/stream"
+	ordersv1 "order-service/proto/ordersv1"
+
+	"google.golang.org/protobuf/types/known/timestamppb"
+)
+
+func toProtoEvent(e stream.Event) *ordersv1.OrderEvent {
+	event := &ordersv1.OrderEvent{
+		Id:       e.ID,
+		Type:     e.Type,
+		OrderUid: e.OrderUID,
+		Time:     timestamppb.New(e.Time),
+	}
+	if e.Order != nil {
+		event.Order = codec.ToProto(e.Order)
+	}
+	if e.Status != nil {
+		event.Status = &ordersv1.StatusChange{
+			From:      string(e.Status.From),
+			To:        string(e.Status.To),
+			Reason:    e.Status.Reason,
+			ChangedAt: timestamppb.New(e.Status.ChangedAt),
+		}
+	}
+	return event
+}
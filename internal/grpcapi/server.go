@@ -0,0 +1,231 @@
+// Package grpcapi serves the orders.v1.OrderService gRPC API from the same
+// cache and store as the HTTP handlers.
+package grpcapi
+
+//go:generate protoc -I ../../proto --go_out=../.. --go_opt=module=order-service --go-grpc_out=../.. --go-grpc_opt=module=order-service order.proto order_service.proto
+
+import (
+	"context"
+	"errors"
+	"log"
+	"order-service/internal/cache"
+	"order-service/internal/codec"
+	"order-service/internal/db"
+	"order-service/internal/stream"
+	ordersv1 "order-service/proto/ordersv1"
+	"slices"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/health"
+	healthpb "google.golang.org/grpc/health/grpc_health_v1"
+	"google.golang.org/grpc/reflection"
+	"google.golang.org/grpc/status"
+)
+
+// listPageSize is the number of orders ListOrders loads per store query.
+const listPageSize = 100
+
+type Server struct {
+	ordersv1.UnimplementedOrderServiceServer
+	cache    *cache.Cache
+	db       db.Store
+	hub      *stream.Hub
+	maxBatch int
+}
+
+// NewServer returns a gRPC server with the order service, the standard
+// health service and server reflection registered. maxBatch bounds
+// BatchGetOrders, as BATCH_MAX_SIZE does for the HTTP batch endpoint.
+func NewServer(cache *cache.Cache, db db.Store, hub *stream.Hub, maxBatch int) *grpc.Server {
+	srv := grpc.NewServer()
+	ordersv1.RegisterOrderServiceServer(srv, &Server{cache: cache, db: db, hub: hub, maxBatch: maxBatch})
+
+	healthServer := health.NewServer()
+	healthServer.SetServingStatus(ordersv1.OrderService_ServiceDesc.ServiceName, healthpb.HealthCheckResponse_SERVING)
+	healthpb.RegisterHealthServer(srv, healthServer)
+	reflection.Register(srv)
+	return srv
+}
+
+func (s *Server) GetOrder(ctx context.Context, req *ordersv1.GetOrderRequest) (*ordersv1.Order, error) {
+	if req.GetOrderUid() == "" {
+		return nil, status.Error(codes.InvalidArgument, "order_uid is required")
+	}
+	order, err := s.getOrder(ctx, req.GetOrderUid())
+	if err != nil {
+		return nil, err
+	}
+	return codec.ToProto(order), nil
+}
+
+// BatchGetOrders serves what it can from the cache and loads the rest with a
+// single store query. Duplicate UIDs are answered once.
+func (s *Server) BatchGetOrders(ctx context.Context, req *ordersv1.BatchGetOrdersRequest) (*ordersv1.BatchGetOrdersResponse, error) {
+	var uids []string
+	seen := make(map[string]bool, len(req.GetOrderUids()))
+	for _, uid := range req.GetOrderUids() {
+		if uid != "" && !seen[uid] {
+			seen[uid] = true
+			uids = append(uids, uid)
+		}
+	}
+	if len(uids) > s.maxBatch {
+		return nil, status.Errorf(codes.InvalidArgument, "at most %d order_uids per call", s.maxBatch)
+	}
+
+	resp := &ordersv1.BatchGetOrdersResponse{Orders: make(map[string]*ordersv1.Order, len(uids))}
+	var uncached []string
+	for _, uid := range uids {
+		if order, ok := s.cache.Get(uid); ok {
+			resp.Orders[uid] = codec.ToProto(order)
+			continue
+		}
+		uncached = append(uncached, uid)
+	}
+	if len(uncached) > 0 {
+		orders, err := s.db.GetOrdersByUIDsContext(ctx, uncached)
+		if err != nil {
+			log.Printf("Failed to get %d orders over gRPC: %v", len(uncached), err)
+			return nil, status.Error(codes.Internal, "failed to get orders")
+		}
+		for i := range orders {
+			order := &orders[i]
+			s.cache.Set(order)
+			resp.Orders[order.OrderUID] = codec.ToProto(order)
+		}
+	}
+	for _, uid := range uids {
+		if _, ok := resp.Orders[uid]; !ok {
+			resp.Missing = append(resp.Missing, uid)
+		}
+	}
+	return resp, nil
+}
+
+// ListOrders streams the matching orders newest first. Orders are loaded a
+// page at a time, so neither the store nor the server holds all of them at
+// once. A single customer, delivery service or status is filtered by the
+// store; several values are filtered here.
+func (s *Server) ListOrders(req *ordersv1.ListOrdersRequest, srv grpc.ServerStreamingServer[ordersv1.Order]) error {
+	ctx := srv.Context()
+	filter := db.OrderFilter{Limit: listPageSize}
+	if ids := req.GetCustomerIds(); len(ids) == 1 {
+		filter.CustomerID = ids[0]
+	}
+	if services := req.GetDeliveryServices(); len(services) == 1 {
+		filter.DeliveryService = services[0]
+	}
+	if statuses := req.GetStatuses(); len(statuses) == 1 {
+		filter.Status = db.OrderStatus(statuses[0])
+	}
+
+	sent := 0
+	for {
+		refs, err := s.db.ListOrderRefsContext(ctx, filter)
+		if err != nil {
+			log.Printf("Failed to list orders over gRPC: %v", err)
+			return status.Error(codes.Internal, "failed to list orders")
+		}
+		uids := make([]string, len(refs))
+		for i, ref := range refs {
+			uids[i] = ref.UID
+		}
+		orders, err := s.db.GetOrdersByUIDsContext(ctx, uids)
+		if err != nil {
+			log.Printf("Failed to list orders over gRPC: %v", err)
+			return status.Error(codes.Internal, "failed to list orders")
+		}
+		byUID := make(map[string]*db.Order, len(orders))
+		for i := range orders {
+			byUID[orders[i].OrderUID] = &orders[i]
+		}
+
+		// Orders deleted since the page was listed are skipped.
+		for _, uid := range uids {
+			o, ok := byUID[uid]
+			if !ok ||
+				!matches(req.GetCustomerIds(), o.CustomerID) ||
+				!matches(req.GetDeliveryServices(), o.DeliveryService) ||
+				!matches(req.GetStatuses(), string(o.Status)) {
+				continue
+			}
+			if err := srv.Send(codec.ToProto(o)); err != nil {
+				return err
+			}
+			sent++
+			if req.GetLimit() > 0 && sent >= int(req.GetLimit()) {
+				return nil
+			}
+		}
+
+		if len(refs) < filter.Limit {
+			return nil
+		}
+		filter.After = &refs[len(refs)-1]
+	}
+}
+
+// WatchOrders streams hub events until the client cancels. A client that
+// falls too far behind gets RESOURCE_EXHAUSTED and can resume with the ID of
+// the last event it received. If the events after it are lost, as after a
+// restart, the stream starts with a stream.resync event.
+func (s *Server) WatchOrders(req *ordersv1.WatchOrdersRequest, srv grpc.ServerStreamingServer[ordersv1.OrderEvent]) error {
+	filter := stream.MatchOrders(req.GetCustomerIds(), req.GetDeliveryServices())
+	if uids := req.GetOrderUids(); len(uids) > 0 {
+		byOrder := filter
+		filter = func(e stream.Event) bool {
+			return slices.Contains(uids, e.OrderUID) && (byOrder == nil || byOrder(e))
+		}
+	}
+
+	sub, replay, complete := s.hub.Subscribe(req.GetLastEventId(), filter)
+	defer s.hub.Unsubscribe(sub)
+
+	if !complete {
+		if err := srv.Send(toProtoEvent(stream.Resync())); err != nil {
+			return err
+		}
+	}
+	for _, e := range replay {
+		if err := srv.Send(toProtoEvent(e)); err != nil {
+			return err
+		}
+	}
+	for {
+		select {
+		case <-srv.Context().Done():
+			return nil
+		case e, ok := <-sub.C:
+			if !ok {
+				if sub.Lagged() {
+					return status.Error(codes.ResourceExhausted, "client is too slow; resume with last_event_id")
+				}
+				return status.Error(codes.Unavailable, "event stream closed")
+			}
+			if err := srv.Send(toProtoEvent(e)); err != nil {
+				return err
+			}
+		}
+	}
+}
+
+func (s *Server) getOrder(ctx context.Context, uid string) (*db.Order, error) {
+	if order, ok := s.cache.Get(uid); ok {
+		return order, nil
+	}
+	order, err := s.db.GetOrderByUIDContext(ctx, uid)
+	if err != nil {
+		if errors.Is(err, db.ErrOrderNotFound) {
+			return nil, status.Errorf(codes.NotFound, "order %s not found", uid)
+		}
+		log.Printf("Failed to get order %s over gRPC: %v", uid, err)
+		return nil, status.Error(codes.Internal, "failed to get order")
+	}
+	s.cache.Set(order)
+	return order, nil
+}
+
+func matches(values []string, v string) bool {
+	return len(values) == 0 || slices.Contains(values, v)
+}
@@ -0,0 +1,147 @@
+package grpcapi
+
+import (
+	"context"
+	"fmt"
+	"order-service/internal/cache"
+	"order-service/internal/db"
+	"order-service/internal/stream"
+	ordersv1 "order-service/proto/ordersv1"
+	"slices"
+	"testing"
+	"time"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// countingStore records the multi-order store calls the server makes.
+type countingStore struct {
+	*db.MemoryStore
+	lists   int
+	getAlls int
+	batches [][]string
+}
+
+func (c *countingStore) ListOrderRefsContext(ctx context.Context, filter db.OrderFilter) ([]db.OrderRef, error) {
+	c.lists++
+	return c.MemoryStore.ListOrderRefsContext(ctx, filter)
+}
+
+func (c *countingStore) GetOrdersByUIDsContext(ctx context.Context, uids []string) ([]db.Order, error) {
+	c.batches = append(c.batches, slices.Clone(uids))
+	return c.MemoryStore.GetOrdersByUIDsContext(ctx, uids)
+}
+
+func (c *countingStore) GetAllOrdersContext(ctx context.Context) ([]db.Order, error) {
+	c.getAlls++
+	return c.MemoryStore.GetAllOrdersContext(ctx)
+}
+
+// newTestServer stores n orders o0..o(n-1), each an hour newer than the one
+// before, split between customers c0, c1 and c2.
+func newTestServer(t *testing.T, n, maxBatch int) (*Server, *countingStore) {
+	t.Helper()
+	store := &countingStore{MemoryStore: db.NewMemoryStore()}
+	base := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	for i := range n {
+		order := &db.Order{
+			OrderUID:    fmt.Sprintf("o%d", i),
+			CustomerID:  fmt.Sprintf("c%d", i%3),
+			DateCreated: base.Add(time.Duration(i) * time.Hour),
+			Items:       []db.Item{},
+		}
+		if err := store.SaveOrderContext(context.Background(), order); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return &Server{cache: cache.NewCache(), db: store, hub: stream.NewHub(0, 1), maxBatch: maxBatch}, store
+}
+
+type listStream struct {
+	grpc.ServerStream
+	sent []string
+}
+
+func (l *listStream) Context() context.Context {
+	return context.Background()
+}
+
+func (l *listStream) Send(o *ordersv1.Order) error {
+	l.sent = append(l.sent, o.GetOrderUid())
+	return nil
+}
+
+func TestListOrdersStreamsPages(t *testing.T) {
+	s, store := newTestServer(t, 2*listPageSize+50, 10)
+
+	out := &listStream{}
+	if err := s.ListOrders(&ordersv1.ListOrdersRequest{}, out); err != nil {
+		t.Fatalf("ListOrders: %v", err)
+	}
+	if len(out.sent) != 2*listPageSize+50 || out.sent[0] != "o249" || out.sent[len(out.sent)-1] != "o0" {
+		t.Errorf("sent %d orders from %s to %s, want all 250 newest first", len(out.sent), out.sent[0], out.sent[len(out.sent)-1])
+	}
+	if store.getAlls != 0 || store.lists != 3 || len(store.batches) != 3 {
+		t.Errorf("store calls: %d GetAllOrders, %d ListOrderRefs, %d GetOrdersByUIDs; want 0, 3, 3", store.getAlls, store.lists, len(store.batches))
+	}
+}
+
+func TestListOrdersFilters(t *testing.T) {
+	tests := []struct {
+		name  string
+		req   *ordersv1.ListOrdersRequest
+		want  string
+		lists int
+	}{
+		{"one customer", &ordersv1.ListOrdersRequest{CustomerIds: []string{"c1"}}, "[o7 o4 o1]", 1},
+		{"two customers", &ordersv1.ListOrdersRequest{CustomerIds: []string{"c0", "c2"}}, "[o8 o6 o5 o3 o2 o0]", 1},
+		{"limit", &ordersv1.ListOrdersRequest{Limit: 2}, "[o8 o7]", 1},
+		{"status", &ordersv1.ListOrdersRequest{Statuses: []string{"paid"}}, "[]", 1},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s, store := newTestServer(t, 9, 10)
+			out := &listStream{}
+			if err := s.ListOrders(tt.req, out); err != nil {
+				t.Fatalf("ListOrders: %v", err)
+			}
+			if got := fmt.Sprint(out.sent); got != tt.want {
+				t.Errorf("sent %s, want %s", got, tt.want)
+			}
+			if store.lists != tt.lists {
+				t.Errorf("%d ListOrderRefs calls, want %d", store.lists, tt.lists)
+			}
+		})
+	}
+}
+
+func TestBatchGetOrders(t *testing.T) {
+	s, store := newTestServer(t, 5, 4)
+	cached, _ := store.MemoryStore.GetOrderByUIDContext(context.Background(), "o1")
+	s.cache.Set(cached)
+
+	resp, err := s.BatchGetOrders(context.Background(), &ordersv1.BatchGetOrdersRequest{
+		OrderUids: []string{"o1", "o2", "missing", "o2", "o3", ""},
+	})
+	if err != nil {
+		t.Fatalf("BatchGetOrders: %v", err)
+	}
+	if len(resp.Orders) != 3 || resp.Orders["o2"].GetOrderUid() != "o2" || fmt.Sprint(resp.Missing) != "[missing]" {
+		t.Errorf("orders %v, missing %v; want o1, o2, o3 and [missing]", resp.Orders, resp.Missing)
+	}
+	if fmt.Sprint(store.batches) != "[[o2 missing o3]]" {
+		t.Errorf("store queries = %v, want one for the uncached UIDs", store.batches)
+	}
+	if _, ok := s.cache.Get("o3"); !ok {
+		t.Error("loaded order not cached")
+	}
+
+	_, err = s.BatchGetOrders(context.Background(), &ordersv1.BatchGetOrdersRequest{
+		OrderUids: []string{"a", "b", "c", "d", "e"},
+	})
+	if status.Code(err) != codes.InvalidArgument {
+		t.Errorf("five UIDs with a limit of four: err = %v, want InvalidArgument", err)
+	}
+}
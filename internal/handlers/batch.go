@@ -0,0 +1,89 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"order-service/internal/db"
+)
+
+type batchRequest struct {
+	OrderUIDs []string `json:"order_uids"`
+}
+
+type batchResponse struct {
+	Orders  map[string]*db.Order `json:"orders"`
+	Missing []string             `json:"missing"`
+}
+
+// BatchGetOrders returns many orders at once; see loadOrders.
+func (h *OrderHandler) BatchGetOrders(w http.ResponseWriter, r *http.Request) {
+	var req batchRequest
+	if err := json.NewDecoder(http.MaxBytesReader(w, r.Body, 1<<20)).Decode(&req); err != nil {
+		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+	if len(req.OrderUIDs) == 0 {
+		http.Error(w, "order_uids is required", http.StatusBadRequest)
+		return
+	}
+
+	uids := make([]string, 0, len(req.OrderUIDs))
+	seen := make(map[string]bool, len(req.OrderUIDs))
+	for _, uid := range req.OrderUIDs {
+		if uid != "" && !seen[uid] {
+			seen[uid] = true
+			uids = append(uids, uid)
+		}
+	}
+	if len(uids) > h.maxBatch {
+		http.Error(w, fmt.Sprintf("At most %d order UIDs per request", h.maxBatch), http.StatusRequestEntityTooLarge)
+		return
+	}
+
+	orders, err := h.loadOrders(r.Context(), uids)
+	if err != nil {
+		log.Printf("Failed to get %d orders: %v", len(uids), err)
+		http.Error(w, "Failed to get orders", http.StatusInternalServerError)
+		return
+	}
+	resp := batchResponse{Orders: orders, Missing: []string{}}
+	for _, uid := range uids {
+		if _, ok := orders[uid]; !ok {
+			resp.Missing = append(resp.Missing, uid)
+		}
+	}
+
+	respondWithJSON(w, http.StatusOK, resp)
+}
+
+// loadOrders serves what it can from the cache and loads the rest with a
+// single store query, caching what it finds. Unknown UIDs are absent from
+// the result.
+func (h *OrderHandler) loadOrders(ctx context.Context, uids []string) (map[string]*db.Order, error) {
+	found := make(map[string]*db.Order, len(uids))
+	var uncached []string
+	for _, uid := range uids {
+		if order, ok := h.cache.Get(uid); ok {
+			found[uid] = order
+			continue
+		}
+		uncached = append(uncached, uid)
+	}
+	if len(uncached) == 0 {
+		return found, nil
+	}
+
+	orders, err := h.db.GetOrdersByUIDsContext(ctx, uncached)
+	if err != nil {
+		return nil, err
+	}
+	for i := range orders {
+		order := &orders[i]
+		h.cache.Set(order)
+		found[order.OrderUID] = order
+	}
+	return found, nil
+}
@@ -0,0 +1,90 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"order-service/internal/db"
+	"slices"
+	"strings"
+	"testing"
+)
+
+// batchStore records the UIDs of every multi-order load.
+type batchStore struct {
+	*db.MemoryStore
+	loads [][]string
+}
+
+func (b *batchStore) GetOrdersByUIDsContext(ctx context.Context, uids []string) ([]db.Order, error) {
+	b.loads = append(b.loads, slices.Clone(uids))
+	return b.MemoryStore.GetOrdersByUIDsContext(ctx, uids)
+}
+
+func TestBatchGetOrders(t *testing.T) {
+	store := &batchStore{MemoryStore: db.NewMemoryStore()}
+	h := newTestHandler(store)
+	for _, uid := range []string{"b1", "b2", "b3"} {
+		if rec := serve(h.CreateOrder, http.MethodPost, "/order", modelJSON(t, uid), nil); rec.Code != http.StatusCreated {
+			t.Fatalf("create %s: %d %s", uid, rec.Code, rec.Body)
+		}
+	}
+	// Only b1 stays cached.
+	h.cache.Delete("b2")
+	h.cache.Delete("b3")
+
+	rec := serve(h.BatchGetOrders, http.MethodPost, "/orders/batch", `{"order_uids": ["b1", "b2", "nope", "b2", "", "b3", "gone"]}`, nil)
+	if rec.Code != http.StatusOK {
+		t.Fatalf("batch: %d %s", rec.Code, rec.Body)
+	}
+	var resp batchResponse
+	if err := json.NewDecoder(rec.Body).Decode(&resp); err != nil {
+		t.Fatal(err)
+	}
+	var found []string
+	for uid, order := range resp.Orders {
+		if order.OrderUID != uid {
+			t.Errorf("orders[%s] holds order %s", uid, order.OrderUID)
+		}
+		found = append(found, uid)
+	}
+	slices.Sort(found)
+	if fmt.Sprint(found) != "[b1 b2 b3]" || fmt.Sprint(resp.Missing) != "[nope gone]" {
+		t.Errorf("found %v, missing %v; want [b1 b2 b3] and [nope gone]", found, resp.Missing)
+	}
+	if fmt.Sprint(store.loads) != "[[b2 nope b3 gone]]" {
+		t.Errorf("store loads = %v, want one load of the uncached UIDs", store.loads)
+	}
+	if _, ok := h.cache.Get("b3"); !ok {
+		t.Error("loaded order not cached")
+	}
+
+	// Everything cached: the store is not asked again, and nothing missing
+	// is still reported as an empty list.
+	rec = serve(h.BatchGetOrders, http.MethodPost, "/orders/batch", `{"order_uids": ["b1", "b3"]}`, nil)
+	if body := rec.Body.String(); rec.Code != http.StatusOK || len(store.loads) != 1 || !strings.Contains(body, `"missing":[]`) {
+		t.Errorf("cached batch: %d %s after %d loads", rec.Code, body, len(store.loads))
+	}
+}
+
+func TestBatchGetOrdersRejectsBadRequests(t *testing.T) {
+	h := newTestHandler(db.NewMemoryStore())
+	h.maxBatch = 3
+
+	tests := []struct {
+		name string
+		body string
+		want int
+	}{
+		{"not json", `{`, http.StatusBadRequest},
+		{"no uids", `{"order_uids": []}`, http.StatusBadRequest},
+		{"too many", `{"order_uids": ["a", "b", "c", "d"]}`, http.StatusRequestEntityTooLarge},
+		{"duplicates count once", `{"order_uids": ["a", "b", "c", "a", "b", "c"]}`, http.StatusOK},
+	}
+	for _, tt := range tests {
+		if rec := serve(h.BatchGetOrders, http.MethodPost, "/orders/batch", tt.body, nil); rec.Code != tt.want {
+			t.Errorf("%s: %d, want %d", tt.name, rec.Code, tt.want)
+		}
+	}
+}
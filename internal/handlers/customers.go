@@ -0,0 +1,101 @@
+package handlers
+
+import (
+	"log"
+	"net/http"
+	"order-service/internal/db"
+	"strconv"
+)
+
+const (
+	defaultCustomerPage = 20
+	maxCustomerPage     = 100
+	favoritesLimit      = 5
+)
+
+type customerOrdersResponse struct {
+	CustomerID string              `json:"customer_id"`
+	Summary    *db.CustomerSummary `json:"summary,omitempty"`
+	Orders     []*db.Order         `json:"orders"`
+	NextCursor string              `json:"next_cursor,omitempty"`
+}
+
+// GetCustomerOrders returns one page of a customer's orders, newest first.
+// The first page also carries aggregates over all of them; they cost a scan
+// of every order of the customer, so later pages, and requests with
+// summary=false, leave them out. Query parameters: limit (default 20, at
+// most 100), after, the next_cursor of the previous page, and summary.
+func (h *OrderHandler) GetCustomerOrders(w http.ResponseWriter, r *http.Request) {
+	customerID := r.PathValue("id")
+
+	limit := defaultCustomerPage
+	if v := r.URL.Query().Get("limit"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil || n < 1 {
+			http.Error(w, "limit must be a positive integer", http.StatusBadRequest)
+			return
+		}
+		limit = min(n, maxCustomerPage)
+	}
+	filter := db.OrderFilter{CustomerID: customerID, Limit: limit + 1}
+	if v := r.URL.Query().Get("after"); v != "" {
+		after, err := db.ParseCursor(v)
+		if err != nil {
+			http.Error(w, "Invalid cursor", http.StatusBadRequest)
+			return
+		}
+		filter.After = after
+	}
+	withSummary := filter.After == nil
+	if v := r.URL.Query().Get("summary"); v != "" {
+		b, err := strconv.ParseBool(v)
+		if err != nil {
+			http.Error(w, "summary must be true or false", http.StatusBadRequest)
+			return
+		}
+		withSummary = withSummary && b
+	}
+
+	refs, err := h.db.ListOrderRefsContext(r.Context(), filter)
+	if err != nil {
+		log.Printf("Failed to list orders of customer %s: %v", customerID, err)
+		http.Error(w, "Failed to get customer orders", http.StatusInternalServerError)
+		return
+	}
+	if len(refs) == 0 && filter.After == nil {
+		http.Error(w, "Customer not found", http.StatusNotFound)
+		return
+	}
+	resp := customerOrdersResponse{CustomerID: customerID, Orders: []*db.Order{}}
+	if withSummary {
+		resp.Summary, err = h.db.GetCustomerSummaryContext(r.Context(), customerID, favoritesLimit)
+		if err != nil {
+			log.Printf("Failed to summarize orders of customer %s: %v", customerID, err)
+			http.Error(w, "Failed to get customer orders", http.StatusInternalServerError)
+			return
+		}
+	}
+	if len(refs) > limit {
+		refs = refs[:limit]
+		resp.NextCursor = refs[limit-1].Cursor()
+	}
+
+	uids := make([]string, len(refs))
+	for i, ref := range refs {
+		uids[i] = ref.UID
+	}
+	orders, err := h.loadOrders(r.Context(), uids)
+	if err != nil {
+		log.Printf("Failed to load orders of customer %s: %v", customerID, err)
+		http.Error(w, "Failed to get customer orders", http.StatusInternalServerError)
+		return
+	}
+	for _, uid := range uids {
+		// An order deleted between the two queries is simply skipped.
+		if order, ok := orders[uid]; ok {
+			resp.Orders = append(resp.Orders, order)
+		}
+	}
+
+	respondWithJSON(w, http.StatusOK, resp)
+}
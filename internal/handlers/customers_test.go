@@ -0,0 +1,121 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"order-service/internal/db"
+	"testing"
+)
+
+// summaryStore counts the customer aggregations.
+type summaryStore struct {
+	*db.MemoryStore
+	summaries int
+}
+
+func (s *summaryStore) GetCustomerSummaryContext(ctx context.Context, customerID string, top int) (*db.CustomerSummary, error) {
+	s.summaries++
+	return s.MemoryStore.GetCustomerSummaryContext(ctx, customerID, top)
+}
+
+func getCustomerOrders(h *OrderHandler, id string, query url.Values) *httptest.ResponseRecorder {
+	req := httptest.NewRequest(http.MethodGet, "/customers/"+id+"/orders?"+query.Encode(), nil)
+	req.SetPathValue("id", id)
+	rec := httptest.NewRecorder()
+	h.GetCustomerOrders(rec, req)
+	return rec
+}
+
+func TestGetCustomerOrdersPages(t *testing.T) {
+	store := &summaryStore{MemoryStore: db.NewMemoryStore()}
+	h := newTestHandler(store)
+	for _, uid := range []string{"c1", "c2", "c3", "c4", "c5"} {
+		if rec := serve(h.CreateOrder, http.MethodPost, "/order", modelJSON(t, uid), nil); rec.Code != http.StatusCreated {
+			t.Fatalf("create %s: %d %s", uid, rec.Code, rec.Body)
+		}
+	}
+
+	seen := map[string]bool{}
+	var pages []int
+	after := ""
+	for {
+		query := url.Values{"limit": {"2"}}
+		if after != "" {
+			query.Set("after", after)
+		}
+		rec := getCustomerOrders(h, "test", query)
+		if rec.Code != http.StatusOK {
+			t.Fatalf("page %d: %d %s", len(pages)+1, rec.Code, rec.Body)
+		}
+		var resp customerOrdersResponse
+		if err := json.NewDecoder(rec.Body).Decode(&resp); err != nil {
+			t.Fatal(err)
+		}
+		if first := after == ""; (resp.Summary != nil) != first {
+			t.Errorf("page %d: summary = %+v, want one on the first page only", len(pages)+1, resp.Summary)
+		}
+		if resp.Summary != nil {
+			s := resp.Summary
+			if s.OrderCount != 5 || s.TotalSpent["USD"] != 5*1817 {
+				t.Errorf("summary count %d, spent %v; want 5 orders and 9085 USD", s.OrderCount, s.TotalSpent)
+			}
+			if fmt.Sprint(s.FavoriteBrands) != "[{Vivienne Sabo 5}]" || fmt.Sprint(s.FavoriteDeliveryServices) != "[{meest 5}]" {
+				t.Errorf("favorites %v and %v", s.FavoriteBrands, s.FavoriteDeliveryServices)
+			}
+		}
+		for _, order := range resp.Orders {
+			if seen[order.OrderUID] {
+				t.Errorf("order %s returned twice", order.OrderUID)
+			}
+			seen[order.OrderUID] = true
+		}
+		pages = append(pages, len(resp.Orders))
+		if resp.NextCursor == "" {
+			break
+		}
+		after = resp.NextCursor
+	}
+	if fmt.Sprint(pages) != "[2 2 1]" || len(seen) != 5 {
+		t.Errorf("page sizes %v, %d orders; want [2 2 1] and all 5", pages, len(seen))
+	}
+	if store.summaries != 1 {
+		t.Errorf("summary computed %d times, want once", store.summaries)
+	}
+
+	rec := getCustomerOrders(h, "test", url.Values{"summary": {"false"}})
+	var resp customerOrdersResponse
+	if err := json.NewDecoder(rec.Body).Decode(&resp); err != nil {
+		t.Fatal(err)
+	}
+	if rec.Code != http.StatusOK || resp.Summary != nil || len(resp.Orders) != 5 || store.summaries != 1 {
+		t.Errorf("summary=false: %d, summary %+v, %d orders; want 200 with all orders and no summary", rec.Code, resp.Summary, len(resp.Orders))
+	}
+}
+
+func TestGetCustomerOrdersErrors(t *testing.T) {
+	h := newTestHandler(db.NewMemoryStore())
+	if rec := serve(h.CreateOrder, http.MethodPost, "/order", modelJSON(t, "c1"), nil); rec.Code != http.StatusCreated {
+		t.Fatalf("create: %d %s", rec.Code, rec.Body)
+	}
+
+	tests := []struct {
+		name, id string
+		query    url.Values
+		want     int
+	}{
+		{"unknown customer", "nobody", nil, http.StatusNotFound},
+		{"zero limit", "test", url.Values{"limit": {"0"}}, http.StatusBadRequest},
+		{"bad limit", "test", url.Values{"limit": {"x"}}, http.StatusBadRequest},
+		{"bad cursor", "test", url.Values{"after": {"!"}}, http.StatusBadRequest},
+		{"bad summary", "test", url.Values{"summary": {"maybe"}}, http.StatusBadRequest},
+	}
+	for _, tt := range tests {
+		if rec := getCustomerOrders(h, tt.id, tt.query); rec.Code != tt.want {
+			t.Errorf("%s: %d %s, want %d", tt.name, rec.Code, rec.Body, tt.want)
+		}
+	}
+}
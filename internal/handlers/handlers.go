@@ -2,19 +2,26 @@ package handlers
 
 import (
 	"encoding/json"
+	"errors"
 	"log"
 	"net/http"
 	"order-service/internal/cache"
 	"order-service/internal/db"
+	"order-service/internal/ingest"
+	"order-service/internal/schema"
+	"order-service/internal/stream"
 )
 
 type OrderHandler struct {
-	cache *cache.Cache
-	db    *db.Database
+	cache    *cache.Cache
+	db       db.Store
+	pipeline *ingest.Pipeline
+	hub      *stream.Hub
+	maxBatch int
 }
 
-func NewOrderHandler(cache *cache.Cache, db *db.Database) *OrderHandler {
-	return &OrderHandler{cache, db}
+func NewOrderHandler(cache *cache.Cache, db db.Store, pipeline *ingest.Pipeline, hub *stream.Hub, maxBatch int) *OrderHandler {
+	return &OrderHandler{cache, db, pipeline, hub, maxBatch}
 }
 
 func (h *OrderHandler) GetOrder(w http.ResponseWriter, r *http.Request) {
@@ -29,7 +36,7 @@ func (h *OrderHandler) GetOrder(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	order, err := h.db.GetOrderByUID(uid)
+	order, err := h.db.GetOrderByUIDContext(r.Context(), uid)
 	if err != nil {
 		http.Error(w, "Order not found", http.StatusNotFound)
 		return
@@ -39,6 +46,33 @@ func (h *OrderHandler) GetOrder(w http.ResponseWriter, r *http.Request) {
 	respondWithJSON(w, http.StatusOK, order)
 }
 
+func (h *OrderHandler) DeleteOrder(w http.ResponseWriter, r *http.Request) {
+	uid := r.PathValue("uid")
+	if uid == "" {
+		http.Error(w, "order UID is required", http.StatusBadRequest)
+		return
+	}
+
+	// The snapshot lets filtered subscribers see the delete.
+	order, ok := h.cache.Get(uid)
+	if !ok {
+		order, _ = h.db.GetOrderByUIDContext(r.Context(), uid)
+	}
+	if err := h.db.DeleteOrderContext(r.Context(), uid); err != nil {
+		if errors.Is(err, db.ErrOrderNotFound) {
+			http.Error(w, "Order not found", http.StatusNotFound)
+			return
+		}
+		log.Printf("Failed to delete order %s: %v", uid, err)
+		http.Error(w, "Failed to delete order", http.StatusInternalServerError)
+		return
+	}
+
+	h.cache.Delete(uid)
+	h.hub.Publish(stream.OrderDeleted(uid, order))
+	w.WriteHeader(http.StatusNoContent)
+}
+
 func respondWithJSON(w http.ResponseWriter, StatusCode int, data interface{}) {
 	w.Header().Set("Content-type", "application/json")
 	w.WriteHeader(StatusCode)
@@ -47,6 +81,13 @@ func respondWithJSON(w http.ResponseWriter, StatusCode int, data interface{}) {
 	}
 }
 
+func SchemaHandler(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-type", "application/schema+json")
+	if err := json.NewEncoder(w).Encode(schema.Order()); err != nil {
+		log.Printf("Failed to encode schema: %v", err)
+	}
+}
+
 func StaticHandler(w http.ResponseWriter, r *http.Request) {
 	if r.URL.Path != "/" {
 		http.NotFound(w, r)
@@ -0,0 +1,64 @@
+package handlers
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"order-service/config"
+	"order-service/internal/cache"
+	"order-service/internal/db"
+	"order-service/internal/ingest"
+	"order-service/internal/stream"
+	"os"
+	"strings"
+	"testing"
+)
+
+func newTestHandler(store db.Store) *OrderHandler {
+	pipeline := ingest.NewPipeline(&config.Config{KafkaSchemaValidation: true, KafkaSchemaVersion: 2})
+	return NewOrderHandler(cache.NewCache(), store, pipeline, stream.NewHub(16, 16), 100)
+}
+
+// modelJSON returns model.json with its order_uid replaced by uid.
+func modelJSON(t *testing.T, uid string) string {
+	t.Helper()
+	data, err := os.ReadFile("../../model.json")
+	if err != nil {
+		t.Fatal(err)
+	}
+	return strings.ReplaceAll(string(data), "b563feb7b2b84b6test", uid)
+}
+
+func serve(handler http.HandlerFunc, method, target, body string, header http.Header) *httptest.ResponseRecorder {
+	req := httptest.NewRequest(method, target, strings.NewReader(body))
+	for k, v := range header {
+		req.Header[k] = v
+	}
+	rec := httptest.NewRecorder()
+	handler(rec, req)
+	return rec
+}
+
+func TestDeleteOrderPublishesEvent(t *testing.T) {
+	h := newTestHandler(db.NewMemoryStore())
+	if rec := serve(h.CreateOrder, http.MethodPost, "/order", modelJSON(t, "del1"), nil); rec.Code != http.StatusCreated {
+		t.Fatalf("create: %d %s", rec.Code, rec.Body)
+	}
+	sub, _, _ := h.hub.Subscribe(0, stream.MatchOrders([]string{"test"}, nil))
+
+	req := httptest.NewRequest(http.MethodDelete, "/order/del1", nil)
+	req.SetPathValue("uid", "del1")
+	rec := httptest.NewRecorder()
+	h.DeleteOrder(rec, req)
+	if rec.Code != http.StatusNoContent {
+		t.Fatalf("delete: %d", rec.Code)
+	}
+
+	select {
+	case e := <-sub.C:
+		if e.Type != stream.EventOrderDeleted || e.OrderUID != "del1" || e.Order == nil {
+			t.Errorf("event = %+v, want order.deleted with the order", e)
+		}
+	default:
+		t.Error("no event published for the delete")
+	}
+}
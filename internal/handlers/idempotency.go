@@ -0,0 +1,168 @@
+package handlers
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"io"
+	"net/http"
+	"sync"
+	"time"
+)
+
+const IdempotencyHeader = "Idempotency-Key"
+
+// Idempotency replays the stored response when a request is retried with the
+// same Idempotency-Key. Keys are kept in memory for ttl, so retries have to
+// reach the same instance.
+type Idempotency struct {
+	ttl time.Duration
+
+	mu        sync.Mutex
+	entries   map[string]*idempotentResponse
+	lastSweep time.Time
+}
+
+type idempotentResponse struct {
+	fingerprint [sha256.Size]byte
+	done        chan struct{}
+	status      int
+	header      http.Header
+	body        []byte
+	expires     time.Time
+}
+
+func NewIdempotency(ttl time.Duration) *Idempotency {
+	return &Idempotency{ttl: ttl, entries: make(map[string]*idempotentResponse)}
+}
+
+// Wrap makes next idempotent for requests carrying an Idempotency-Key. A key
+// reused with a different request is rejected with 422, and a retry that
+// arrives while the first request is still running gets 409. Server errors
+// and panics are not stored so the client can retry them.
+func (i *Idempotency) Wrap(next http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		key := r.Header.Get(IdempotencyHeader)
+		if key == "" {
+			next(w, r)
+			return
+		}
+
+		body, err := io.ReadAll(http.MaxBytesReader(w, r.Body, maxOrderBody))
+		if err != nil {
+			http.Error(w, "Request body too large", http.StatusRequestEntityTooLarge)
+			return
+		}
+		r.Body = io.NopCloser(bytes.NewReader(body))
+
+		h := sha256.New()
+		io.WriteString(h, r.Method+" "+r.URL.Path+"\n"+r.Header.Get("Content-Type")+"\n")
+		h.Write(body)
+		var fingerprint [sha256.Size]byte
+		copy(fingerprint[:], h.Sum(nil))
+
+		entry, owner := i.acquire(key, fingerprint)
+		if !owner {
+			if entry.fingerprint != fingerprint {
+				http.Error(w, "Idempotency-Key was used for a different request", http.StatusUnprocessableEntity)
+				return
+			}
+			select {
+			case <-entry.done:
+				entry.replay(w)
+			default:
+				http.Error(w, "A request with this Idempotency-Key is in progress", http.StatusConflict)
+			}
+			return
+		}
+
+		rec := &responseRecorder{ResponseWriter: w, status: http.StatusOK}
+		completed := false
+		defer func() {
+			// next panicked; free the key rather than hold it forever.
+			if !completed {
+				i.release(key, entry)
+			}
+		}()
+		next(rec, r)
+		i.complete(key, entry, rec)
+		completed = true
+	}
+}
+
+func (i *Idempotency) acquire(key string, fingerprint [sha256.Size]byte) (*idempotentResponse, bool) {
+	i.mu.Lock()
+	defer i.mu.Unlock()
+
+	now := time.Now()
+	if now.Sub(i.lastSweep) > time.Minute {
+		for k, e := range i.entries {
+			if !e.expires.IsZero() && now.After(e.expires) {
+				delete(i.entries, k)
+			}
+		}
+		i.lastSweep = now
+	}
+
+	if entry, ok := i.entries[key]; ok {
+		return entry, false
+	}
+	entry := &idempotentResponse{fingerprint: fingerprint, done: make(chan struct{})}
+	i.entries[key] = entry
+	return entry, true
+}
+
+func (i *Idempotency) complete(key string, entry *idempotentResponse, rec *responseRecorder) {
+	i.mu.Lock()
+	defer i.mu.Unlock()
+
+	if rec.status >= http.StatusInternalServerError {
+		i.releaseLocked(key, entry)
+		return
+	}
+	entry.status = rec.status
+	entry.header = rec.Header().Clone()
+	entry.body = rec.body.Bytes()
+	entry.expires = time.Now().Add(i.ttl)
+	close(entry.done)
+}
+
+// release forgets a request that produced no response worth replaying.
+func (i *Idempotency) release(key string, entry *idempotentResponse) {
+	i.mu.Lock()
+	defer i.mu.Unlock()
+	i.releaseLocked(key, entry)
+}
+
+func (i *Idempotency) releaseLocked(key string, entry *idempotentResponse) {
+	delete(i.entries, key)
+	close(entry.done)
+}
+
+func (e *idempotentResponse) replay(w http.ResponseWriter) {
+	if e.header == nil {
+		http.Error(w, "The original request failed; retry without reusing the key", http.StatusConflict)
+		return
+	}
+	for k, v := range e.header {
+		w.Header()[k] = v
+	}
+	w.Header().Set("Idempotent-Replayed", "true")
+	w.WriteHeader(e.status)
+	w.Write(e.body)
+}
+
+type responseRecorder struct {
+	http.ResponseWriter
+	status int
+	body   bytes.Buffer
+}
+
+func (r *responseRecorder) WriteHeader(status int) {
+	r.status = status
+	r.ResponseWriter.WriteHeader(status)
+}
+
+func (r *responseRecorder) Write(b []byte) (int, error) {
+	r.body.Write(b)
+	return r.ResponseWriter.Write(b)
+}
@@ -0,0 +1,91 @@
+package handlers
+
+import (
+	"net/http"
+	"testing"
+	"time"
+)
+
+func TestIdempotencyReplaysAndRejects(t *testing.T) {
+	calls := 0
+	handler := NewIdempotency(time.Hour).Wrap(func(w http.ResponseWriter, r *http.Request) {
+		calls++
+		w.WriteHeader(http.StatusCreated)
+		w.Write([]byte("created"))
+	})
+	key := http.Header{IdempotencyHeader: {"k1"}}
+
+	first := serve(handler, http.MethodPost, "/order", "a", key)
+	retry := serve(handler, http.MethodPost, "/order", "a", key)
+	if calls != 1 || retry.Code != http.StatusCreated || retry.Body.String() != "created" || retry.Header().Get("Idempotent-Replayed") != "true" {
+		t.Errorf("retry = %d %q after %d calls; want the first response %d replayed", retry.Code, retry.Body, calls, first.Code)
+	}
+	if rec := serve(handler, http.MethodPost, "/order", "b", key); rec.Code != http.StatusUnprocessableEntity {
+		t.Errorf("key reused for another body: %d, want 422", rec.Code)
+	}
+}
+
+func TestIdempotencyRejectsRetryInProgress(t *testing.T) {
+	started, release := make(chan struct{}), make(chan struct{})
+	handler := NewIdempotency(time.Hour).Wrap(func(w http.ResponseWriter, r *http.Request) {
+		close(started)
+		<-release
+	})
+	key := http.Header{IdempotencyHeader: {"k1"}}
+
+	done := make(chan struct{})
+	go func() {
+		serve(handler, http.MethodPost, "/order", "a", key)
+		close(done)
+	}()
+	<-started
+	if rec := serve(handler, http.MethodPost, "/order", "a", key); rec.Code != http.StatusConflict {
+		t.Errorf("retry while running: %d, want 409", rec.Code)
+	}
+	close(release)
+	<-done
+}
+
+func TestIdempotencyFreesKeyAfterFailure(t *testing.T) {
+	fail := true
+	handler := NewIdempotency(time.Hour).Wrap(func(w http.ResponseWriter, r *http.Request) {
+		if fail {
+			http.Error(w, "boom", http.StatusInternalServerError)
+			return
+		}
+		w.WriteHeader(http.StatusCreated)
+	})
+	key := http.Header{IdempotencyHeader: {"k1"}}
+
+	serve(handler, http.MethodPost, "/order", "a", key)
+	fail = false
+	if rec := serve(handler, http.MethodPost, "/order", "a", key); rec.Code != http.StatusCreated {
+		t.Errorf("retry after a server error: %d, want 201", rec.Code)
+	}
+}
+
+func TestIdempotencyFreesKeyAfterPanic(t *testing.T) {
+	panics := true
+	handler := NewIdempotency(time.Hour).Wrap(func(w http.ResponseWriter, r *http.Request) {
+		if panics {
+			panic("handler failed")
+		}
+		w.WriteHeader(http.StatusCreated)
+	})
+	key := http.Header{IdempotencyHeader: {"k1"}}
+
+	func() {
+		defer func() {
+			if recover() == nil {
+				t.Error("panic was swallowed")
+			}
+		}()
+		serve(handler, http.MethodPost, "/order", "a", key)
+	}()
+
+	panics = false
+	if rec := serve(handler, http.MethodPost, "/order", "a", key); rec.Code != http.StatusCreated {
+		t.Errorf("retry after a panic: %d, want 201", rec.Code)
+	}
+
+}
@@ -0,0 +1,148 @@
+package handlers
+
+import (
+	"errors"
+	"io"
+	"log"
+	"net/http"
+	"order-service/internal/codec"
+	"order-service/internal/db"
+	"order-service/internal/ingest"
+	"order-service/internal/schema"
+	"order-service/internal/stream"
+	"order-service/internal/validation"
+)
+
+// maxOrderBody matches the largest message the Kafka consumer accepts.
+const maxOrderBody = 10 << 20
+
+type validationErrorResponse struct {
+	Error      string                 `json:"error"`
+	Stage      string                 `json:"stage,omitempty"`
+	Violations []validation.Violation `json:"violations"`
+	Warnings   []validation.Violation `json:"warnings,omitempty"`
+}
+
+func respondWithValidationError(w http.ResponseWriter, statusCode int, stage string, report *validation.ValidationError) {
+	respondWithJSON(w, statusCode, validationErrorResponse{
+		Error:      "order rejected",
+		Stage:      stage,
+		Violations: report.Violations,
+		Warnings:   report.Warnings,
+	})
+}
+
+// CreateOrder accepts an order in any format the Kafka consumer accepts and
+// stores it. An order that already exists is a conflict; use PUT to replace
+// it.
+func (h *OrderHandler) CreateOrder(w http.ResponseWriter, r *http.Request) {
+	order, ok := h.decodeOrder(w, r)
+	if !ok {
+		return
+	}
+
+	if err := h.db.CreateOrderContext(r.Context(), order); err != nil {
+		if errors.Is(err, db.ErrOrderExists) {
+			http.Error(w, "Order already exists", http.StatusConflict)
+			return
+		}
+		log.Printf("Failed to save order %s: %v", order.OrderUID, err)
+		http.Error(w, "Failed to save order", http.StatusInternalServerError)
+		return
+	}
+	h.orderSaved(order)
+	w.Header().Set("Location", "/order/"+order.OrderUID)
+	respondWithJSON(w, http.StatusCreated, order)
+}
+
+// PutOrder creates or replaces the order named in the path.
+func (h *OrderHandler) PutOrder(w http.ResponseWriter, r *http.Request) {
+	uid := r.PathValue("uid")
+	if uid == "" {
+		http.Error(w, "order UID is required", http.StatusBadRequest)
+		return
+	}
+
+	order, ok := h.decodeOrder(w, r)
+	if !ok {
+		return
+	}
+	if order.OrderUID != uid {
+		respondWithValidationError(w, http.StatusUnprocessableEntity, ingest.StageValidation, &validation.ValidationError{
+			Violations: []validation.Violation{{
+				Path:    "order_uid",
+				Rule:    "path",
+				Value:   order.OrderUID,
+				Message: "must match the order UID in the URL",
+			}},
+		})
+		return
+	}
+
+	exists, err := h.db.OrderExistsContext(r.Context(), uid)
+	if err != nil {
+		log.Printf("Failed to check order %s: %v", uid, err)
+		http.Error(w, "Failed to save order", http.StatusInternalServerError)
+		return
+	}
+
+	if !h.saveOrder(w, r, order) {
+		return
+	}
+	status := http.StatusOK
+	if !exists {
+		status = http.StatusCreated
+		w.Header().Set("Location", "/order/"+uid)
+	}
+	respondWithJSON(w, status, order)
+}
+
+func (h *OrderHandler) decodeOrder(w http.ResponseWriter, r *http.Request) (*db.Order, bool) {
+	body, err := io.ReadAll(http.MaxBytesReader(w, r.Body, maxOrderBody))
+	if err != nil {
+		var maxErr *http.MaxBytesError
+		if errors.As(err, &maxErr) {
+			http.Error(w, "Request body too large", http.StatusRequestEntityTooLarge)
+			return nil, false
+		}
+		http.Error(w, "Failed to read request body", http.StatusBadRequest)
+		return nil, false
+	}
+
+	order, err := h.pipeline.Decode(r.Context(), r.Header.Get("Content-Type"), r.Header.Get(schema.HeaderVersion), body)
+	if err != nil {
+		if codec.IsRetryable(err) {
+			log.Printf("Failed to decode order: %v", err)
+			http.Error(w, "Schema registry unavailable", http.StatusServiceUnavailable)
+			return nil, false
+		}
+		var ierr *ingest.Error
+		if !errors.As(err, &ierr) {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return nil, false
+		}
+		status := http.StatusUnprocessableEntity
+		if ierr.Stage == ingest.StageContentType {
+			status = http.StatusUnsupportedMediaType
+		}
+		respondWithValidationError(w, status, ierr.Stage, ierr.Report)
+		return nil, false
+	}
+	return order, true
+}
+
+func (h *OrderHandler) saveOrder(w http.ResponseWriter, r *http.Request, order *db.Order) bool {
+	if err := h.db.SaveOrderContext(r.Context(), order); err != nil {
+		log.Printf("Failed to save order %s: %v", order.OrderUID, err)
+		http.Error(w, "Failed to save order", http.StatusInternalServerError)
+		return false
+	}
+	h.orderSaved(order)
+	return true
+}
+
+func (h *OrderHandler) orderSaved(order *db.Order) {
+	h.cache.Set(order)
+	h.hub.Publish(stream.OrderSaved(order))
+	log.Printf("Order %s saved and cached", order.OrderUID)
+}
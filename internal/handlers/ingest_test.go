@@ -0,0 +1,45 @@
+package handlers
+
+import (
+	"net/http"
+	"order-service/internal/db"
+	"sync"
+	"testing"
+)
+
+func TestCreateOrderConflicts(t *testing.T) {
+	h := newTestHandler(db.NewMemoryStore())
+	body := modelJSON(t, "create1")
+
+	if rec := serve(h.CreateOrder, http.MethodPost, "/order", body, nil); rec.Code != http.StatusCreated {
+		t.Fatalf("create: %d %s", rec.Code, rec.Body)
+	}
+	if rec := serve(h.CreateOrder, http.MethodPost, "/order", body, nil); rec.Code != http.StatusConflict {
+		t.Errorf("second create: %d, want 409", rec.Code)
+	}
+}
+
+func TestCreateOrderConcurrentCreatesConflict(t *testing.T) {
+	h := newTestHandler(db.NewMemoryStore())
+	body := modelJSON(t, "race1")
+
+	codes := make(chan int, 8)
+	var wg sync.WaitGroup
+	for range cap(codes) {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			codes <- serve(h.CreateOrder, http.MethodPost, "/order", body, nil).Code
+		}()
+	}
+	wg.Wait()
+	close(codes)
+
+	counts := map[int]int{}
+	for code := range codes {
+		counts[code]++
+	}
+	if counts[http.StatusCreated] != 1 || counts[http.StatusConflict] != cap(codes)-1 {
+		t.Errorf("responses = %v, want one 201 and the rest 409", counts)
+	}
+}
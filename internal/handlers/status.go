@@ -0,0 +1,123 @@
+package handlers
+
+import (
+	"encoding/json"
+	"errors"
+	"log"
+	"net/http"
+	"order-service/internal/db"
+	"order-service/internal/stream"
+	"order-service/internal/validation"
+	"strings"
+)
+
+type statusRequest struct {
+	Status db.OrderStatus `json:"status"`
+	Reason string         `json:"reason"`
+}
+
+type statusResponse struct {
+	OrderUID string            `json:"order_uid"`
+	Status   db.OrderStatus    `json:"status"`
+	Allowed  []db.OrderStatus  `json:"allowed"`
+	History  []db.StatusChange `json:"history,omitempty"`
+}
+
+type transitionErrorResponse struct {
+	Error   string           `json:"error"`
+	From    db.OrderStatus   `json:"from"`
+	To      db.OrderStatus   `json:"to"`
+	Allowed []db.OrderStatus `json:"allowed"`
+}
+
+// UpdateOrderStatus moves an order to the requested status. Transitions the
+// state machine does not allow are rejected with 409.
+func (h *OrderHandler) UpdateOrderStatus(w http.ResponseWriter, r *http.Request) {
+	uid := r.PathValue("uid")
+
+	var req statusRequest
+	if err := json.NewDecoder(http.MaxBytesReader(w, r.Body, 1<<20)).Decode(&req); err != nil {
+		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+
+	change, err := h.db.UpdateOrderStatusContext(r.Context(), uid, req.Status, req.Reason)
+	var terr *db.TransitionError
+	switch {
+	case err == nil:
+	case errors.Is(err, db.ErrOrderNotFound):
+		http.Error(w, "Order not found", http.StatusNotFound)
+		return
+	case errors.Is(err, db.ErrInvalidStatus):
+		respondWithValidationError(w, http.StatusUnprocessableEntity, "", &validation.ValidationError{
+			Violations: []validation.Violation{{
+				Path:    "status",
+				Rule:    "status",
+				Value:   req.Status,
+				Message: "must be one of: " + statusList(db.Statuses()),
+			}},
+		})
+		return
+	case errors.As(err, &terr):
+		respondWithJSON(w, http.StatusConflict, transitionErrorResponse{
+			Error:   terr.Error(),
+			From:    terr.From,
+			To:      terr.To,
+			Allowed: terr.From.Next(),
+		})
+		return
+	default:
+		log.Printf("Failed to update status of order %s: %v", uid, err)
+		http.Error(w, "Failed to update order status", http.StatusInternalServerError)
+		return
+	}
+
+	h.cache.Delete(uid)
+	if change.From != change.To {
+		order, _ := h.db.GetOrderByUIDContext(r.Context(), uid)
+		h.hub.Publish(stream.StatusChanged(change, order))
+	}
+	respondWithJSON(w, http.StatusOK, change)
+}
+
+// GetOrderStatus returns the current status of an order, the statuses it may
+// move to and its status history.
+func (h *OrderHandler) GetOrderStatus(w http.ResponseWriter, r *http.Request) {
+	uid := r.PathValue("uid")
+
+	order, ok := h.cache.Get(uid)
+	if !ok {
+		var err error
+		order, err = h.db.GetOrderByUIDContext(r.Context(), uid)
+		if err != nil {
+			http.Error(w, "Order not found", http.StatusNotFound)
+			return
+		}
+	}
+
+	history, err := h.db.GetStatusHistoryContext(r.Context(), uid)
+	if err != nil {
+		if errors.Is(err, db.ErrOrderNotFound) {
+			http.Error(w, "Order not found", http.StatusNotFound)
+			return
+		}
+		log.Printf("Failed to get status history of order %s: %v", uid, err)
+		http.Error(w, "Failed to get order status", http.StatusInternalServerError)
+		return
+	}
+
+	respondWithJSON(w, http.StatusOK, statusResponse{
+		OrderUID: uid,
+		Status:   order.Status,
+		Allowed:  order.Status.Next(),
+		History:  history,
+	})
+}
+
+func statusList(statuses []db.OrderStatus) string {
+	names := make([]string, len(statuses))
+	for i, s := range statuses {
+		names[i] = string(s)
+	}
+	return strings.Join(names, ", ")
+}
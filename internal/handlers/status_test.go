@@ -0,0 +1,45 @@
+package handlers
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"order-service/internal/db"
+	"strings"
+	"testing"
+)
+
+func TestUpdateOrderStatus(t *testing.T) {
+	store := db.NewMemoryStore()
+	h := newTestHandler(store)
+	if rec := serve(h.CreateOrder, http.MethodPost, "/order", modelJSON(t, "status1"), nil); rec.Code != http.StatusCreated {
+		t.Fatalf("create: %d %s", rec.Code, rec.Body)
+	}
+
+	update := func(status string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodPatch, "/order/status1/status", strings.NewReader(`{"status": "`+status+`"}`))
+		req.SetPathValue("uid", "status1")
+		rec := httptest.NewRecorder()
+		h.UpdateOrderStatus(rec, req)
+		return rec
+	}
+
+	if rec := update("shipped"); rec.Code != http.StatusOK {
+		t.Errorf("assembling -> shipped: %d %s", rec.Code, rec.Body)
+	}
+
+	rec := update("paid")
+	var conflict transitionErrorResponse
+	json.NewDecoder(rec.Body).Decode(&conflict)
+	if rec.Code != http.StatusConflict || conflict.From != db.StatusShipped || len(conflict.Allowed) != 2 {
+		t.Errorf("shipped -> paid: %d %+v, want 409 listing the allowed statuses", rec.Code, conflict)
+	}
+
+	rec = update("lost")
+	var invalid validationErrorResponse
+	json.NewDecoder(rec.Body).Decode(&invalid)
+	want := "must be one of: created, paid, assembling, shipped, delivered, cancelled, returned"
+	if rec.Code != http.StatusUnprocessableEntity || len(invalid.Violations) != 1 || invalid.Violations[0].Message != want {
+		t.Errorf("unknown status: %d %+v", rec.Code, invalid)
+	}
+}
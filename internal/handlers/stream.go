@@ -0,0 +1,115 @@
+package handlers
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"order-service/internal/stream"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// StreamHandler serves order events as Server-Sent Events.
+type StreamHandler struct {
+	hub          *stream.Hub
+	heartbeat    time.Duration
+	writeTimeout time.Duration
+}
+
+func NewStreamHandler(hub *stream.Hub, heartbeat, writeTimeout time.Duration) *StreamHandler {
+	return &StreamHandler{hub: hub, heartbeat: heartbeat, writeTimeout: writeTimeout}
+}
+
+// ServeHTTP streams events until the client goes away. Query parameters
+// customer_id and delivery_service filter the events and may be repeated or
+// comma-separated. A client that reconnects with Last-Event-ID first receives
+// the buffered events it missed, or a stream.resync event when they are lost.
+// Clients that stop reading are disconnected once their buffer fills or a
+// write takes longer than the write timeout.
+func (s *StreamHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	rc := http.NewResponseController(w)
+
+	lastID, _ := strconv.ParseUint(r.Header.Get("Last-Event-ID"), 10, 64)
+	if v := r.URL.Query().Get("last_event_id"); v != "" && lastID == 0 {
+		lastID, _ = strconv.ParseUint(v, 10, 64)
+	}
+	filter := stream.MatchOrders(queryList(r, "customer_id"), queryList(r, "delivery_service"))
+
+	sub, replay, complete := s.hub.Subscribe(lastID, filter)
+	defer s.hub.Unsubscribe(sub)
+
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.Header().Set("X-Accel-Buffering", "no")
+	w.WriteHeader(http.StatusOK)
+
+	write := func(format string, args ...any) error {
+		rc.SetWriteDeadline(time.Now().Add(s.writeTimeout))
+		if _, err := fmt.Fprintf(w, format, args...); err != nil {
+			return err
+		}
+		return rc.Flush()
+	}
+
+	if err := write("retry: 3000\n\n"); err != nil {
+		return
+	}
+	if !complete {
+		if err := writeEvent(write, stream.Resync()); err != nil {
+			return
+		}
+	}
+	for _, e := range replay {
+		if err := writeEvent(write, e); err != nil {
+			return
+		}
+	}
+
+	ticker := time.NewTicker(s.heartbeat)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-r.Context().Done():
+			return
+		case <-ticker.C:
+			if err := write(": heartbeat\n\n"); err != nil {
+				return
+			}
+		case e, ok := <-sub.C:
+			if !ok {
+				if sub.Lagged() {
+					log.Printf("Disconnecting slow event stream client %s", r.RemoteAddr)
+				}
+				return
+			}
+			if err := writeEvent(write, e); err != nil {
+				return
+			}
+		}
+	}
+}
+
+func writeEvent(write func(string, ...any) error, e stream.Event) error {
+	data, err := json.Marshal(e)
+	if err != nil {
+		return err
+	}
+	if e.ID == 0 {
+		return write("event: %s\ndata: %s\n\n", e.Type, data)
+	}
+	return write("id: %d\nevent: %s\ndata: %s\n\n", e.ID, e.Type, data)
+}
+
+func queryList(r *http.Request, key string) []string {
+	var values []string
+	for _, v := range r.URL.Query()[key] {
+		for _, part := range strings.Split(v, ",") {
+			if part = strings.TrimSpace(part); part != "" {
+				values = append(values, part)
+			}
+		}
+	}
+	return values
+}
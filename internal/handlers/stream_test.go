@@ -0,0 +1,37 @@
+package handlers
+
+import (
+	"bufio"
+	"net/http"
+	"net/http/httptest"
+	"order-service/internal/stream"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestStreamAsksStaleClientsToResync(t *testing.T) {
+	hub := stream.NewHub(10, 10)
+	srv := httptest.NewServer(NewStreamHandler(hub, time.Minute, time.Second))
+	defer srv.Close()
+
+	// An ID from before the hub started, as after a restart.
+	req, _ := http.NewRequest(http.MethodGet, srv.URL, nil)
+	req.Header.Set("Last-Event-ID", "42")
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+
+	lines := bufio.NewScanner(resp.Body)
+	var event []string
+	for lines.Scan() && len(event) < 2 {
+		if line := lines.Text(); line != "" && !strings.HasPrefix(line, "retry:") {
+			event = append(event, line)
+		}
+	}
+	if len(event) != 2 || event[0] != "event: "+stream.EventResync || !strings.HasPrefix(event[1], "data: ") {
+		t.Errorf("first event = %q, want a stream.resync event without an id", event)
+	}
+}
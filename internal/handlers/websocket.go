@@ -0,0 +1,401 @@
+package handlers
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"order-service/internal/cache"
+	"order-service/internal/db"
+	"order-service/internal/stream"
+	"reflect"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/gorilla/websocket"
+)
+
+// WatchHandler lets clients follow individual orders over a WebSocket.
+//
+// Clients send {"op": "subscribe" | "unsubscribe", "order_uids": [...],
+// "track_numbers": [...]}. The server answers with a "subscribed" message
+// listing the current subscriptions, a "snapshot" for every order UID it
+// knows and for up to maxTrackSnapshots orders shipped under each track
+// number, and then an "update" with the changed fields whenever a watched
+// order changes. An order that shows up under a watched track number later
+// arrives in full in its first update.
+type WatchHandler struct {
+	hub          *stream.Hub
+	cache        *cache.Cache
+	db           db.Store
+	maxSubs      int
+	pingInterval time.Duration
+	upgrader     websocket.Upgrader
+}
+
+// maxTrackSnapshots caps the snapshots sent for one track number.
+const maxTrackSnapshots = 100
+
+func NewWatchHandler(hub *stream.Hub, cache *cache.Cache, db db.Store, maxSubs int, pingInterval time.Duration) *WatchHandler {
+	return &WatchHandler{
+		hub:          hub,
+		cache:        cache,
+		db:           db,
+		maxSubs:      maxSubs,
+		pingInterval: pingInterval,
+		upgrader:     websocket.Upgrader{ReadBufferSize: 1024, WriteBufferSize: 4096},
+	}
+}
+
+type watchRequest struct {
+	Op           string   `json:"op"`
+	OrderUIDs    []string `json:"order_uids"`
+	TrackNumbers []string `json:"track_numbers"`
+}
+
+type watchMessage struct {
+	Type         string           `json:"type"`
+	OrderUID     string           `json:"order_uid,omitempty"`
+	Event        string           `json:"event,omitempty"`
+	EventID      uint64           `json:"event_id,omitempty"`
+	Order        *db.Order        `json:"order,omitempty"`
+	Changes      []fieldChange    `json:"changes,omitempty"`
+	Status       *db.StatusChange `json:"status,omitempty"`
+	OrderUIDs    []string         `json:"order_uids,omitempty"`
+	TrackNumbers []string         `json:"track_numbers,omitempty"`
+	Error        string           `json:"error,omitempty"`
+}
+
+type fieldChange struct {
+	Path string `json:"path"`
+	Old  any    `json:"old"`
+	New  any    `json:"new"`
+}
+
+// watchSet is the set of orders one connection follows. It is read by the
+// hub while publishing, so it has its own lock.
+type watchSet struct {
+	mu     sync.RWMutex
+	uids   map[string]bool
+	tracks map[string]bool
+}
+
+func (s *watchSet) matches(e stream.Event) bool {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	if s.uids[e.OrderUID] {
+		return true
+	}
+	return e.Order != nil && s.tracks[e.Order.TrackNumber]
+}
+
+// watches reports whether the order with the given UID and track number is
+// still followed.
+func (s *watchSet) watches(uid, track string) bool {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return s.uids[uid] || s.tracks[track]
+}
+
+func (s *watchSet) list() (uids, tracks []string) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	for uid := range s.uids {
+		uids = append(uids, uid)
+	}
+	for track := range s.tracks {
+		tracks = append(tracks, track)
+	}
+	sort.Strings(uids)
+	sort.Strings(tracks)
+	return uids, tracks
+}
+
+func (h *WatchHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	conn, err := h.upgrader.Upgrade(w, r, nil)
+	if err != nil {
+		return
+	}
+	defer conn.Close()
+
+	set := &watchSet{uids: make(map[string]bool), tracks: make(map[string]bool)}
+	sub, _, _ := h.hub.Subscribe(0, set.matches)
+	defer h.hub.Unsubscribe(sub)
+
+	// quit stops the reader from blocking on out once the writer is gone.
+	out := make(chan watchMessage, 64)
+	done := make(chan struct{})
+	quit := make(chan struct{})
+	defer close(quit)
+	send := func(msg watchMessage) bool {
+		select {
+		case out <- msg:
+			return true
+		case <-quit:
+			return false
+		}
+	}
+	go func() {
+		defer close(done)
+		h.readLoop(r, conn, set, send)
+	}()
+
+	h.writeLoop(conn, set, sub, out, done)
+}
+
+func (h *WatchHandler) readLoop(r *http.Request, conn *websocket.Conn, set *watchSet, send func(watchMessage) bool) {
+	conn.SetReadLimit(64 << 10)
+	conn.SetReadDeadline(time.Now().Add(2 * h.pingInterval))
+	conn.SetPongHandler(func(string) error {
+		return conn.SetReadDeadline(time.Now().Add(2 * h.pingInterval))
+	})
+
+	for {
+		var req watchRequest
+		if err := conn.ReadJSON(&req); err != nil {
+			var syntaxErr *json.SyntaxError
+			var typeErr *json.UnmarshalTypeError
+			if (errors.As(err, &syntaxErr) || errors.As(err, &typeErr)) && send(watchMessage{Type: "error", Error: "invalid message"}) {
+				continue
+			}
+			return
+		}
+
+		switch req.Op {
+		case "subscribe":
+			if err := h.subscribe(set, req); err != nil {
+				if !send(watchMessage{Type: "error", Error: err.Error()}) {
+					return
+				}
+				continue
+			}
+		case "unsubscribe":
+			set.mu.Lock()
+			for _, uid := range req.OrderUIDs {
+				delete(set.uids, uid)
+			}
+			for _, track := range req.TrackNumbers {
+				delete(set.tracks, track)
+			}
+			set.mu.Unlock()
+		default:
+			if !send(watchMessage{Type: "error", Error: fmt.Sprintf("unknown op %q", req.Op)}) {
+				return
+			}
+			continue
+		}
+
+		uids, tracks := set.list()
+		if !send(watchMessage{Type: "subscribed", OrderUIDs: uids, TrackNumbers: tracks}) {
+			return
+		}
+
+		if req.Op == "subscribe" {
+			for _, uid := range req.OrderUIDs {
+				msg := watchMessage{Type: "snapshot", OrderUID: uid}
+				order, ok := h.cache.Get(uid)
+				if !ok {
+					var err error
+					if order, err = h.db.GetOrderByUIDContext(r.Context(), uid); err != nil {
+						msg = watchMessage{Type: "error", OrderUID: uid, Error: "order not found"}
+					}
+				}
+				msg.Order = order
+				if !send(msg) {
+					return
+				}
+			}
+			for _, track := range req.TrackNumbers {
+				if !h.sendTrackSnapshots(r, track, send) {
+					return
+				}
+			}
+		}
+	}
+}
+
+// sendTrackSnapshots sends a snapshot of every order shipped under track. A
+// track number without orders is not an error: they may still arrive.
+func (h *WatchHandler) sendTrackSnapshots(r *http.Request, track string, send func(watchMessage) bool) bool {
+	refs, err := h.db.ListOrderRefsContext(r.Context(), db.OrderFilter{TrackNumber: track, Limit: maxTrackSnapshots})
+	if err != nil {
+		log.Printf("Failed to list orders for track number %s: %v", track, err)
+		return send(watchMessage{Type: "error", TrackNumbers: []string{track}, Error: "failed to load orders"})
+	}
+	uids := make([]string, len(refs))
+	for i, ref := range refs {
+		uids[i] = ref.UID
+	}
+	orders, err := h.db.GetOrdersByUIDsContext(r.Context(), uids)
+	if err != nil {
+		log.Printf("Failed to load orders for track number %s: %v", track, err)
+		return send(watchMessage{Type: "error", TrackNumbers: []string{track}, Error: "failed to load orders"})
+	}
+	for i := range orders {
+		if !send(watchMessage{Type: "snapshot", OrderUID: orders[i].OrderUID, Order: &orders[i]}) {
+			return false
+		}
+	}
+	return true
+}
+
+func (h *WatchHandler) subscribe(set *watchSet, req watchRequest) error {
+	set.mu.Lock()
+	defer set.mu.Unlock()
+
+	added := 0
+	for _, uid := range req.OrderUIDs {
+		if !set.uids[uid] {
+			added++
+		}
+	}
+	for _, track := range req.TrackNumbers {
+		if !set.tracks[track] {
+			added++
+		}
+	}
+	if len(set.uids)+len(set.tracks)+added > h.maxSubs {
+		return fmt.Errorf("subscription limit of %d reached", h.maxSubs)
+	}
+	for _, uid := range req.OrderUIDs {
+		set.uids[uid] = true
+	}
+	for _, track := range req.TrackNumbers {
+		set.tracks[track] = true
+	}
+	return nil
+}
+
+// writeLoop owns every write to the connection. It remembers the last
+// snapshot sent per order so updates carry only the changed fields, and
+// forgets the orders no longer watched whenever the subscriptions change.
+func (h *WatchHandler) writeLoop(conn *websocket.Conn, set *watchSet, sub *stream.Subscription, out <-chan watchMessage, done <-chan struct{}) {
+	last := make(map[string]map[string]any)
+	ping := time.NewTicker(h.pingInterval)
+	defer ping.Stop()
+
+	write := func(msg watchMessage) bool {
+		conn.SetWriteDeadline(time.Now().Add(h.pingInterval))
+		return conn.WriteJSON(msg) == nil
+	}
+
+	for {
+		select {
+		case <-done:
+			return
+		case <-ping.C:
+			if err := conn.WriteControl(websocket.PingMessage, nil, time.Now().Add(h.pingInterval)); err != nil {
+				return
+			}
+		case msg := <-out:
+			switch msg.Type {
+			case "snapshot":
+				last[msg.OrderUID] = flatten(msg.Order)
+			case "subscribed":
+				prune(last, set)
+			}
+			if !write(msg) {
+				return
+			}
+		case e, ok := <-sub.C:
+			if !ok {
+				if sub.Lagged() {
+					log.Printf("Disconnecting slow order watcher")
+					conn.WriteControl(websocket.CloseMessage,
+						websocket.FormatCloseMessage(websocket.CloseTryAgainLater, "too slow"),
+						time.Now().Add(time.Second))
+				}
+				return
+			}
+			if !write(updateMessage(e, last)) {
+				return
+			}
+		}
+	}
+}
+
+func updateMessage(e stream.Event, last map[string]map[string]any) watchMessage {
+	msg := watchMessage{Type: "update", OrderUID: e.OrderUID, Event: e.Type, EventID: e.ID, Status: e.Status}
+	if e.Type == stream.EventOrderDeleted {
+		delete(last, e.OrderUID)
+		return msg
+	}
+	if e.Order == nil {
+		if e.Status != nil && last[e.OrderUID] != nil {
+			last[e.OrderUID]["status"] = string(e.Status.To)
+		}
+		return msg
+	}
+	current := flatten(e.Order)
+	previous, ok := last[e.OrderUID]
+	last[e.OrderUID] = current
+	if !ok {
+		msg.Order = e.Order
+		return msg
+	}
+	msg.Changes = diff(previous, current)
+	return msg
+}
+
+// prune drops the remembered orders set no longer watches.
+func prune(last map[string]map[string]any, set *watchSet) {
+	for uid, fields := range last {
+		track, _ := fields["track_number"].(string)
+		if !set.watches(uid, track) {
+			delete(last, uid)
+		}
+	}
+}
+
+// flatten turns an order into a map of JSON paths such as "items[0].price"
+// to scalar values.
+func flatten(order *db.Order) map[string]any {
+	var doc any
+	data, _ := json.Marshal(order)
+	json.Unmarshal(data, &doc)
+
+	fields := make(map[string]any)
+	var walk func(prefix string, v any)
+	walk = func(prefix string, v any) {
+		switch v := v.(type) {
+		case map[string]any:
+			for k, child := range v {
+				walk(joinField(prefix, k), child)
+			}
+		case []any:
+			fields[prefix+".length"] = float64(len(v))
+			for i, child := range v {
+				walk(fmt.Sprintf("%s[%d]", prefix, i), child)
+			}
+		default:
+			fields[prefix] = v
+		}
+	}
+	walk("", doc)
+	return fields
+}
+
+func joinField(prefix, key string) string {
+	if prefix == "" {
+		return key
+	}
+	return prefix + "." + key
+}
+
+func diff(previous, current map[string]any) []fieldChange {
+	var changes []fieldChange
+	for path, v := range current {
+		if old, ok := previous[path]; !ok || !reflect.DeepEqual(old, v) {
+			changes = append(changes, fieldChange{Path: path, Old: previous[path], New: v})
+		}
+	}
+	for path, old := range previous {
+		if _, ok := current[path]; !ok {
+			changes = append(changes, fieldChange{Path: path, Old: old})
+		}
+	}
+	sort.Slice(changes, func(i, j int) bool { return changes[i].Path < changes[j].Path })
+	return changes
+}
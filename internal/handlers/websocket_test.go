@@ -0,0 +1,206 @@
+package handlers
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"order-service/internal/db"
+	"order-service/internal/stream"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gorilla/websocket"
+)
+
+// watchClient is a test client of the order watch WebSocket.
+type watchClient struct {
+	t    *testing.T
+	conn *websocket.Conn
+}
+
+func dialWatch(t *testing.T, h *OrderHandler) *watchClient {
+	t.Helper()
+	server := httptest.NewServer(NewWatchHandler(h.hub, h.cache, h.db, 3, time.Second))
+	t.Cleanup(server.Close)
+	conn, _, err := websocket.DefaultDialer.Dial("ws"+strings.TrimPrefix(server.URL, "http"), nil)
+	if err != nil {
+		t.Fatalf("dial: %v", err)
+	}
+	t.Cleanup(func() { conn.Close() })
+	return &watchClient{t: t, conn: conn}
+}
+
+func (c *watchClient) send(msg string) {
+	c.t.Helper()
+	if err := c.conn.WriteMessage(websocket.TextMessage, []byte(msg)); err != nil {
+		c.t.Fatalf("send %s: %v", msg, err)
+	}
+}
+
+func (c *watchClient) read() watchMessage {
+	c.t.Helper()
+	c.conn.SetReadDeadline(time.Now().Add(time.Second))
+	var msg watchMessage
+	if err := c.conn.ReadJSON(&msg); err != nil {
+		c.t.Fatalf("read: %v", err)
+	}
+	return msg
+}
+
+func (c *watchClient) expect(typ string) watchMessage {
+	c.t.Helper()
+	msg := c.read()
+	if msg.Type != typ {
+		c.t.Fatalf("got %+v, want a %s message", msg, typ)
+	}
+	return msg
+}
+
+func createOrders(t *testing.T, h *OrderHandler, uids ...string) {
+	t.Helper()
+	for _, uid := range uids {
+		if rec := serve(h.CreateOrder, http.MethodPost, "/order", modelJSON(t, uid), nil); rec.Code != http.StatusCreated {
+			t.Fatalf("create %s: %d %s", uid, rec.Code, rec.Body)
+		}
+	}
+}
+
+func moveToCity(t *testing.T, h *OrderHandler, uid, city string) {
+	t.Helper()
+	order, err := h.db.GetOrderByUIDContext(context.Background(), uid)
+	if err != nil {
+		t.Fatal(err)
+	}
+	order.Delivery.City = city
+	h.hub.Publish(stream.OrderUpdated(order))
+}
+
+func TestWatchSubscriptions(t *testing.T) {
+	h := newTestHandler(db.NewMemoryStore())
+	createOrders(t, h, "w1", "w2")
+	c := dialWatch(t, h)
+
+	c.send(`{"op": "subscribe", "order_uids": ["w1", "missing"]}`)
+	msg := c.expect("subscribed")
+	if fmt.Sprint(msg.OrderUIDs) != "[missing w1]" {
+		t.Errorf("subscribed to %v, want [missing w1]", msg.OrderUIDs)
+	}
+	if msg = c.expect("snapshot"); msg.OrderUID != "w1" || msg.Order == nil || msg.Order.OrderUID != "w1" {
+		t.Errorf("snapshot = %+v, want order w1", msg)
+	}
+	if msg = c.expect("error"); msg.OrderUID != "missing" || msg.Error != "order not found" {
+		t.Errorf("error = %+v, want order not found for missing", msg)
+	}
+
+	c.send(`{"op": "subscribe", "order_uids": ["w2", "w3", "w4"]}`)
+	if msg = c.expect("error"); msg.Error != "subscription limit of 3 reached" {
+		t.Errorf("error = %q, want the subscription limit", msg.Error)
+	}
+	c.send(`{"op": "subscribe", "order_uids": "w2"}`)
+	if msg = c.expect("error"); msg.Error != "invalid message" {
+		t.Errorf("error = %q, want invalid message", msg.Error)
+	}
+	c.send(`{"op": "watch"}`)
+	if msg = c.expect("error"); msg.Error != `unknown op "watch"` {
+		t.Errorf("error = %q, want unknown op", msg.Error)
+	}
+
+	c.send(`{"op": "unsubscribe", "order_uids": ["missing", "w1"]}`)
+	if msg = c.expect("subscribed"); len(msg.OrderUIDs) != 0 {
+		t.Errorf("subscribed to %v after unsubscribing from everything", msg.OrderUIDs)
+	}
+	// The update to the order no longer watched is not sent: the next
+	// message is the answer to the following request.
+	moveToCity(t, h, "w1", "Kazan")
+	c.send(`{"op": "subscribe", "order_uids": ["w2"]}`)
+	if msg = c.expect("subscribed"); fmt.Sprint(msg.OrderUIDs) != "[w2]" {
+		t.Errorf("subscribed to %v, want [w2]", msg.OrderUIDs)
+	}
+}
+
+func TestWatchUpdates(t *testing.T) {
+	h := newTestHandler(db.NewMemoryStore())
+	createOrders(t, h, "w1")
+	c := dialWatch(t, h)
+
+	c.send(`{"op": "subscribe", "order_uids": ["w1"]}`)
+	c.expect("subscribed")
+	c.expect("snapshot")
+
+	moveToCity(t, h, "w1", "Kazan")
+	msg := c.expect("update")
+	if msg.Event != stream.EventOrderUpdated || msg.Order != nil {
+		t.Errorf("update = %+v, want order.updated without the order", msg)
+	}
+	if len(msg.Changes) != 1 || msg.Changes[0] != (fieldChange{Path: "delivery.city", Old: "Kiryat Mozkin", New: "Kazan"}) {
+		t.Errorf("changes = %+v, want only delivery.city", msg.Changes)
+	}
+
+	order, err := h.db.GetOrderByUIDContext(context.Background(), "w1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	req := httptest.NewRequest(http.MethodDelete, "/order/w1", nil)
+	req.SetPathValue("uid", "w1")
+	rec := httptest.NewRecorder()
+	h.DeleteOrder(rec, req)
+	if rec.Code != http.StatusNoContent {
+		t.Fatalf("delete: %d", rec.Code)
+	}
+	if msg = c.expect("update"); msg.Event != stream.EventOrderDeleted || msg.OrderUID != "w1" || msg.Changes != nil {
+		t.Errorf("update = %+v, want order.deleted for w1", msg)
+	}
+
+	// After a delete the order is forgotten, so it comes back in full.
+	order.Delivery.City = "Omsk"
+	h.hub.Publish(stream.OrderUpdated(order))
+	if msg = c.expect("update"); msg.Order == nil || msg.Order.Delivery.City != "Omsk" || msg.Changes != nil {
+		t.Errorf("update = %+v, want the full order", msg)
+	}
+}
+
+func TestWatchTrackNumbers(t *testing.T) {
+	h := newTestHandler(db.NewMemoryStore())
+	createOrders(t, h, "w1", "w2")
+	other := strings.ReplaceAll(modelJSON(t, "w3"), "WBILMTESTTRACK", "WBILMOTHERTRACK")
+	if rec := serve(h.CreateOrder, http.MethodPost, "/order", other, nil); rec.Code != http.StatusCreated {
+		t.Fatalf("create w3: %d %s", rec.Code, rec.Body)
+	}
+	c := dialWatch(t, h)
+
+	c.send(`{"op": "subscribe", "track_numbers": ["WBILMTESTTRACK", "NOSUCHTRACK"]}`)
+	if msg := c.expect("subscribed"); fmt.Sprint(msg.TrackNumbers) != "[NOSUCHTRACK WBILMTESTTRACK]" {
+		t.Errorf("subscribed to %v", msg.TrackNumbers)
+	}
+	snapshots := map[string]bool{}
+	for range 2 {
+		msg := c.expect("snapshot")
+		if msg.Order == nil || msg.Order.TrackNumber != "WBILMTESTTRACK" {
+			t.Errorf("snapshot = %+v, want an order under WBILMTESTTRACK", msg)
+		}
+		snapshots[msg.OrderUID] = true
+	}
+	if !snapshots["w1"] || !snapshots["w2"] {
+		t.Errorf("snapshots of %v, want w1 and w2", snapshots)
+	}
+
+	moveToCity(t, h, "w2", "Kazan")
+	if msg := c.expect("update"); msg.OrderUID != "w2" || len(msg.Changes) != 1 || msg.Changes[0].Path != "delivery.city" {
+		t.Errorf("update = %+v, want only w2's delivery.city", msg)
+	}
+}
+
+func TestPruneForgetsUnwatchedOrders(t *testing.T) {
+	set := &watchSet{uids: map[string]bool{"w1": true}, tracks: map[string]bool{"T1": true}}
+	last := map[string]map[string]any{
+		"w1": {"track_number": "T9"},
+		"w2": {"track_number": "T1"},
+		"w3": {"track_number": "T9"},
+	}
+	prune(last, set)
+	if len(last) != 2 || last["w1"] == nil || last["w2"] == nil {
+		t.Errorf("kept %v, want w1 by UID and w2 by track number", last)
+	}
+}
@@ -0,0 +1,101 @@
+package ingest
+
+import (
+	"context"
+	"order-service/config"
+	"order-service/internal/codec"
+	"order-service/internal/db"
+	"order-service/internal/schema"
+	"order-service/internal/validation"
+)
+
+// Stages a payload can be rejected at.
+const (
+	StageContentType = "content-type"
+	StageVersion     = "version"
+	StageSchema      = "schema"
+	StageDecode      = "decode"
+	StageValidation  = "validation"
+)
+
+// Pipeline turns a raw order payload into a validated order. It is shared by
+// the Kafka consumer and the HTTP ingestion endpoints so both accept exactly
+// the same messages.
+type Pipeline struct {
+	schema  schema.Schema
+	codecs  codec.Options
+	version int
+}
+
+func NewPipeline(cfg *config.Config) *Pipeline {
+	p := &Pipeline{
+		codecs:  codec.Options{Strict: cfg.KafkaStrictDecoding},
+		version: cfg.KafkaSchemaVersion,
+	}
+	if cfg.KafkaSchemaValidation {
+		p.schema = schema.Order()
+	}
+	if cfg.SchemaRegistryURL != "" {
+		p.codecs.Registry = codec.NewRegistry(cfg.SchemaRegistryURL, cfg.SchemaRegistryTimeout)
+	}
+	return p
+}
+
+// Error reports the stage that rejected a payload and why.
+type Error struct {
+	Stage  string
+	Report *validation.ValidationError
+}
+
+func (e *Error) Error() string {
+	return e.Stage + ": " + e.Report.Error()
+}
+
+func reject(stage string, err error) *Error {
+	return &Error{Stage: stage, Report: validation.AsValidationError(err, stage)}
+}
+
+// Decode picks the codec for contentType, brings JSON payloads up to the
+// current schema version, optionally checks them against the JSON Schema,
+// decodes, normalizes and validates the order. The schema check sees the
+// payload normalized the same way as the decoded order, so both accept the
+// same spellings. Failures are returned as *Error, except when the schema
+// registry is unavailable: that error is returned as is and satisfies
+// codec.IsRetryable, since the payload may be fine. ctx bounds the schema
+// registry lookup of Avro messages.
+func (p *Pipeline) Decode(ctx context.Context, contentType, version string, payload []byte) (*db.Order, error) {
+	dec, err := codec.ForContentType(contentType, payload, p.codecs)
+	if err != nil {
+		return nil, reject(StageContentType, err)
+	}
+
+	if dec.ContentType() == codec.ContentTypeJSON {
+		v, err := schema.ParseVersion(version, 0)
+		if err == nil {
+			payload, err = schema.Upcast(payload, v, p.version)
+		}
+		if err != nil {
+			return nil, reject(StageVersion, err)
+		}
+
+		if p.schema != nil {
+			if violations := schema.Validate(p.schema, validation.NormalizeJSON(payload)); len(violations) > 0 {
+				return nil, &Error{Stage: StageSchema, Report: &validation.ValidationError{Violations: violations}}
+			}
+		}
+	}
+
+	order, err := dec.Decode(ctx, payload)
+	if err != nil {
+		if codec.IsRetryable(err) {
+			return nil, err
+		}
+		return nil, reject(StageDecode, err)
+	}
+
+	validation.NormalizeOrder(order)
+	if err := validation.ValidateOrder(order); err != nil {
+		return nil, reject(StageValidation, err)
+	}
+	return order, nil
+}
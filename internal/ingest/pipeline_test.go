@@ -0,0 +1,100 @@
+package ingest
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"order-service/config"
+	"order-service/internal/codec"
+	"order-service/internal/validation"
+	"os"
+	"testing"
+	"time"
+)
+
+func newTestPipeline() *Pipeline {
+	return NewPipeline(&config.Config{KafkaSchemaValidation: true, KafkaSchemaVersion: 2})
+}
+
+func modelPayload(t *testing.T, modify func(doc map[string]any)) []byte {
+	t.Helper()
+	data, err := os.ReadFile("../../model.json")
+	if err != nil {
+		t.Fatal(err)
+	}
+	var doc map[string]any
+	if err := json.Unmarshal(data, &doc); err != nil {
+		t.Fatal(err)
+	}
+	modify(doc)
+	payload, _ := json.Marshal(doc)
+	return payload
+}
+
+func TestPipelineChecksSchemaAfterNormalizing(t *testing.T) {
+	defer validation.SetPhoneRegion(validation.PhoneRegion())
+	validation.SetPhoneRegion("RU")
+
+	payload := modelPayload(t, func(doc map[string]any) {
+		doc["delivery"].(map[string]any)["phone"] = "8 (916) 123-45-67"
+		doc["payment"].(map[string]any)["currency"] = " usd"
+	})
+	order, err := newTestPipeline().Decode(context.Background(), "", "", payload)
+	if err != nil {
+		t.Fatalf("Decode: %v", err)
+	}
+	if order.Delivery.Phone != "+79161234567" || order.Payment.Currency != "USD" {
+		t.Errorf("phone %q, currency %q", order.Delivery.Phone, order.Payment.Currency)
+	}
+}
+
+func TestPipelineRejectsAtStage(t *testing.T) {
+	tests := []struct {
+		name        string
+		contentType string
+		modify      func(doc map[string]any)
+		stage       string
+	}{
+		{"unknown content type", "text/plain", func(doc map[string]any) {}, StageContentType},
+		{"schema", "", func(doc map[string]any) { doc["colour"] = "red" }, StageSchema},
+		{"unnormalizable phone", "", func(doc map[string]any) {
+			doc["delivery"].(map[string]any)["phone"] = "phone"
+		}, StageSchema},
+		{"validation", "", func(doc map[string]any) {
+			doc["payment"].(map[string]any)["amount"] = 9
+		}, StageValidation},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := newTestPipeline().Decode(context.Background(), tt.contentType, "", modelPayload(t, tt.modify))
+			var ierr *Error
+			if !errors.As(err, &ierr) || ierr.Stage != tt.stage {
+				t.Errorf("Decode error = %v, want rejection at %s", err, tt.stage)
+			}
+		})
+	}
+}
+
+func TestPipelineReturnsRegistryOutagesForRetry(t *testing.T) {
+	status := http.StatusServiceUnavailable
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		http.Error(w, "unavailable", status)
+	}))
+	defer srv.Close()
+	p := NewPipeline(&config.Config{SchemaRegistryURL: srv.URL, SchemaRegistryTimeout: time.Second})
+	avro := []byte{0, 0, 0, 0, 7, 2}
+
+	_, err := p.Decode(context.Background(), "", "", avro)
+	var ierr *Error
+	if !codec.IsRetryable(err) || errors.As(err, &ierr) {
+		t.Errorf("registry down: err = %v, want a retryable error that is not a rejection", err)
+	}
+
+	status = http.StatusNotFound
+	_, err = p.Decode(context.Background(), "", "", avro)
+	if !errors.As(err, &ierr) || ierr.Stage != StageDecode {
+		t.Errorf("unknown schema: err = %v, want rejection at %s", err, StageDecode)
+	}
+}
@@ -2,24 +2,50 @@ package kafka
 
 import (
 	"context"
-	"encoding/json"
+	"errors"
 	"log"
 	"order-service/config"
 	"order-service/internal/cache"
+	"order-service/internal/codec"
 	"order-service/internal/db"
+	"order-service/internal/ingest"
+	"order-service/internal/schema"
+	"order-service/internal/stream"
 	"order-service/internal/validation"
+	"strings"
 	"time"
 
 	"github.com/segmentio/kafka-go"
 )
 
+// Backoff bounds for retrying a message while the schema registry or the
+// dead-letter topic is down.
+const (
+	retryMin = time.Second
+	retryMax = 30 * time.Second
+)
+
+type messageReader interface {
+	FetchMessage(ctx context.Context) (kafka.Message, error)
+	CommitMessages(ctx context.Context, msgs ...kafka.Message) error
+	Close() error
+}
+
 type Consumer struct {
-	reader *kafka.Reader
-	db     *db.Database
-	cache  *cache.Cache
+	reader   messageReader
+	dlq      messageWriter
+	retryMin time.Duration
+	retryMax time.Duration
+	pipeline *ingest.Pipeline
+	db       db.Store
+	cache    *cache.Cache
+	hub      *stream.Hub
+	ctx      context.Context
+	cancel   context.CancelFunc
 }
 
-func NewConsumer(cfg *config.Config, db *db.Database, cache *cache.Cache) *Consumer {
+func NewConsumer(cfg *config.Config, pipeline *ingest.Pipeline, db db.Store, cache *cache.Cache, hub *stream.Hub) *Consumer {
+	ctx, cancel := context.WithCancel(context.Background())
 	return &Consumer{
 		reader: kafka.NewReader(kafka.ReaderConfig{
 			Brokers:        cfg.KafkaBrokers,
@@ -29,8 +55,20 @@ func NewConsumer(cfg *config.Config, db *db.Database, cache *cache.Cache) *Consu
 			MaxBytes:       10e6,
 			CommitInterval: time.Second,
 		}),
-		db:    db,
-		cache: cache}
+		dlq: &kafka.Writer{
+			Addr:         kafka.TCP(cfg.KafkaBrokers...),
+			Topic:        cfg.KafkaDLQTopic,
+			Balancer:     &kafka.Hash{},
+			BatchTimeout: 10 * time.Millisecond,
+		},
+		retryMin: retryMin,
+		retryMax: retryMax,
+		pipeline: pipeline,
+		db:       db,
+		cache:    cache,
+		hub:      hub,
+		ctx:      ctx,
+		cancel:   cancel}
 }
 
 func (c *Consumer) Start() {
@@ -39,39 +77,95 @@ func (c *Consumer) Start() {
 
 func (c *Consumer) ConsumeMessages() {
 	for {
-		msg, err := c.reader.FetchMessage(context.Background())
+		msg, err := c.reader.FetchMessage(c.ctx)
 		if err != nil {
+			if c.ctx.Err() != nil {
+				return
+			}
 			log.Printf("Failed to fetch message: %v", err)
 			time.Sleep(1 * time.Second)
 			continue
 		}
 
-		var order db.Order
-		if err := json.Unmarshal(msg.Value, &order); err != nil {
-			log.Printf("Failed to unmarshal message: %v", err)
-			c.reader.CommitMessages(context.Background(), msg)
+		order, err := c.decode(msg)
+		if err != nil {
+			if c.ctx.Err() != nil {
+				return
+			}
+			log.Printf("Rejected message at offset %d: %v", msg.Offset, err)
+			var ierr *ingest.Error
+			if errors.As(err, &ierr) {
+				err = c.reject(msg, ierr.Stage, ierr.Report)
+			} else {
+				err = c.reject(msg, ingest.StageDecode, validation.AsValidationError(err, ingest.StageDecode))
+			}
+			if err != nil {
+				// Closed before the message reached the dead-letter topic: it
+				// stays uncommitted and is fetched again after a restart.
+				return
+			}
 			continue
-		}
-
-		if err := validation.ValidateOrder(&order); err != nil {
-			log.Printf("Validation failed: %v", err)
-			return
 		} else {
 			log.Printf("validation successfully!")
-			if err := c.db.SaveOrder(&order); err != nil {
+			if err := c.db.SaveOrderContext(c.ctx, order); err != nil {
 				log.Printf("Failed to save order to DB: %v", err)
 			} else {
-				c.cache.Set(&order)
+				c.cache.Set(order)
+				c.hub.Publish(stream.OrderSaved(order))
 				log.Printf("Order %s saved and cached", order.OrderUID)
 			}
 		}
 
-		if err := c.reader.CommitMessages(context.Background(), msg); err != nil {
+		if err := c.reader.CommitMessages(c.ctx, msg); err != nil {
 			log.Printf("Failed to commit message: %v", err)
 		}
 	}
 }
 
+// decode runs msg through the pipeline. While the schema registry is
+// unavailable the same message is retried with backoff instead of being
+// dead-lettered, so it is neither lost nor skipped.
+func (c *Consumer) decode(msg kafka.Message) (*db.Order, error) {
+	backoff := c.retryMin
+	for {
+		order, err := c.pipeline.Decode(c.ctx, header(msg, codec.HeaderContentType), header(msg, schema.HeaderVersion), msg.Value)
+		if !codec.IsRetryable(err) {
+			return order, err
+		}
+		log.Printf("Retrying message at offset %d in %s: %v", msg.Offset, backoff, err)
+		select {
+		case <-c.ctx.Done():
+			return nil, c.ctx.Err()
+		case <-time.After(backoff):
+		}
+		backoff = min(2*backoff, c.retryMax)
+	}
+}
+
+// reject commits msg only once the dead-letter topic has it. Until then the
+// write is retried with backoff, so a rejected message is never lost; the
+// error is the consumer closing first.
+func (c *Consumer) reject(msg kafka.Message, reason string, report *validation.ValidationError) error {
+	if err := deadLetterWithRetry(c.ctx, c.dlq, msg, reason, report, c.retryMin, c.retryMax); err != nil {
+		return err
+	}
+	if err := c.reader.CommitMessages(c.ctx, msg); err != nil {
+		log.Printf("Failed to commit message: %v", err)
+	}
+	return nil
+}
+
+func header(msg kafka.Message, key string) string {
+	for _, h := range msg.Headers {
+		if strings.EqualFold(h.Key, key) {
+			return string(h.Value)
+		}
+	}
+	return ""
+}
+
 func (c *Consumer) Close() error {
+	c.cancel()
+	c.dlq.Close()
 	return c.reader.Close()
 }
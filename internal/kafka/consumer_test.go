@@ -0,0 +1,201 @@
+package kafka
+
+import (
+	"context"
+	"encoding/json"
+	"order-service/config"
+	"order-service/internal/cache"
+	"order-service/internal/db"
+	"order-service/internal/ingest"
+	"order-service/internal/stream"
+	"order-service/internal/validation"
+	"os"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/segmentio/kafka-go"
+)
+
+// fakeReader hands out queued messages and records the offsets committed.
+type fakeReader struct {
+	msgs chan kafka.Message
+
+	mu      sync.Mutex
+	commits []int64
+}
+
+func newFakeReader(msgs ...kafka.Message) *fakeReader {
+	r := &fakeReader{msgs: make(chan kafka.Message, len(msgs))}
+	for i, msg := range msgs {
+		msg.Topic, msg.Partition, msg.Offset = "orders", 0, int64(i)
+		r.msgs <- msg
+	}
+	return r
+}
+
+func (r *fakeReader) FetchMessage(ctx context.Context) (kafka.Message, error) {
+	select {
+	case msg := <-r.msgs:
+		return msg, nil
+	case <-ctx.Done():
+		return kafka.Message{}, ctx.Err()
+	}
+}
+
+func (r *fakeReader) CommitMessages(ctx context.Context, msgs ...kafka.Message) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	for _, msg := range msgs {
+		r.commits = append(r.commits, msg.Offset)
+	}
+	return nil
+}
+
+func (r *fakeReader) committed() []int64 {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return append([]int64(nil), r.commits...)
+}
+
+func (r *fakeReader) Close() error { return nil }
+
+// waitFor polls cond until it holds or a second has passed.
+func waitFor(t *testing.T, what string, cond func() bool) {
+	t.Helper()
+	deadline := time.Now().Add(time.Second)
+	for !cond() {
+		if time.Now().After(deadline) {
+			t.Fatalf("timed out waiting for %s", what)
+		}
+		time.Sleep(time.Millisecond)
+	}
+}
+
+// runConsumer consumes from reader until stop is called; stop returns once
+// the consumer has returned.
+func runConsumer(reader messageReader, dlq messageWriter, store db.Store) (stop func()) {
+	ctx, cancel := context.WithCancel(context.Background())
+	c := &Consumer{
+		reader:   reader,
+		dlq:      dlq,
+		retryMin: time.Millisecond,
+		retryMax: 4 * time.Millisecond,
+		pipeline: ingest.NewPipeline(&config.Config{KafkaSchemaVersion: 2}),
+		db:       store,
+		cache:    cache.NewCache(),
+		hub:      stream.NewHub(16, 16),
+		ctx:      ctx,
+		cancel:   cancel,
+	}
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		c.ConsumeMessages()
+	}()
+	return func() {
+		cancel()
+		<-done
+	}
+}
+
+func orderMessage(t *testing.T, uid string, edit func(string) string) kafka.Message {
+	t.Helper()
+	data, err := os.ReadFile("../../model.json")
+	if err != nil {
+		t.Fatal(err)
+	}
+	value := strings.ReplaceAll(string(data), "b563feb7b2b84b6test", uid)
+	if edit != nil {
+		value = edit(value)
+	}
+	return kafka.Message{
+		Key:     []byte(uid),
+		Value:   []byte(value),
+		Headers: []kafka.Header{{Key: "trace-id", Value: []byte(uid)}},
+	}
+}
+
+func TestConsumerDeadLettersRejectedMessages(t *testing.T) {
+	badEmail := orderMessage(t, "bad", func(v string) string {
+		return strings.Replace(v, "test@gmail.com", "not-an-email", 1)
+	})
+	wrongType := kafka.Message{Key: []byte("junk"), Value: []byte(`{"order_uid": 1}`)}
+	reader := newFakeReader(badEmail, wrongType, orderMessage(t, "good", nil))
+	dlq := &fakeWriter{}
+	store := db.NewMemoryStore()
+	stop := runConsumer(reader, dlq, store)
+	waitFor(t, "three commits", func() bool { return len(reader.committed()) == 3 })
+	stop()
+
+	if exists, _ := store.OrderExistsContext(context.Background(), "good"); !exists {
+		t.Error("valid order not saved")
+	}
+	if len(dlq.writes) != 2 {
+		t.Fatalf("dead-lettered %d writes, want 2", len(dlq.writes))
+	}
+
+	msg := dlq.writes[0][0]
+	if string(msg.Key) != "bad" || string(msg.Value) != string(badEmail.Value) {
+		t.Errorf("dead letter = %s %s, want the rejected message unchanged", msg.Key, msg.Value)
+	}
+	headers := map[string]string{}
+	for _, h := range msg.Headers {
+		headers[h.Key] = string(h.Value)
+	}
+	want := map[string]string{
+		"trace-id":             "bad",
+		"dlq-reason":           ingest.StageValidation,
+		"dlq-source-topic":     "orders",
+		"dlq-source-partition": "0",
+		"dlq-source-offset":    "0",
+	}
+	for k, v := range want {
+		if headers[k] != v {
+			t.Errorf("header %s = %q, want %q", k, headers[k], v)
+		}
+	}
+	var report validation.ValidationError
+	if err := json.Unmarshal([]byte(headers["validation-report"]), &report); err != nil {
+		t.Fatalf("validation-report header %q: %v", headers["validation-report"], err)
+	}
+	if len(report.Violations) != 1 || report.Violations[0].Path != "delivery.email" || report.Violations[0].Rule != "email" {
+		t.Errorf("report = %+v, want one email violation on delivery.email", report)
+	}
+
+	msg = dlq.writes[1][0]
+	headers = map[string]string{}
+	for _, h := range msg.Headers {
+		headers[h.Key] = string(h.Value)
+	}
+	if headers["dlq-reason"] != ingest.StageDecode || headers["dlq-source-offset"] != "1" {
+		t.Errorf("undecodable message headers = %v, want reason decode at offset 1", headers)
+	}
+}
+
+func TestConsumerCommitsRejectedMessageOnlyOnceDeadLettered(t *testing.T) {
+	bad := kafka.Message{Key: []byte("junk"), Value: []byte("{")}
+
+	// A dead-letter topic that recovers: the message is written and then
+	// committed.
+	reader := newFakeReader(bad)
+	dlq := &fakeWriter{fails: 3}
+	stop := runConsumer(reader, dlq, db.NewMemoryStore())
+	waitFor(t, "the commit", func() bool { return len(reader.committed()) == 1 })
+	stop()
+	if dlq.attempts != 4 || len(dlq.writes) != 1 {
+		t.Errorf("%d write attempts, %d written; want 4 and 1", dlq.attempts, len(dlq.writes))
+	}
+
+	// A dead-letter topic that stays down: the consumer keeps retrying and
+	// never commits, so the message is fetched again after a restart.
+	reader = newFakeReader(bad)
+	dlq = &fakeWriter{fails: 1 << 30}
+	stop = runConsumer(reader, dlq, db.NewMemoryStore())
+	waitFor(t, "retries", func() bool { return dlq.attempted() >= 3 })
+	stop()
+	if commits := reader.committed(); len(commits) != 0 {
+		t.Errorf("committed %v while the dead-letter topic was down", commits)
+	}
+}
@@ -0,0 +1,60 @@
+package kafka
+
+import (
+	"context"
+	"encoding/json"
+	"log"
+	"order-service/internal/validation"
+	"strconv"
+	"time"
+
+	"github.com/segmentio/kafka-go"
+)
+
+// deadLetterWithRetry writes msg to the dead-letter topic, retrying with
+// backoff between minBackoff and maxBackoff until the write succeeds or ctx
+// is done.
+func deadLetterWithRetry(ctx context.Context, dlq messageWriter, msg kafka.Message, reason string, report *validation.ValidationError, minBackoff, maxBackoff time.Duration) error {
+	backoff := minBackoff
+	for {
+		err := writeDeadLetter(ctx, dlq, msg, reason, report)
+		if err == nil {
+			return nil
+		}
+		if ctx.Err() != nil {
+			return ctx.Err()
+		}
+		log.Printf("Failed to dead-letter message at offset %d, retrying in %s: %v", msg.Offset, backoff, err)
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-time.After(backoff):
+		}
+		backoff = min(2*backoff, maxBackoff)
+	}
+}
+
+// writeDeadLetter forwards a rejected message unchanged to the dead-letter
+// topic and attaches the validation report, so producers can see why it was
+// rejected.
+func writeDeadLetter(ctx context.Context, dlq messageWriter, msg kafka.Message, reason string, report *validation.ValidationError) error {
+	reportJSON, err := json.Marshal(report)
+	if err != nil {
+		return err
+	}
+
+	headers := append([]kafka.Header{}, msg.Headers...)
+	headers = append(headers,
+		kafka.Header{Key: "dlq-reason", Value: []byte(reason)},
+		kafka.Header{Key: "dlq-source-topic", Value: []byte(msg.Topic)},
+		kafka.Header{Key: "dlq-source-partition", Value: []byte(strconv.Itoa(msg.Partition))},
+		kafka.Header{Key: "dlq-source-offset", Value: []byte(strconv.FormatInt(msg.Offset, 10))},
+		kafka.Header{Key: "validation-report", Value: reportJSON},
+	)
+
+	return dlq.WriteMessages(ctx, kafka.Message{
+		Key:     msg.Key,
+		Value:   msg.Value,
+		Headers: headers,
+	})
+}
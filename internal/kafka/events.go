@@ -0,0 +1,226 @@
+package kafka
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"order-service/config"
+	"order-service/internal/cache"
+	"order-service/internal/db"
+	"order-service/internal/stream"
+	"order-service/internal/validation"
+	"time"
+
+	"github.com/lib/pq"
+	"github.com/segmentio/kafka-go"
+)
+
+const (
+	EventTypeStatus   = "status"
+	EventTypeDelivery = "delivery"
+)
+
+// EventMessage is a small change to an existing order published on the
+// order-events topic. Producers key messages by order_uid so every change of
+// an order lands on one partition and is applied in order.
+type EventMessage struct {
+	Type     string            `json:"type"`
+	OrderUID string            `json:"order_uid"`
+	Status   db.OrderStatus    `json:"status,omitempty"`
+	Reason   string            `json:"reason,omitempty"`
+	Delivery *db.DeliveryPatch `json:"delivery,omitempty"`
+}
+
+// EventConsumer applies order events one message at a time and commits only
+// after an event is applied or dead-lettered. A failing store or dead-letter
+// topic blocks the partition and is retried, so later events never overtake
+// earlier ones and no event is lost.
+type EventConsumer struct {
+	reader           messageReader
+	dlq              messageWriter
+	db               db.Store
+	cache            *cache.Cache
+	hub              *stream.Hub
+	backoff          time.Duration
+	notFoundAttempts int
+	ctx              context.Context
+	cancel           context.CancelFunc
+}
+
+func NewEventConsumer(cfg *config.Config, db db.Store, cache *cache.Cache, hub *stream.Hub) *EventConsumer {
+	ctx, cancel := context.WithCancel(context.Background())
+	return &EventConsumer{
+		reader: kafka.NewReader(kafka.ReaderConfig{
+			Brokers:        cfg.KafkaBrokers,
+			Topic:          cfg.KafkaEventsTopic,
+			GroupID:        "order-service-events",
+			MinBytes:       1,
+			MaxBytes:       1e6,
+			CommitInterval: time.Second,
+		}),
+		dlq: &kafka.Writer{
+			Addr:         kafka.TCP(cfg.KafkaBrokers...),
+			Topic:        cfg.KafkaDLQTopic,
+			Balancer:     &kafka.Hash{},
+			BatchTimeout: 10 * time.Millisecond,
+		},
+		db:               db,
+		cache:            cache,
+		hub:              hub,
+		backoff:          cfg.KafkaEventsRetryBackoff,
+		notFoundAttempts: cfg.KafkaEventsNotFoundRetries,
+		ctx:              ctx,
+		cancel:           cancel,
+	}
+}
+
+func (c *EventConsumer) Start() {
+	go c.consume()
+}
+
+func (c *EventConsumer) consume() {
+	for {
+		msg, err := c.reader.FetchMessage(c.ctx)
+		if err != nil {
+			if c.ctx.Err() != nil {
+				return
+			}
+			log.Printf("Failed to fetch order event: %v", err)
+			time.Sleep(time.Second)
+			continue
+		}
+
+		event, err := parseEvent(msg)
+		if err == nil {
+			err = c.applyWithRetry(event)
+		}
+		if c.ctx.Err() != nil {
+			return
+		}
+		if err != nil {
+			log.Printf("Rejected order event at offset %d: %v", msg.Offset, err)
+			report := validation.AsValidationError(err, "event")
+			if err := deadLetterWithRetry(c.ctx, c.dlq, msg, "event", report, c.backoff, retryMax); err != nil {
+				// Closed before the event reached the dead-letter topic; it
+				// stays uncommitted.
+				return
+			}
+		}
+
+		if err := c.reader.CommitMessages(c.ctx, msg); err != nil {
+			log.Printf("Failed to commit order event: %v", err)
+		}
+	}
+}
+
+func parseEvent(msg kafka.Message) (*EventMessage, error) {
+	var event EventMessage
+	if err := json.Unmarshal(msg.Value, &event); err != nil {
+		return nil, fmt.Errorf("failed to decode order event: %w", err)
+	}
+	if event.OrderUID == "" {
+		event.OrderUID = string(msg.Key)
+	}
+	if event.OrderUID == "" {
+		return nil, errors.New("order event has no order_uid")
+	}
+	if len(msg.Key) > 0 && string(msg.Key) != event.OrderUID {
+		return nil, fmt.Errorf("order event key %q does not match order_uid %q", msg.Key, event.OrderUID)
+	}
+	return &event, nil
+}
+
+// applyWithRetry retries store failures until they succeed or the consumer is
+// closed. An unknown order is retried a limited number of times because the
+// order itself may still be on its way through the orders topic.
+func (c *EventConsumer) applyWithRetry(event *EventMessage) error {
+	notFound := 0
+	for {
+		err := c.apply(event)
+		switch {
+		case err == nil:
+			return nil
+		case errors.Is(err, db.ErrOrderNotFound):
+			notFound++
+			if notFound >= c.notFoundAttempts {
+				return err
+			}
+		case !isRetryable(err):
+			return err
+		default:
+			log.Printf("Failed to apply %s event for order %s, retrying: %v", event.Type, event.OrderUID, err)
+		}
+
+		select {
+		case <-c.ctx.Done():
+			return c.ctx.Err()
+		case <-time.After(c.backoff):
+		}
+	}
+}
+
+// isRetryable reports whether applying an event again may succeed. Rejected
+// transitions, invalid events, unknown orders, undecodable data and the data
+// and constraint errors Postgres raises for the values themselves (classes 22
+// and 23) fail the same way every time.
+func isRetryable(err error) bool {
+	var terr *db.TransitionError
+	var verr *validation.ValidationError
+	var syntaxErr *json.SyntaxError
+	var typeErr *json.UnmarshalTypeError
+	var pqErr *pq.Error
+	switch {
+	case errors.As(err, &terr), errors.As(err, &verr), errors.As(err, &syntaxErr), errors.As(err, &typeErr),
+		errors.Is(err, db.ErrInvalidStatus), errors.Is(err, db.ErrOrderNotFound):
+		return false
+	case errors.As(err, &pqErr):
+		class := pqErr.Code.Class()
+		return class != "22" && class != "23"
+	}
+	return true
+}
+
+func (c *EventConsumer) apply(event *EventMessage) error {
+	switch event.Type {
+	case EventTypeStatus:
+		change, err := c.db.UpdateOrderStatusContext(c.ctx, event.OrderUID, event.Status, event.Reason)
+		if err != nil {
+			return err
+		}
+		c.cache.Delete(event.OrderUID)
+		if change.From != change.To {
+			order, _ := c.db.GetOrderByUIDContext(c.ctx, event.OrderUID)
+			c.hub.Publish(stream.StatusChanged(change, order))
+		}
+		log.Printf("Order %s status %s -> %s", event.OrderUID, change.From, change.To)
+	case EventTypeDelivery:
+		if event.Delivery == nil {
+			return &validation.ValidationError{Violations: []validation.Violation{{
+				Path: "delivery", Rule: "required", Message: "is required",
+			}}}
+		}
+		if err := validation.ValidateDeliveryPatch(event.Delivery); err != nil {
+			return err
+		}
+		order, err := c.db.UpdateDeliveryContext(c.ctx, event.OrderUID, *event.Delivery)
+		if err != nil {
+			return err
+		}
+		c.cache.Delete(event.OrderUID)
+		c.hub.Publish(stream.OrderUpdated(order))
+		log.Printf("Order %s delivery updated", event.OrderUID)
+	default:
+		return &validation.ValidationError{Violations: []validation.Violation{{
+			Path: "type", Rule: "oneof", Value: event.Type, Message: "must be one of: status, delivery",
+		}}}
+	}
+	return nil
+}
+
+func (c *EventConsumer) Close() error {
+	c.cancel()
+	c.dlq.Close()
+	return c.reader.Close()
+}
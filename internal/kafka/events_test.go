@@ -0,0 +1,259 @@
+package kafka
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"order-service/internal/cache"
+	"order-service/internal/db"
+	"order-service/internal/stream"
+	"order-service/internal/validation"
+	"os"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/lib/pq"
+	"github.com/segmentio/kafka-go"
+)
+
+func newTestEventConsumer(t *testing.T, reader messageReader, dlq messageWriter) (*EventConsumer, *db.MemoryStore) {
+	t.Helper()
+	data, err := os.ReadFile("../../model.json")
+	if err != nil {
+		t.Fatal(err)
+	}
+	var order db.Order
+	if err := json.Unmarshal(data, &order); err != nil {
+		t.Fatal(err)
+	}
+	order.OrderUID = "o1"
+	store := db.NewMemoryStore()
+	if err := store.SaveOrderContext(context.Background(), &order); err != nil {
+		t.Fatal(err)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	t.Cleanup(cancel)
+	return &EventConsumer{
+		reader:           reader,
+		dlq:              dlq,
+		db:               store,
+		cache:            cache.NewCache(),
+		hub:              stream.NewHub(16, 16),
+		backoff:          time.Millisecond,
+		notFoundAttempts: 2,
+		ctx:              ctx,
+		cancel:           cancel,
+	}, store
+}
+
+func eventMessage(key, value string) kafka.Message {
+	return kafka.Message{Key: []byte(key), Value: []byte(value)}
+}
+
+func TestParseEvent(t *testing.T) {
+	tests := []struct {
+		name     string
+		msg      kafka.Message
+		wantUID  string
+		wantType string
+		wantErr  string
+	}{
+		{"status", eventMessage("o1", `{"type": "status", "order_uid": "o1", "status": "paid"}`), "o1", EventTypeStatus, ""},
+		{"uid from key", eventMessage("o1", `{"type": "delivery", "delivery": {"city": "Kazan"}}`), "o1", EventTypeDelivery, ""},
+		{"no key", eventMessage("", `{"type": "status", "order_uid": "o1"}`), "o1", EventTypeStatus, ""},
+		{"no uid", eventMessage("", `{"type": "status"}`), "", "", "has no order_uid"},
+		{"key mismatch", eventMessage("o2", `{"type": "status", "order_uid": "o1"}`), "", "", `key "o2" does not match order_uid "o1"`},
+		{"not json", eventMessage("o1", `{`), "", "", "failed to decode order event"},
+		{"wrong type", eventMessage("o1", `{"order_uid": 1}`), "", "", "failed to decode order event"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			event, err := parseEvent(tt.msg)
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Errorf("err = %v, want one containing %q", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("parseEvent: %v", err)
+			}
+			if event.OrderUID != tt.wantUID || event.Type != tt.wantType {
+				t.Errorf("event = %+v, want %s for %s", event, tt.wantType, tt.wantUID)
+			}
+		})
+	}
+}
+
+func TestIsRetryable(t *testing.T) {
+	var syntaxErr *json.SyntaxError
+	if !errors.As(json.Unmarshal([]byte("{x"), &struct{}{}), &syntaxErr) {
+		t.Fatal("no json syntax error to classify")
+	}
+
+	tests := []struct {
+		name string
+		err  error
+		want bool
+	}{
+		{"transition", &db.TransitionError{From: db.StatusShipped, To: db.StatusPaid}, false},
+		{"validation", &validation.ValidationError{}, false},
+		{"invalid status", fmt.Errorf("update: %w", db.ErrInvalidStatus), false},
+		{"unknown order", fmt.Errorf("shard 1: %w", db.ErrOrderNotFound), false},
+		{"value too long", fmt.Errorf("failed to update delivery: %w", &pq.Error{Code: "22001"}), false},
+		{"unique violation", &pq.Error{Code: "23505"}, false},
+		{"check violation", &pq.Error{Code: "23514"}, false},
+		{"json syntax", syntaxErr, false},
+		{"json type", &json.UnmarshalTypeError{Value: "number"}, false},
+		{"connection failure", &pq.Error{Code: "08006"}, true},
+		{"serialization failure", &pq.Error{Code: "40001"}, true},
+		{"shutdown", &pq.Error{Code: "57P01"}, true},
+		{"timeout", context.DeadlineExceeded, true},
+		{"other", errors.New("connection reset by peer"), true},
+	}
+	for _, tt := range tests {
+		if got := isRetryable(tt.err); got != tt.want {
+			t.Errorf("%s: isRetryable(%v) = %t, want %t", tt.name, tt.err, got, tt.want)
+		}
+	}
+}
+
+func TestEventConsumerApply(t *testing.T) {
+	c, store := newTestEventConsumer(t, nil, nil)
+	ctx := context.Background()
+	sub, _, _ := c.hub.Subscribe(0, nil)
+	next := func() stream.Event {
+		t.Helper()
+		select {
+		case e := <-sub.C:
+			return e
+		default:
+			t.Fatal("no event published")
+			return stream.Event{}
+		}
+	}
+
+	// model.json's item status 202 puts the order in assembling.
+	if err := c.apply(&EventMessage{Type: EventTypeStatus, OrderUID: "o1", Status: db.StatusShipped, Reason: "picked up"}); err != nil {
+		t.Fatalf("status event: %v", err)
+	}
+	if e := next(); e.Type != stream.EventOrderStatusChanged || e.Status.To != db.StatusShipped || e.Order == nil {
+		t.Errorf("status event published %+v", e)
+	}
+	if o, _ := store.GetOrderByUIDContext(ctx, "o1"); o.Status != db.StatusShipped {
+		t.Errorf("status = %s, want shipped", o.Status)
+	}
+	// A repeated status changes nothing and publishes nothing.
+	if err := c.apply(&EventMessage{Type: EventTypeStatus, OrderUID: "o1", Status: db.StatusShipped}); err != nil {
+		t.Fatalf("repeated status event: %v", err)
+	}
+	if len(sub.C) != 0 {
+		t.Errorf("repeated status published %+v", <-sub.C)
+	}
+
+	city := "Kazan"
+	if err := c.apply(&EventMessage{Type: EventTypeDelivery, OrderUID: "o1", Delivery: &db.DeliveryPatch{City: &city}}); err != nil {
+		t.Fatalf("delivery event: %v", err)
+	}
+	if e := next(); e.Type != stream.EventOrderUpdated || e.Order.Delivery.City != "Kazan" {
+		t.Errorf("delivery event published %+v", e)
+	}
+	if o, _ := store.GetOrderByUIDContext(ctx, "o1"); o.Delivery.City != "Kazan" || o.Delivery.Name != "Test Testov" {
+		t.Errorf("delivery = %+v, want only the city changed", o.Delivery)
+	}
+
+	email := "nope"
+	var terr *db.TransitionError
+	var verr *validation.ValidationError
+	rejected := []struct {
+		name  string
+		event EventMessage
+		check func(error) bool
+	}{
+		{"backwards transition", EventMessage{Type: EventTypeStatus, OrderUID: "o1", Status: db.StatusPaid},
+			func(err error) bool { return errors.As(err, &terr) }},
+		{"unknown status", EventMessage{Type: EventTypeStatus, OrderUID: "o1", Status: "lost"},
+			func(err error) bool { return errors.Is(err, db.ErrInvalidStatus) }},
+		{"unknown order", EventMessage{Type: EventTypeStatus, OrderUID: "missing", Status: db.StatusPaid},
+			func(err error) bool { return errors.Is(err, db.ErrOrderNotFound) }},
+		{"no delivery", EventMessage{Type: EventTypeDelivery, OrderUID: "o1"},
+			func(err error) bool { return errors.As(err, &verr) && verr.Violations[0].Path == "delivery" }},
+		{"invalid delivery", EventMessage{Type: EventTypeDelivery, OrderUID: "o1", Delivery: &db.DeliveryPatch{Email: &email}},
+			func(err error) bool { return errors.As(err, &verr) }},
+		{"unknown type", EventMessage{Type: "refund", OrderUID: "o1"},
+			func(err error) bool { return errors.As(err, &verr) && verr.Violations[0].Path == "type" }},
+	}
+	for _, tt := range rejected {
+		err := c.apply(&tt.event)
+		if !tt.check(err) {
+			t.Errorf("%s: err = %v", tt.name, err)
+		}
+		if isRetryable(err) {
+			t.Errorf("%s: %v is retryable", tt.name, err)
+		}
+	}
+	if len(sub.C) != 0 {
+		t.Errorf("rejected events published %+v", <-sub.C)
+	}
+}
+
+// runEventConsumer consumes until stop is called; stop returns once the
+// consumer has returned.
+func runEventConsumer(c *EventConsumer) (stop func()) {
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		c.consume()
+	}()
+	return func() {
+		c.cancel()
+		<-done
+	}
+}
+
+func TestEventConsumerDeadLettersRejectedEvents(t *testing.T) {
+	reader := newFakeReader(
+		eventMessage("o1", `{`),
+		eventMessage("missing", `{"type": "status", "status": "paid"}`),
+		eventMessage("o1", `{"type": "status", "status": "shipped"}`),
+	)
+	dlq := &fakeWriter{fails: 2}
+	c, store := newTestEventConsumer(t, reader, dlq)
+	stop := runEventConsumer(c)
+	waitFor(t, "three commits", func() bool { return len(reader.committed()) == 3 })
+	stop()
+
+	if fmt.Sprint(reader.committed()) != "[0 1 2]" {
+		t.Errorf("committed %v, want every offset in order", reader.committed())
+	}
+	if len(dlq.writes) != 2 {
+		t.Fatalf("dead-lettered %d events, want 2", len(dlq.writes))
+	}
+	for i, offset := range []string{"0", "1"} {
+		headers := map[string]string{}
+		for _, h := range dlq.writes[i][0].Headers {
+			headers[h.Key] = string(h.Value)
+		}
+		if headers["dlq-reason"] != "event" || headers["dlq-source-offset"] != offset || headers["validation-report"] == "" {
+			t.Errorf("dead letter %d headers = %v", i, headers)
+		}
+	}
+	if o, _ := store.GetOrderByUIDContext(context.Background(), "o1"); o.Status != db.StatusShipped {
+		t.Errorf("status = %s, want the valid event applied", o.Status)
+	}
+}
+
+func TestEventConsumerKeepsEventWhileDeadLetterTopicIsDown(t *testing.T) {
+	reader := newFakeReader(eventMessage("o1", `{`))
+	dlq := &fakeWriter{fails: 1 << 30}
+	c, _ := newTestEventConsumer(t, reader, dlq)
+	stop := runEventConsumer(c)
+	waitFor(t, "retries", func() bool { return dlq.attempted() >= 3 })
+	stop()
+	if commits := reader.committed(); len(commits) != 0 {
+		t.Errorf("committed %v while the dead-letter topic was down", commits)
+	}
+}
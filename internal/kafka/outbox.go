@@ -0,0 +1,125 @@
+package kafka
+
+import (
+	"context"
+	"log"
+	"order-service/config"
+	"order-service/internal/db"
+	"strconv"
+	"time"
+
+	"github.com/segmentio/kafka-go"
+)
+
+// outboxStore is the part of *db.Database the relay uses.
+type outboxStore interface {
+	ProcessOutbox(ctx context.Context, limit int, publish func([]db.OutboxEvent) error) (int, error)
+	PurgeOutbox(ctx context.Context, publishedBefore time.Time) (int64, error)
+}
+
+type messageWriter interface {
+	WriteMessages(ctx context.Context, msgs ...kafka.Message) error
+	Close() error
+}
+
+type OutboxRelay struct {
+	writer       messageWriter
+	db           outboxStore
+	pollInterval time.Duration
+	batchSize    int
+	retention    time.Duration
+	cancel       context.CancelFunc
+	done         chan struct{}
+}
+
+func NewOutboxRelay(cfg *config.Config, db *db.Database) *OutboxRelay {
+	return &OutboxRelay{
+		writer: &kafka.Writer{
+			Addr:         kafka.TCP(cfg.KafkaBrokers...),
+			Topic:        cfg.OutboxTopic,
+			Balancer:     &kafka.Hash{},
+			RequiredAcks: kafka.RequireAll,
+			BatchTimeout: 10 * time.Millisecond,
+		},
+		db:           db,
+		pollInterval: cfg.OutboxPollInterval,
+		batchSize:    cfg.OutboxBatchSize,
+		retention:    cfg.OutboxRetention,
+		done:         make(chan struct{}),
+	}
+}
+
+func (r *OutboxRelay) Start() {
+	ctx, cancel := context.WithCancel(context.Background())
+	r.cancel = cancel
+	go r.run(ctx)
+}
+
+func (r *OutboxRelay) run(ctx context.Context) {
+	defer close(r.done)
+
+	ticker := time.NewTicker(r.pollInterval)
+	defer ticker.Stop()
+	cleanup := time.NewTicker(time.Hour)
+	defer cleanup.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			r.drain(ctx)
+		case <-cleanup.C:
+			n, err := r.db.PurgeOutbox(ctx, time.Now().Add(-r.retention))
+			if err != nil {
+				log.Printf("Failed to purge outbox: %v", err)
+			} else if n > 0 {
+				log.Printf("Purged %d published outbox events", n)
+			}
+		}
+	}
+}
+
+func (r *OutboxRelay) drain(ctx context.Context) {
+	for {
+		n, err := r.db.ProcessOutbox(ctx, r.batchSize, func(events []db.OutboxEvent) error {
+			return r.publish(ctx, events)
+		})
+		if err != nil {
+			if ctx.Err() == nil {
+				log.Printf("Outbox relay failed: %v", err)
+			}
+			return
+		}
+		if n > 0 {
+			log.Printf("Published %d outbox events", n)
+		}
+		if n < r.batchSize {
+			return
+		}
+	}
+}
+
+func (r *OutboxRelay) publish(ctx context.Context, events []db.OutboxEvent) error {
+	msgs := make([]kafka.Message, len(events))
+	for i, e := range events {
+		msgs[i] = kafka.Message{
+			Key:   []byte(e.OrderUID),
+			Value: e.Payload,
+			Headers: []kafka.Header{
+				{Key: "event-id", Value: []byte(strconv.FormatInt(e.ID, 10))},
+				{Key: "event-type", Value: []byte(e.EventType)},
+			},
+			Time: e.CreatedAt,
+		}
+	}
+	return r.writer.WriteMessages(ctx, msgs...)
+}
+
+func (r *OutboxRelay) Close() error {
+	if r.cancel != nil {
+		r.cancel()
+		<-r.done
+	}
+	return r.writer.Close()
+}
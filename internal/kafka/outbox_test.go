@@ -0,0 +1,177 @@
+package kafka
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"order-service/internal/db"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/segmentio/kafka-go"
+)
+
+// fakeOutbox behaves like the outbox table: ProcessOutbox hands out the
+// oldest unpublished events and marks them published only when publish
+// succeeds.
+type fakeOutbox struct {
+	events    []db.OutboxEvent
+	published map[int64]bool
+	calls     int
+}
+
+func newFakeOutbox(n int) *fakeOutbox {
+	o := &fakeOutbox{published: map[int64]bool{}}
+	for i := 1; i <= n; i++ {
+		o.events = append(o.events, db.OutboxEvent{
+			ID:        int64(i),
+			OrderUID:  fmt.Sprintf("order-%d", i%2),
+			EventType: db.EventOrderAccepted,
+			Payload:   []byte(fmt.Sprintf(`{"n":%d}`, i)),
+			CreatedAt: time.Date(2024, 1, 1, 0, 0, i, 0, time.UTC),
+		})
+	}
+	return o
+}
+
+func (o *fakeOutbox) ProcessOutbox(ctx context.Context, limit int, publish func([]db.OutboxEvent) error) (int, error) {
+	o.calls++
+	var batch []db.OutboxEvent
+	for _, e := range o.events {
+		if !o.published[e.ID] && len(batch) < limit {
+			batch = append(batch, e)
+		}
+	}
+	if len(batch) == 0 {
+		return 0, nil
+	}
+	if err := publish(batch); err != nil {
+		return 0, err
+	}
+	for _, e := range batch {
+		o.published[e.ID] = true
+	}
+	return len(batch), nil
+}
+
+func (o *fakeOutbox) PurgeOutbox(ctx context.Context, publishedBefore time.Time) (int64, error) {
+	return 0, nil
+}
+
+// fakeWriter records every successful write. It fails with err while err is
+// set, or for the first fails writes.
+type fakeWriter struct {
+	mu       sync.Mutex
+	writes   [][]kafka.Message
+	err      error
+	fails    int
+	attempts int
+}
+
+func (w *fakeWriter) WriteMessages(ctx context.Context, msgs ...kafka.Message) error {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	w.attempts++
+	if w.err != nil {
+		return w.err
+	}
+	if w.fails > 0 {
+		w.fails--
+		return errors.New("broker unavailable")
+	}
+	w.writes = append(w.writes, msgs)
+	return nil
+}
+
+func (w *fakeWriter) attempted() int {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	return w.attempts
+}
+
+func (w *fakeWriter) Close() error { return nil }
+
+func newTestRelay(store outboxStore, writer messageWriter, batchSize int) *OutboxRelay {
+	return &OutboxRelay{writer: writer, db: store, batchSize: batchSize, done: make(chan struct{})}
+}
+
+func TestOutboxRelayDrainPublishesEveryBatch(t *testing.T) {
+	store := newFakeOutbox(5)
+	writer := &fakeWriter{}
+	newTestRelay(store, writer, 2).drain(context.Background())
+
+	if len(store.published) != 5 {
+		t.Fatalf("published %d events, want 5", len(store.published))
+	}
+	var sizes []int
+	for _, w := range writer.writes {
+		sizes = append(sizes, len(w))
+	}
+	if fmt.Sprint(sizes) != "[2 2 1]" {
+		t.Errorf("batch sizes = %v, want [2 2 1]", sizes)
+	}
+	// The short last batch ends the drain without another empty query.
+	if store.calls != 3 {
+		t.Errorf("ProcessOutbox called %d times, want 3", store.calls)
+	}
+}
+
+func TestOutboxRelayDrainStopsOnEmptyOutbox(t *testing.T) {
+	store := newFakeOutbox(4)
+	writer := &fakeWriter{}
+	newTestRelay(store, writer, 2).drain(context.Background())
+
+	if len(store.published) != 4 {
+		t.Fatalf("published %d events, want 4", len(store.published))
+	}
+	if store.calls != 3 {
+		t.Errorf("ProcessOutbox called %d times, want 3", store.calls)
+	}
+}
+
+func TestOutboxRelayMessages(t *testing.T) {
+	store := newFakeOutbox(1)
+	writer := &fakeWriter{}
+	newTestRelay(store, writer, 10).drain(context.Background())
+
+	if len(writer.writes) != 1 || len(writer.writes[0]) != 1 {
+		t.Fatalf("writes = %v, want one message", writer.writes)
+	}
+	e, msg := store.events[0], writer.writes[0][0]
+	if string(msg.Key) != e.OrderUID {
+		t.Errorf("key = %q, want %q", msg.Key, e.OrderUID)
+	}
+	if string(msg.Value) != string(e.Payload) {
+		t.Errorf("value = %s, want %s", msg.Value, e.Payload)
+	}
+	if !msg.Time.Equal(e.CreatedAt) {
+		t.Errorf("time = %v, want %v", msg.Time, e.CreatedAt)
+	}
+	headers := map[string]string{}
+	for _, h := range msg.Headers {
+		headers[h.Key] = string(h.Value)
+	}
+	if headers["event-id"] != "1" || headers["event-type"] != db.EventOrderAccepted {
+		t.Errorf("headers = %v", headers)
+	}
+}
+
+func TestOutboxRelayWriteFailureLeavesEventsUnpublished(t *testing.T) {
+	store := newFakeOutbox(3)
+	writer := &fakeWriter{err: errors.New("broker unavailable")}
+	newTestRelay(store, writer, 2).drain(context.Background())
+
+	if len(store.published) != 0 {
+		t.Errorf("published %d events after a failed write, want 0", len(store.published))
+	}
+	if store.calls != 1 {
+		t.Errorf("ProcessOutbox called %d times, want 1", store.calls)
+	}
+
+	writer.err = nil
+	newTestRelay(store, writer, 2).drain(context.Background())
+	if len(store.published) != 3 {
+		t.Errorf("published %d events on retry, want 3", len(store.published))
+	}
+}
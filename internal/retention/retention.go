@@ -0,0 +1,98 @@
+package retention
+
+import (
+	"context"
+	"log"
+	"order-service/config"
+	"order-service/internal/cache"
+	"order-service/internal/db"
+	"time"
+)
+
+type Job struct {
+	db         *db.Database
+	cache      *cache.Cache
+	maxAge     time.Duration
+	interval   time.Duration
+	batchSize  int
+	mode       string
+	archiveDir string
+	cancel     context.CancelFunc
+	done       chan struct{}
+}
+
+func NewJob(cfg *config.Config, db *db.Database, cache *cache.Cache) *Job {
+	return &Job{
+		db:         db,
+		cache:      cache,
+		maxAge:     cfg.RetentionMaxAge,
+		interval:   cfg.RetentionInterval,
+		batchSize:  cfg.RetentionBatchSize,
+		mode:       cfg.RetentionMode,
+		archiveDir: cfg.RetentionArchiveDir,
+		done:       make(chan struct{}),
+	}
+}
+
+func (j *Job) Start() {
+	if j.maxAge <= 0 {
+		log.Println("Retention job disabled")
+		return
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	j.cancel = cancel
+	go j.run(ctx)
+}
+
+func (j *Job) run(ctx context.Context) {
+	defer close(j.done)
+
+	ticker := time.NewTicker(j.interval)
+	defer ticker.Stop()
+
+	for {
+		j.RunOnce(ctx)
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// RunOnce archives expired orders chunk by chunk until none are left.
+func (j *Job) RunOnce(ctx context.Context) {
+	before := time.Now().Add(-j.maxAge)
+	total := 0
+	for ctx.Err() == nil {
+		var uids []string
+		var err error
+		if j.mode == "file" {
+			uids, err = j.db.ArchiveOrdersToFile(ctx, before, j.batchSize, j.archiveDir)
+		} else {
+			uids, err = j.db.ArchiveOrdersToTables(ctx, before, j.batchSize)
+		}
+		if err != nil {
+			log.Printf("Retention job failed: %v", err)
+			break
+		}
+		for _, uid := range uids {
+			j.cache.Delete(uid)
+		}
+		total += len(uids)
+		if len(uids) < j.batchSize {
+			break
+		}
+	}
+	if total > 0 {
+		log.Printf("Archived %d orders created before %s", total, before.Format(time.RFC3339))
+	}
+}
+
+func (j *Job) Close() {
+	if j.cancel == nil {
+		return
+	}
+	j.cancel()
+	<-j.done
+}
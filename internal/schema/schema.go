@@ -0,0 +1,146 @@
+package schema
+
+import (
+	"order-service/internal/db"
+	"order-service/internal/validation"
+	"reflect"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	Draft = "https://json-schema.org/draft/2020-12/schema"
+	ID    = "urn:order-service:schema:order"
+)
+
+type Schema = map[string]any
+
+var timeType = reflect.TypeOf(time.Time{})
+
+// Order builds the JSON Schema of the order message from the db.Order struct
+// and its validate tags. Nested structs are emitted once under $defs.
+func Order() Schema {
+	defs := Schema{}
+	root := objectSchema(reflect.TypeOf(db.Order{}), defs)
+	root["$schema"] = Draft
+	root["$id"] = ID
+	root["title"] = "Order"
+	root["x-schema-version"] = CurrentVersion
+	root["$defs"] = defs
+	return root
+}
+
+func objectSchema(t reflect.Type, defs Schema) Schema {
+	props := Schema{}
+	var required []string
+
+	for i := 0; i < t.NumField(); i++ {
+		f := t.Field(i)
+		name := strings.SplitN(f.Tag.Get("json"), ",", 2)[0]
+		if name == "" || name == "-" || !f.IsExported() {
+			continue
+		}
+
+		s := typeSchema(f.Type, defs)
+		if applyTags(s, f.Type, f.Tag.Get("validate")) {
+			required = append(required, name)
+		}
+		props[name] = s
+	}
+
+	s := Schema{
+		"type":                 "object",
+		"properties":           props,
+		"additionalProperties": false,
+	}
+	if len(required) > 0 {
+		s["required"] = required
+	}
+	return s
+}
+
+func typeSchema(t reflect.Type, defs Schema) Schema {
+	switch {
+	case t == timeType:
+		return Schema{
+			"type":        "string",
+			"format":      "date-time",
+			"description": "RFC 3339 date-time; a timestamp without zone is read as UTC",
+		}
+	case t.Kind() == reflect.Struct:
+		if _, ok := defs[t.Name()]; !ok {
+			defs[t.Name()] = nil
+			defs[t.Name()] = objectSchema(t, defs)
+		}
+		return Schema{"$ref": "#/$defs/" + t.Name()}
+	case t.Kind() == reflect.Slice:
+		return Schema{"type": "array", "items": typeSchema(t.Elem(), defs)}
+	case t.Kind() == reflect.String:
+		return Schema{"type": "string"}
+	case t.Kind() >= reflect.Int && t.Kind() <= reflect.Uint64:
+		return Schema{"type": "integer"}
+	case t.Kind() == reflect.Float32 || t.Kind() == reflect.Float64:
+		return Schema{"type": "number"}
+	case t.Kind() == reflect.Bool:
+		return Schema{"type": "boolean"}
+	}
+	return Schema{}
+}
+
+// applyTags translates validate tags into schema keywords and reports whether
+// the field is required.
+func applyTags(s Schema, t reflect.Type, tags string) bool {
+	required := false
+	for _, tag := range strings.Split(tags, ",") {
+		name, param, _ := strings.Cut(tag, "=")
+		switch name {
+		case "dive":
+			return required
+		case "required":
+			required = true
+			if t.Kind() == reflect.String && t != timeType {
+				if _, ok := s["minLength"]; !ok {
+					s["minLength"] = 1
+				}
+			}
+		case "min", "max":
+			n, err := strconv.Atoi(param)
+			if err != nil {
+				continue
+			}
+			s[boundKeyword(t, name)] = n
+		case "oneof":
+			s["enum"] = strings.Fields(param)
+		case "alpha":
+			s["pattern"] = `^[a-zA-Z]+$`
+		case "alphanum":
+			s["pattern"] = `^[a-zA-Z0-9]+$`
+		case "numeric":
+			s["pattern"] = `^[-+]?[0-9]+(?:\.[0-9]+)?$`
+		case "email":
+			s["format"] = "email"
+		case "e164":
+			s["pattern"] = `^\+[1-9][0-9]{1,14}$`
+		case "iso4217":
+			s["enum"] = validation.CurrencyCodes()
+		case "bcp47":
+			s["pattern"] = validation.BCP47Pattern
+			s["description"] = "BCP 47 language tag; allowed: " + strings.Join(validation.AllowedLocales(), ", ")
+		}
+	}
+	return required
+}
+
+func boundKeyword(t reflect.Type, bound string) string {
+	switch t.Kind() {
+	case reflect.String:
+		return bound + "Length"
+	case reflect.Slice:
+		return bound + "Items"
+	}
+	if bound == "min" {
+		return "minimum"
+	}
+	return "maximum"
+}
@@ -0,0 +1,126 @@
+package schema
+
+import (
+	"encoding/json"
+	"os"
+	"slices"
+	"testing"
+)
+
+func modelJSON(t *testing.T) map[string]any {
+	t.Helper()
+	data, err := os.ReadFile("../../model.json")
+	if err != nil {
+		t.Fatal(err)
+	}
+	var doc map[string]any
+	if err := json.Unmarshal(data, &doc); err != nil {
+		t.Fatal(err)
+	}
+	return doc
+}
+
+func TestOrderSchema(t *testing.T) {
+	s := Order()
+	if s["$id"] != ID || s["$schema"] != Draft || s["x-schema-version"] != CurrentVersion {
+		t.Errorf("header = %v %v %v", s["$id"], s["$schema"], s["x-schema-version"])
+	}
+	required, _ := s["required"].([]string)
+	for _, name := range []string{"order_uid", "delivery", "payment", "items", "date_created"} {
+		if !slices.Contains(required, name) {
+			t.Errorf("%s is not required", name)
+		}
+	}
+	if slices.Contains(required, "status") || slices.Contains(required, "internal_signature") {
+		t.Errorf("optional fields are required: %v", required)
+	}
+	defs, _ := s["$defs"].(Schema)
+	for _, name := range []string{"Delivery", "Payment", "Item"} {
+		if _, ok := defs[name].(Schema); !ok {
+			t.Errorf("$defs has no %s", name)
+		}
+	}
+	items := s["properties"].(Schema)["items"].(Schema)
+	if items["minItems"] != 1 || items["items"].(Schema)["$ref"] != "#/$defs/Item" {
+		t.Errorf("items = %v", items)
+	}
+
+	// The schema is served as JSON.
+	if _, err := json.Marshal(s); err != nil {
+		t.Fatalf("schema does not marshal: %v", err)
+	}
+}
+
+func TestValidate(t *testing.T) {
+	tests := []struct {
+		name   string
+		modify func(doc map[string]any)
+		want   []string
+	}{
+		{"model.json", func(doc map[string]any) {}, nil},
+		{"unknown field", func(doc map[string]any) {
+			doc["colour"] = "red"
+		}, []string{"colour schema:additionalProperties"}},
+		{"missing field", func(doc map[string]any) {
+			delete(doc, "track_number")
+		}, []string{"track_number schema:required"}},
+		{"wrong type", func(doc map[string]any) {
+			doc["sm_id"] = "99"
+		}, []string{"sm_id schema:type"}},
+		{"empty required string", func(doc map[string]any) {
+			doc["order_uid"] = ""
+		}, []string{"order_uid schema:minLength"}},
+		{"number out of range", func(doc map[string]any) {
+			doc["sm_id"] = 1001
+		}, []string{"sm_id schema:maximum"}},
+		{"pattern", func(doc map[string]any) {
+			doc["entry"] = "WB-IL"
+		}, []string{"entry schema:pattern"}},
+		{"phone", func(doc map[string]any) {
+			doc["delivery"].(map[string]any)["phone"] = "89161234567"
+		}, []string{"delivery.phone schema:pattern"}},
+		{"email", func(doc map[string]any) {
+			doc["delivery"].(map[string]any)["email"] = "test.gmail.com"
+		}, []string{"delivery.email schema:format"}},
+		{"currency", func(doc map[string]any) {
+			doc["payment"].(map[string]any)["currency"] = "EURO"
+		}, []string{"payment.currency schema:enum"}},
+		{"no items", func(doc map[string]any) {
+			doc["items"] = []any{}
+		}, []string{"items schema:minItems"}},
+		{"item field", func(doc map[string]any) {
+			item := doc["items"].([]any)[0].(map[string]any)
+			item["price"] = -1
+		}, []string{"items[0].price schema:minimum"}},
+		{"date", func(doc map[string]any) {
+			doc["date_created"] = "26.11.2021"
+		}, []string{"date_created schema:format"}},
+		{"date without zone", func(doc map[string]any) {
+			doc["date_created"] = "2021-11-26T06:22:19"
+		}, nil},
+		{"status", func(doc map[string]any) {
+			doc["status"] = "lost"
+		}, []string{"status schema:enum"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			doc := modelJSON(t)
+			tt.modify(doc)
+			payload, _ := json.Marshal(doc)
+			var got []string
+			for _, v := range Validate(Order(), payload) {
+				got = append(got, v.Path+" "+v.Rule)
+			}
+			if !slices.Equal(got, tt.want) {
+				t.Errorf("violations = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestValidateRejectsInvalidJSON(t *testing.T) {
+	violations := Validate(Order(), []byte(`{"order_uid":`))
+	if len(violations) != 1 || violations[0].Rule != "schema:json" {
+		t.Errorf("violations = %v", violations)
+	}
+}
@@ -0,0 +1,210 @@
+package schema
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"math/big"
+	"net/mail"
+	"order-service/internal/db"
+	"order-service/internal/validation"
+	"regexp"
+	"slices"
+	"sort"
+	"strings"
+	"sync"
+	"unicode/utf8"
+)
+
+var patternCache sync.Map
+
+// Validate checks a raw JSON payload against a schema built by this package.
+// It understands the keywords Order emits, which is all the order contract
+// needs; unknown fields are reported through additionalProperties.
+func Validate(s Schema, payload []byte) []validation.Violation {
+	dec := json.NewDecoder(bytes.NewReader(payload))
+	dec.UseNumber()
+	var doc any
+	if err := dec.Decode(&doc); err != nil {
+		return []validation.Violation{{Rule: "schema:json", Message: err.Error()}}
+	}
+
+	v := &validator{root: s}
+	v.check(s, doc, "")
+	return v.violations
+}
+
+type validator struct {
+	root       Schema
+	violations []validation.Violation
+}
+
+func (v *validator) fail(path, keyword string, value any, format string, args ...any) {
+	v.violations = append(v.violations, validation.Violation{
+		Path:    path,
+		Rule:    "schema:" + keyword,
+		Value:   value,
+		Message: fmt.Sprintf(format, args...),
+	})
+}
+
+func (v *validator) resolve(s Schema) Schema {
+	ref, ok := s["$ref"].(string)
+	if !ok || !strings.HasPrefix(ref, "#/$defs/") {
+		return s
+	}
+	defs, _ := v.root["$defs"].(Schema)
+	def, _ := defs[strings.TrimPrefix(ref, "#/$defs/")].(Schema)
+	return def
+}
+
+func join(path, key string) string {
+	if path == "" {
+		return key
+	}
+	return path + "." + key
+}
+
+func (v *validator) check(s Schema, doc any, path string) {
+	s = v.resolve(s)
+	if s == nil {
+		return
+	}
+
+	if !v.checkType(s, doc, path) {
+		return
+	}
+
+	if enum, ok := s["enum"].([]string); ok {
+		if str, _ := doc.(string); !slices.Contains(enum, str) {
+			v.fail(path, "enum", doc, "must be one of: %s", strings.Join(enum, ", "))
+		}
+	}
+
+	switch d := doc.(type) {
+	case map[string]any:
+		v.checkObject(s, d, path)
+	case []any:
+		if n, ok := s["minItems"].(int); ok && len(d) < n {
+			v.fail(path, "minItems", len(d), "must have at least %d items", n)
+		}
+		if n, ok := s["maxItems"].(int); ok && len(d) > n {
+			v.fail(path, "maxItems", len(d), "must have at most %d items", n)
+		}
+		if items, ok := s["items"].(Schema); ok {
+			for i, elem := range d {
+				v.check(items, elem, fmt.Sprintf("%s[%d]", path, i))
+			}
+		}
+	case string:
+		v.checkString(s, d, path)
+	case json.Number:
+		v.checkNumber(s, d, path)
+	}
+}
+
+func (v *validator) checkType(s Schema, doc any, path string) bool {
+	want, ok := s["type"].(string)
+	if !ok {
+		return true
+	}
+	var got string
+	switch d := doc.(type) {
+	case map[string]any:
+		got = "object"
+	case []any:
+		got = "array"
+	case string:
+		got = "string"
+	case bool:
+		got = "boolean"
+	case nil:
+		got = "null"
+	case json.Number:
+		got = "number"
+		if _, err := d.Int64(); err == nil {
+			got = "integer"
+		}
+	}
+	if got == want || (want == "number" && got == "integer") {
+		return true
+	}
+	v.fail(path, "type", doc, "must be of type %s, got %s", want, got)
+	return false
+}
+
+func (v *validator) checkObject(s Schema, obj map[string]any, path string) {
+	props, _ := s["properties"].(Schema)
+
+	if required, ok := s["required"].([]string); ok {
+		for _, name := range required {
+			if _, present := obj[name]; !present {
+				v.fail(join(path, name), "required", nil, "is required")
+			}
+		}
+	}
+
+	keys := make([]string, 0, len(obj))
+	for k := range obj {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+
+	for _, k := range keys {
+		prop, known := props[k].(Schema)
+		if !known {
+			if s["additionalProperties"] == false {
+				v.fail(join(path, k), "additionalProperties", nil, "unknown field")
+			}
+			continue
+		}
+		v.check(prop, obj[k], join(path, k))
+	}
+}
+
+func (v *validator) checkString(s Schema, str, path string) {
+	n := utf8.RuneCountInString(str)
+	if min, ok := s["minLength"].(int); ok && n < min {
+		v.fail(path, "minLength", str, "must be at least %d characters", min)
+	}
+	if max, ok := s["maxLength"].(int); ok && n > max {
+		v.fail(path, "maxLength", str, "must be at most %d characters", max)
+	}
+	if pattern, ok := s["pattern"].(string); ok {
+		if !compile(pattern).MatchString(str) {
+			v.fail(path, "pattern", str, "must match %s", pattern)
+		}
+	}
+	switch s["format"] {
+	case "date-time":
+		if _, err := db.ParseDateCreated(str); err != nil {
+			v.fail(path, "format", str, "must be an RFC 3339 date-time")
+		}
+	case "email":
+		if _, err := mail.ParseAddress(str); err != nil {
+			v.fail(path, "format", str, "must be an email address")
+		}
+	}
+}
+
+func (v *validator) checkNumber(s Schema, num json.Number, path string) {
+	value, ok := new(big.Rat).SetString(num.String())
+	if !ok {
+		return
+	}
+	if min, ok := s["minimum"].(int); ok && value.Cmp(big.NewRat(int64(min), 1)) < 0 {
+		v.fail(path, "minimum", num, "must be at least %d", min)
+	}
+	if max, ok := s["maximum"].(int); ok && value.Cmp(big.NewRat(int64(max), 1)) > 0 {
+		v.fail(path, "maximum", num, "must be at most %d", max)
+	}
+}
+
+func compile(pattern string) *regexp.Regexp {
+	if re, ok := patternCache.Load(pattern); ok {
+		return re.(*regexp.Regexp)
+	}
+	re := regexp.MustCompile(pattern)
+	patternCache.Store(pattern, re)
+	return re
+}
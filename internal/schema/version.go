@@ -0,0 +1,115 @@
+package schema
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"order-service/internal/validation"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// CurrentVersion is the version of the order message that db.Order decodes.
+//
+//	1 - national phone numbers, free-form currency
+//	2 - E.164 phone numbers, ISO 4217 currency codes
+const CurrentVersion = 2
+
+const (
+	// HeaderVersion is the message header carrying the payload version.
+	HeaderVersion = "schema-version"
+	// VersionField is the payload field carrying the version when there is no
+	// header. It is removed by Upcast.
+	VersionField = "schema_version"
+)
+
+// Upcaster rewrites a decoded JSON payload of one version into the next.
+type Upcaster func(doc map[string]any) error
+
+var (
+	upcastersMu sync.RWMutex
+	upcasters   = map[int]Upcaster{
+		1: upcastV1,
+	}
+)
+
+// RegisterUpcaster sets the upcaster that turns version from into from+1.
+func RegisterUpcaster(from int, fn Upcaster) {
+	upcastersMu.Lock()
+	defer upcastersMu.Unlock()
+	upcasters[from] = fn
+}
+
+// ParseVersion reads a version header value; an empty value yields def.
+func ParseVersion(value string, def int) (int, error) {
+	value = strings.TrimPrefix(strings.TrimSpace(value), "v")
+	if value == "" {
+		return def, nil
+	}
+	v, err := strconv.Atoi(value)
+	if err != nil || v < 1 {
+		return 0, fmt.Errorf("invalid schema version %q", value)
+	}
+	return v, nil
+}
+
+// Upcast brings a JSON order payload up to CurrentVersion. version is the
+// version named by the message header, or 0 to take it from the payload's
+// schema_version field and fall back to def.
+func Upcast(payload []byte, version, def int) ([]byte, error) {
+	dec := json.NewDecoder(bytes.NewReader(payload))
+	dec.UseNumber()
+	var doc map[string]any
+	if err := dec.Decode(&doc); err != nil {
+		return nil, err
+	}
+
+	field, hasField := doc[VersionField]
+	if version == 0 {
+		version = def
+		if hasField {
+			v, err := ParseVersion(fmt.Sprint(field), def)
+			if err != nil {
+				return nil, err
+			}
+			version = v
+		}
+	}
+	if version == CurrentVersion && !hasField {
+		return payload, nil
+	}
+	if version > CurrentVersion {
+		return nil, fmt.Errorf("schema version %d is newer than supported version %d", version, CurrentVersion)
+	}
+	delete(doc, VersionField)
+
+	upcastersMu.RLock()
+	defer upcastersMu.RUnlock()
+	for v := version; v < CurrentVersion; v++ {
+		fn, ok := upcasters[v]
+		if !ok {
+			return nil, fmt.Errorf("no upcaster from schema version %d", v)
+		}
+		if err := fn(doc); err != nil {
+			return nil, fmt.Errorf("failed to upcast from schema version %d: %w", v, err)
+		}
+	}
+	return json.Marshal(doc)
+}
+
+// upcastV1 converts national phone numbers to E.164 using the configured
+// phone region and upper-cases the currency code.
+func upcastV1(doc map[string]any) error {
+	if delivery, ok := doc["delivery"].(map[string]any); ok {
+		if phone, ok := delivery["phone"].(string); ok {
+			delivery["phone"] = validation.NormalizePhone(phone, validation.PhoneRegion())
+		}
+	}
+	if payment, ok := doc["payment"].(map[string]any); ok {
+		if currency, ok := payment["currency"].(string); ok {
+			payment["currency"] = strings.ToUpper(strings.TrimSpace(currency))
+		}
+	}
+	return nil
+}
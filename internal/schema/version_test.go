@@ -0,0 +1,165 @@
+package schema
+
+import (
+	"bytes"
+	"encoding/json"
+	"order-service/internal/validation"
+	"os"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func readFixture(t *testing.T, name string) []byte {
+	t.Helper()
+	data, err := os.ReadFile("testdata/" + name)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return data
+}
+
+func decodeDoc(t *testing.T, data []byte) map[string]any {
+	t.Helper()
+	var doc map[string]any
+	if err := json.Unmarshal(data, &doc); err != nil {
+		t.Fatalf("decode %s: %v", data, err)
+	}
+	return doc
+}
+
+// withPhoneRegion sets the phone region for the rest of the test.
+func withPhoneRegion(t *testing.T, region string) {
+	t.Helper()
+	prev := validation.PhoneRegion()
+	if err := validation.SetPhoneRegion(region); err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { validation.SetPhoneRegion(prev) })
+}
+
+func TestUpcastV1(t *testing.T) {
+	withPhoneRegion(t, "RU")
+	v1 := readFixture(t, "order-v1.json")
+	want := decodeDoc(t, readFixture(t, "order-v2.json"))
+
+	// The version comes from the payload field, from the header, or from the
+	// configured default; the field is dropped either way.
+	headerOnly := decodeDoc(t, v1)
+	delete(headerOnly, VersionField)
+	unlabeled, _ := json.Marshal(headerOnly)
+
+	tests := []struct {
+		name         string
+		payload      []byte
+		version, def int
+	}{
+		{"payload field", v1, 0, CurrentVersion},
+		{"header", unlabeled, 1, CurrentVersion},
+		{"header wins over field", v1, 1, CurrentVersion},
+		{"default", unlabeled, 0, 1},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := Upcast(tt.payload, tt.version, tt.def)
+			if err != nil {
+				t.Fatalf("Upcast: %v", err)
+			}
+			if doc := decodeDoc(t, got); !reflect.DeepEqual(doc, want) {
+				t.Errorf("Upcast = %s, want the v2 fixture", got)
+			}
+		})
+	}
+}
+
+// A national number cannot be converted without a region; it is left
+// digits-only for the e164 check to reject.
+func TestUpcastV1WithoutPhoneRegion(t *testing.T) {
+	withPhoneRegion(t, "")
+	got, err := Upcast(readFixture(t, "order-v1.json"), 0, CurrentVersion)
+	if err != nil {
+		t.Fatalf("Upcast: %v", err)
+	}
+	doc := decodeDoc(t, got)
+	if phone := doc["delivery"].(map[string]any)["phone"]; phone != "89161234567" {
+		t.Errorf("phone = %v, want the digits of the national number", phone)
+	}
+	if currency := doc["payment"].(map[string]any)["currency"]; currency != "USD" {
+		t.Errorf("currency = %v, want USD", currency)
+	}
+}
+
+func TestUpcastLeavesCurrentPayloadsAlone(t *testing.T) {
+	withPhoneRegion(t, "RU")
+	v2 := readFixture(t, "order-v2.json")
+	got, err := Upcast(v2, 0, CurrentVersion)
+	if err != nil {
+		t.Fatalf("Upcast: %v", err)
+	}
+	if !bytes.Equal(got, v2) {
+		t.Errorf("Upcast rewrote a current payload: %s", got)
+	}
+
+	// v2 values are already canonical, so upcasting them from v1 is a no-op.
+	got, err = Upcast(v2, 1, CurrentVersion)
+	if err != nil {
+		t.Fatalf("Upcast v2 as v1: %v", err)
+	}
+	if doc := decodeDoc(t, got); !reflect.DeepEqual(doc, decodeDoc(t, v2)) {
+		t.Errorf("Upcast v2 as v1 = %s, want the fixture unchanged", got)
+	}
+
+	labeled := decodeDoc(t, v2)
+	labeled[VersionField] = "v2"
+	payload, _ := json.Marshal(labeled)
+	got, err = Upcast(payload, 0, 1)
+	if err != nil {
+		t.Fatalf("Upcast labeled v2: %v", err)
+	}
+	if doc := decodeDoc(t, got); !reflect.DeepEqual(doc, decodeDoc(t, v2)) {
+		t.Errorf("Upcast labeled v2 = %s, want the fixture without %s", got, VersionField)
+	}
+}
+
+func TestUpcastErrors(t *testing.T) {
+	v2 := readFixture(t, "order-v2.json")
+	tests := []struct {
+		name    string
+		payload []byte
+		version int
+		want    string
+	}{
+		{"newer version", v2, CurrentVersion + 1, "newer than supported"},
+		{"bad version field", []byte(`{"schema_version": "x"}`), 0, `invalid schema version "x"`},
+		{"version zero", []byte(`{"schema_version": 0}`), 0, `invalid schema version "0"`},
+		{"not json", []byte(`{`), 1, "unexpected EOF"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := Upcast(tt.payload, tt.version, CurrentVersion)
+			if err == nil || !strings.Contains(err.Error(), tt.want) {
+				t.Errorf("Upcast error = %v, want one containing %q", err, tt.want)
+			}
+		})
+	}
+}
+
+func TestParseVersion(t *testing.T) {
+	tests := []struct {
+		value string
+		want  int
+		ok    bool
+	}{
+		{"", 2, true},
+		{"1", 1, true},
+		{" v2 ", 2, true},
+		{"0", 0, false},
+		{"two", 0, false},
+	}
+	for _, tt := range tests {
+		got, err := ParseVersion(tt.value, 2)
+		if (err == nil) != tt.ok || got != tt.want {
+			t.Errorf("ParseVersion(%q) = %d, %v", tt.value, got, err)
+		}
+	}
+}
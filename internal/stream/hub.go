@@ -0,0 +1,185 @@
+package stream
+
+import (
+	"order-service/internal/db"
+	"slices"
+	"sync"
+	"time"
+)
+
+const (
+	EventOrderSaved         = "order.saved"
+	EventOrderUpdated       = "order.updated"
+	EventOrderStatusChanged = "order.status_changed"
+	EventOrderDeleted       = "order.deleted"
+	// EventResync is sent to a resuming subscriber instead of the events it
+	// missed when they are no longer buffered, for example after a restart.
+	// The client has to reload the orders it follows.
+	EventResync = "stream.resync"
+)
+
+// Event is a change to an order as seen by live subscribers. IDs increase by
+// one per event; a hub starts counting at its start time in microseconds, so
+// IDs keep increasing across restarts.
+type Event struct {
+	ID       uint64           `json:"id"`
+	Type     string           `json:"type"`
+	OrderUID string           `json:"order_uid"`
+	Order    *db.Order        `json:"order,omitempty"`
+	Status   *db.StatusChange `json:"status,omitempty"`
+	Time     time.Time        `json:"time"`
+}
+
+func OrderSaved(order *db.Order) Event {
+	return Event{Type: EventOrderSaved, OrderUID: order.OrderUID, Order: order}
+}
+
+func OrderUpdated(order *db.Order) Event {
+	return Event{Type: EventOrderUpdated, OrderUID: order.OrderUID, Order: order}
+}
+
+// OrderDeleted builds a delete event; order is the order before it was
+// deleted and may be nil.
+func OrderDeleted(uid string, order *db.Order) Event {
+	return Event{Type: EventOrderDeleted, OrderUID: uid, Order: order}
+}
+
+// StatusChanged builds a status event; order is the order after the change
+// and may be nil when it could not be loaded.
+func StatusChanged(change *db.StatusChange, order *db.Order) Event {
+	return Event{Type: EventOrderStatusChanged, OrderUID: change.OrderUID, Order: order, Status: change}
+}
+
+// Filter selects the events a subscriber receives; nil matches every event.
+type Filter func(Event) bool
+
+// MatchOrders filters events by the customer and delivery service of the
+// order; an empty list matches any value. Events without an order snapshot
+// only pass when both lists are empty.
+func MatchOrders(customers, deliveryServices []string) Filter {
+	if len(customers) == 0 && len(deliveryServices) == 0 {
+		return nil
+	}
+	return func(e Event) bool {
+		if e.Order == nil {
+			return false
+		}
+		return (len(customers) == 0 || slices.Contains(customers, e.Order.CustomerID)) &&
+			(len(deliveryServices) == 0 || slices.Contains(deliveryServices, e.Order.DeliveryService))
+	}
+}
+
+// Hub fans events out to subscribers and keeps the most recent ones so that
+// reconnecting clients can resume where they stopped.
+type Hub struct {
+	mu      sync.Mutex
+	firstID uint64
+	nextID  uint64
+	replay  []Event
+	size    int
+	buffer  int
+	subs    map[*Subscription]struct{}
+}
+
+// Subscription receives events on C. C is closed when the subscriber falls
+// more than the buffer size behind; Lagged then reports true and the client
+// is expected to reconnect and resume from its last event ID.
+type Subscription struct {
+	C      <-chan Event
+	ch     chan Event
+	filter Filter
+	lagged bool
+}
+
+func NewHub(replaySize, clientBuffer int) *Hub {
+	first := uint64(time.Now().UnixMicro())
+	return &Hub{
+		firstID: first,
+		nextID:  first,
+		size:    replaySize,
+		buffer:  clientBuffer,
+		subs:    make(map[*Subscription]struct{}),
+	}
+}
+
+func (h *Hub) Publish(e Event) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	e.ID = h.nextID
+	h.nextID++
+	if e.Time.IsZero() {
+		e.Time = time.Now().UTC()
+	}
+
+	if h.size > 0 {
+		if len(h.replay) == h.size {
+			copy(h.replay, h.replay[1:])
+			h.replay = h.replay[:h.size-1]
+		}
+		h.replay = append(h.replay, e)
+	}
+
+	for sub := range h.subs {
+		if sub.filter != nil && !sub.filter(e) {
+			continue
+		}
+		select {
+		case sub.ch <- e:
+		default:
+			sub.lagged = true
+			close(sub.ch)
+			delete(h.subs, sub)
+		}
+	}
+}
+
+// Subscribe registers a subscriber and returns the buffered events after
+// lastID that match filter. A lastID of 0 starts with live events only. When
+// events after lastID are missing from the buffer, or lastID was not issued
+// by this hub (it predates a restart or comes from another instance),
+// complete reports false and the client should resync with EventResync.
+func (h *Hub) Subscribe(lastID uint64, filter Filter) (sub *Subscription, replay []Event, complete bool) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	complete = true
+	switch {
+	case lastID == 0:
+	case lastID < h.firstID || lastID >= h.nextID:
+		complete = false
+	default:
+		if len(h.replay) == 0 || h.replay[0].ID > lastID+1 {
+			complete = false
+		}
+		for _, e := range h.replay {
+			if e.ID > lastID && (filter == nil || filter(e)) {
+				replay = append(replay, e)
+			}
+		}
+	}
+
+	ch := make(chan Event, h.buffer)
+	sub = &Subscription{C: ch, ch: ch, filter: filter}
+	h.subs[sub] = struct{}{}
+	return sub, replay, complete
+}
+
+// Resync builds the EventResync event. It has no ID, so it does not move a
+// client's resume position.
+func Resync() Event {
+	return Event{Type: EventResync, Time: time.Now().UTC()}
+}
+
+func (h *Hub) Unsubscribe(sub *Subscription) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	if _, ok := h.subs[sub]; ok {
+		delete(h.subs, sub)
+		close(sub.ch)
+	}
+}
+
+func (s *Subscription) Lagged() bool {
+	return s.lagged
+}
@@ -0,0 +1,100 @@
+package stream
+
+import (
+	"order-service/internal/db"
+	"testing"
+	"time"
+)
+
+func publish(h *Hub, uids ...string) {
+	for _, uid := range uids {
+		h.Publish(OrderSaved(&db.Order{OrderUID: uid}))
+	}
+}
+
+func received(sub *Subscription) []string {
+	var uids []string
+	for {
+		select {
+		case e, ok := <-sub.C:
+			if !ok {
+				return uids
+			}
+			uids = append(uids, e.OrderUID)
+		default:
+			return uids
+		}
+	}
+}
+
+func TestHubIDsIncreaseAcrossRestarts(t *testing.T) {
+	before := uint64(time.Now().UnixMicro())
+	old := NewHub(10, 10)
+	sub, _, _ := old.Subscribe(0, nil)
+	publish(old, "a", "b")
+	first, second := <-sub.C, <-sub.C
+	if first.ID < before || second.ID != first.ID+1 {
+		t.Fatalf("IDs %d, %d; want consecutive IDs from the start time %d", first.ID, second.ID, before)
+	}
+
+	time.Sleep(time.Millisecond)
+	restarted := NewHub(10, 10)
+	sub, _, _ = restarted.Subscribe(0, nil)
+	publish(restarted, "c")
+	if e := <-sub.C; e.ID <= second.ID {
+		t.Errorf("ID after restart = %d, want more than %d", e.ID, second.ID)
+	}
+}
+
+func TestHubSubscribeResumes(t *testing.T) {
+	h := NewHub(2, 10)
+	live, _, _ := h.Subscribe(0, nil)
+	publish(h, "a", "b", "c", "d")
+	var ids []uint64
+	for range 4 {
+		ids = append(ids, (<-live.C).ID)
+	}
+
+	tests := []struct {
+		name     string
+		lastID   uint64
+		replay   int
+		complete bool
+	}{
+		{"live only", 0, 0, true},
+		{"buffered", ids[1], 2, true},
+		{"up to date", ids[3], 0, true},
+		{"older than the buffer", ids[0], 2, false},
+		{"before a restart", ids[0] - 1000, 0, false},
+		{"not issued yet", ids[3] + 1, 0, false},
+	}
+	for _, tt := range tests {
+		_, replay, complete := h.Subscribe(tt.lastID, nil)
+		if len(replay) != tt.replay || complete != tt.complete {
+			t.Errorf("%s: %d events, complete %t; want %d, %t", tt.name, len(replay), complete, tt.replay, tt.complete)
+		}
+	}
+}
+
+func TestHubFiltersDeletes(t *testing.T) {
+	h := NewHub(0, 10)
+	sub, _, _ := h.Subscribe(0, MatchOrders([]string{"alice"}, nil))
+	h.Publish(OrderDeleted("a", &db.Order{OrderUID: "a", CustomerID: "alice"}))
+	h.Publish(OrderDeleted("b", &db.Order{OrderUID: "b", CustomerID: "bob"}))
+	h.Publish(OrderDeleted("c", nil))
+	if got := received(sub); len(got) != 1 || got[0] != "a" {
+		t.Errorf("received %v, want [a]", got)
+	}
+}
+
+func TestHubDisconnectsSlowSubscribers(t *testing.T) {
+	h := NewHub(0, 1)
+	sub, _, _ := h.Subscribe(0, nil)
+	publish(h, "a", "b")
+	if got := received(sub); len(got) != 1 {
+		t.Errorf("received %v before the disconnect, want one event", got)
+	}
+	if _, ok := <-sub.C; ok || !sub.Lagged() {
+		t.Error("slow subscriber not disconnected as lagged")
+	}
+}
@@ -0,0 +1,202 @@
+package validation
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"order-service/internal/db"
+	"regexp"
+	"sort"
+	"strings"
+
+	"github.com/go-playground/validator"
+)
+
+var currencyCodes = makeSet(strings.Fields(`
+	AED AFN ALL AMD ANG AOA ARS AUD AWG AZN BAM BBD BDT BGN BHD BIF BMD BND BOB
+	BRL BSD BTN BWP BYN BZD CAD CDF CHF CLP CNY COP CRC CUP CVE CZK DJF DKK DOP
+	DZD EGP ERN ETB EUR FJD FKP GBP GEL GHS GIP GMD GNF GTQ GYD HKD HNL HTG HUF
+	IDR ILS INR IQD IRR ISK JMD JOD JPY KES KGS KHR KMF KPW KRW KWD KYD KZT LAK
+	LBP LKR LRD LSL LYD MAD MDL MGA MKD MMK MNT MOP MRU MUR MVR MWK MXN MYR MZN
+	NAD NGN NIO NOK NPR NZD OMR PAB PEN PGK PHP PKR PLN PYG QAR RON RSD RUB RWF
+	SAR SBD SCR SDG SEK SGD SHP SLE SOS SRD SSP STN SVC SYP SZL THB TJS TMT TND
+	TOP TRY TTD TWD TZS UAH UGX USD UYU UZS VES VND VUV WST XAF XCD XOF XPF YER
+	ZAR ZMW ZWL`))
+
+// BCP47Pattern accepts well-formed language tags: language, optional script,
+// optional region and any number of variants or extensions.
+const BCP47Pattern = `^[a-zA-Z]{2,3}(-[a-zA-Z]{4})?(-([a-zA-Z]{2}|[0-9]{3}))?(-([a-zA-Z0-9]{5,8}|[0-9][a-zA-Z0-9]{3}))*(-[a-wyzA-WYZ0-9](-[a-zA-Z0-9]{2,8})+)*$`
+
+var bcp47Pattern = regexp.MustCompile(BCP47Pattern)
+
+var allowedLocales = makeSet([]string{"en", "ru", "pt"})
+
+// callingCodes maps a region to its country calling code and the national
+// trunk prefix that has to be dropped when converting to E.164.
+var callingCodes = map[string]struct{ code, trunk string }{
+	"RU": {"7", "8"},
+	"KZ": {"7", "8"},
+	"BY": {"375", "80"},
+	"UA": {"380", "0"},
+	"IL": {"972", "0"},
+	"DE": {"49", "0"},
+	"GB": {"44", "0"},
+	"FR": {"33", "0"},
+	"PT": {"351", ""},
+	"BR": {"55", "0"},
+	"US": {"1", "1"},
+	"CA": {"1", "1"},
+}
+
+var phoneRegion string
+
+func init() {
+	validate.RegisterValidation("iso4217", func(fl validator.FieldLevel) bool {
+		return currencyCodes[fl.Field().String()]
+	})
+	validate.RegisterValidation("bcp47", func(fl validator.FieldLevel) bool {
+		return IsAllowedLocale(fl.Field().String())
+	})
+}
+
+func CurrencyCodes() []string {
+	codes := make([]string, 0, len(currencyCodes))
+	for c := range currencyCodes {
+		codes = append(codes, c)
+	}
+	sort.Strings(codes)
+	return codes
+}
+
+func AllowedLocales() []string {
+	locales := make([]string, 0, len(allowedLocales))
+	for l := range allowedLocales {
+		locales = append(locales, l)
+	}
+	sort.Strings(locales)
+	return locales
+}
+
+func makeSet(values []string) map[string]bool {
+	set := make(map[string]bool, len(values))
+	for _, v := range values {
+		set[v] = true
+	}
+	return set
+}
+
+// SetAllowedLocales replaces the locale allow-list. An entry allows the tag
+// itself and every more specific tag, so "en" allows "en-US".
+func SetAllowedLocales(locales []string) {
+	set := make(map[string]bool, len(locales))
+	for _, l := range locales {
+		set[strings.ToLower(l)] = true
+	}
+	allowedLocales = set
+}
+
+func IsAllowedLocale(tag string) bool {
+	if !bcp47Pattern.MatchString(tag) {
+		return false
+	}
+	tag = strings.ToLower(tag)
+	for {
+		if allowedLocales[tag] {
+			return true
+		}
+		i := strings.LastIndex(tag, "-")
+		if i < 0 {
+			return false
+		}
+		tag = tag[:i]
+	}
+}
+
+// SetPhoneRegion sets the region whose national numbers NormalizePhone turns
+// into international ones, e.g. "RU" turns 89161234567 into +79161234567.
+func SetPhoneRegion(region string) error {
+	region = strings.ToUpper(region)
+	if _, ok := callingCodes[region]; region != "" && !ok {
+		return fmt.Errorf("unsupported phone region %q", region)
+	}
+	phoneRegion = region
+	return nil
+}
+
+func PhoneRegion() string {
+	return phoneRegion
+}
+
+// NormalizePhone strips formatting from a phone number and converts it to
+// E.164. Numbers without an international prefix are read as national numbers
+// of region; when region is empty they are returned digits-only and will fail
+// the e164 check.
+func NormalizePhone(raw, region string) string {
+	var b strings.Builder
+	for i, r := range strings.TrimSpace(raw) {
+		switch {
+		case r >= '0' && r <= '9':
+			b.WriteRune(r)
+		case r == '+' && i == 0:
+			b.WriteRune(r)
+		}
+	}
+	phone := b.String()
+
+	switch {
+	case strings.HasPrefix(phone, "+"):
+		return phone
+	case strings.HasPrefix(phone, "00"):
+		return "+" + phone[2:]
+	}
+
+	cc, ok := callingCodes[strings.ToUpper(region)]
+	if !ok {
+		return phone
+	}
+	if cc.trunk != "" && strings.HasPrefix(phone, cc.trunk) {
+		return "+" + cc.code + phone[len(cc.trunk):]
+	}
+	if strings.HasPrefix(phone, cc.code) {
+		return "+" + phone
+	}
+	return "+" + cc.code + phone
+}
+
+// NormalizeOrder rewrites fields that have several accepted spellings into
+// their canonical form before validation.
+func NormalizeOrder(order *db.Order) {
+	order.Delivery.Phone = NormalizePhone(order.Delivery.Phone, phoneRegion)
+	order.Payment.Currency = normalizeCurrency(order.Payment.Currency)
+}
+
+func normalizeCurrency(code string) string {
+	return strings.ToUpper(strings.TrimSpace(code))
+}
+
+// NormalizeJSON applies NormalizeOrder to a raw JSON order, so that a JSON
+// Schema check sees the same canonical values as the struct validation.
+// Anything that is not a JSON object is returned unchanged.
+func NormalizeJSON(payload []byte) []byte {
+	dec := json.NewDecoder(bytes.NewReader(payload))
+	dec.UseNumber()
+	var doc map[string]any
+	if err := dec.Decode(&doc); err != nil {
+		return payload
+	}
+	if delivery, ok := doc["delivery"].(map[string]any); ok {
+		if phone, ok := delivery["phone"].(string); ok {
+			delivery["phone"] = NormalizePhone(phone, phoneRegion)
+		}
+	}
+	if payment, ok := doc["payment"].(map[string]any); ok {
+		if currency, ok := payment["currency"].(string); ok {
+			payment["currency"] = normalizeCurrency(currency)
+		}
+	}
+	normalized, err := json.Marshal(doc)
+	if err != nil {
+		return payload
+	}
+	return normalized
+}
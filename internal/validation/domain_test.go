@@ -0,0 +1,120 @@
+package validation
+
+import "testing"
+
+func TestDomainTags(t *testing.T) {
+	tests := []struct {
+		tag   string
+		value string
+		valid bool
+	}{
+		{"iso4217", "USD", true},
+		{"iso4217", "EUR", true},
+		{"iso4217", "RUB", true},
+		{"iso4217", "EURO", false},
+		{"iso4217", "usd", false},
+		{"iso4217", "XXX", false},
+		{"iso4217", "", false},
+
+		{"bcp47", "en", true},
+		{"bcp47", "en-US", true},
+		{"bcp47", "ru-RU", true},
+		{"bcp47", "pt-BR", true},
+		{"bcp47", "EN", true},
+		{"bcp47", "de", false},
+		{"bcp47", "english", false},
+		{"bcp47", "en_US", false},
+		{"bcp47", "", false},
+
+		{"e164", "+79161234567", true},
+		{"e164", "+375291234567", true},
+		{"e164", "+9720000000", true},
+		{"e164", "89161234567", false},
+		{"e164", "8033412412", false},
+		{"e164", "+7 916 123-45-67", false},
+		{"e164", "+123", false},
+
+		{"email", "test@gmail.com", true},
+		{"email", "first.last+tag@example.co.uk", true},
+		{"email", "test@", false},
+		{"email", "@gmail.com", false},
+		{"email", "test gmail.com", false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.tag+"/"+tt.value, func(t *testing.T) {
+			err := validate.Var(tt.value, tt.tag)
+			if (err == nil) != tt.valid {
+				t.Errorf("%s(%q) valid = %t, want %t (err %v)", tt.tag, tt.value, err == nil, tt.valid, err)
+			}
+		})
+	}
+}
+
+func TestSetAllowedLocales(t *testing.T) {
+	defer SetAllowedLocales(AllowedLocales())
+	SetAllowedLocales([]string{"de-AT"})
+
+	tests := map[string]bool{
+		"de-AT":    true,
+		"de-at":    true,
+		"de-AT-x1": false,
+		"de":       false,
+		"en":       false,
+	}
+	for tag, want := range tests {
+		if got := IsAllowedLocale(tag); got != want {
+			t.Errorf("IsAllowedLocale(%q) = %t, want %t", tag, got, want)
+		}
+	}
+}
+
+func TestNormalizePhone(t *testing.T) {
+	tests := []struct {
+		raw, region, want string
+	}{
+		{"+7 (916) 123-45-67", "", "+79161234567"},
+		{"0079161234567", "", "+79161234567"},
+		{"89161234567", "RU", "+79161234567"},
+		{"79161234567", "RU", "+79161234567"},
+		{"9161234567", "RU", "+79161234567"},
+		{"80291234567", "BY", "+375291234567"},
+		{"054-123-4567", "IL", "+972541234567"},
+		{"912345678", "PT", "+351912345678"},
+		{"89161234567", "", "89161234567"},
+		{"89161234567", "XX", "89161234567"},
+	}
+	for _, tt := range tests {
+		if got := NormalizePhone(tt.raw, tt.region); got != tt.want {
+			t.Errorf("NormalizePhone(%q, %q) = %q, want %q", tt.raw, tt.region, got, tt.want)
+		}
+	}
+}
+
+func TestSetPhoneRegion(t *testing.T) {
+	defer SetPhoneRegion(PhoneRegion())
+	if err := SetPhoneRegion("ru"); err != nil || PhoneRegion() != "RU" {
+		t.Errorf("SetPhoneRegion(ru) = %v, region %q", err, PhoneRegion())
+	}
+	if err := SetPhoneRegion(""); err != nil {
+		t.Errorf("SetPhoneRegion(\"\") = %v", err)
+	}
+	if err := SetPhoneRegion("XX"); err == nil {
+		t.Error("SetPhoneRegion(XX) accepted an unsupported region")
+	}
+}
+
+func TestNormalizeOrderFixesCurrencyAndPhone(t *testing.T) {
+	defer SetPhoneRegion(PhoneRegion())
+	SetPhoneRegion("RU")
+
+	o := validOrder()
+	o.Payment.Currency = " usd "
+	o.Delivery.Phone = "8 (916) 123-45-67"
+	NormalizeOrder(o)
+	if o.Payment.Currency != "USD" || o.Delivery.Phone != "+79161234567" {
+		t.Errorf("normalized currency %q, phone %q", o.Payment.Currency, o.Delivery.Phone)
+	}
+	if err := ValidateOrder(o); err != nil {
+		t.Errorf("ValidateOrder after normalizing: %v", err)
+	}
+}
@@ -0,0 +1,317 @@
+package validation
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"maps"
+	"order-service/internal/db"
+	"os"
+	"path/filepath"
+	"regexp"
+	"slices"
+	"strconv"
+	"strings"
+	"sync/atomic"
+	"time"
+
+	"gopkg.in/yaml.v3"
+)
+
+// Profile adjusts validation for orders from one upstream source. It adds
+// constraints, and Relax lifts struct-tag rules of db.Order: each entry names
+// a field and the tags to drop there, or every tag on it when the list is
+// empty. Field paths use the JSON names of the order, with "[]" to address
+// every element of a list, e.g. "items[].price".
+type Profile struct {
+	Name     string              `json:"name" yaml:"name"`
+	Match    ProfileMatch        `json:"match" yaml:"match"`
+	Required []string            `json:"required" yaml:"required"`
+	Ranges   map[string]Range    `json:"ranges" yaml:"ranges"`
+	Patterns map[string]string   `json:"patterns" yaml:"patterns"`
+	Enums    map[string][]string `json:"enums" yaml:"enums"`
+	Relax    map[string][]string `json:"relax" yaml:"relax"`
+
+	patterns map[string]*regexp.Regexp
+}
+
+// ProfileMatch selects the orders a profile applies to; an empty list matches
+// any value.
+type ProfileMatch struct {
+	Entry           []string `json:"entry" yaml:"entry"`
+	DeliveryService []string `json:"delivery_service" yaml:"delivery_service"`
+}
+
+// Range bounds a number, or the length of a string or list.
+type Range struct {
+	Min *float64 `json:"min" yaml:"min"`
+	Max *float64 `json:"max" yaml:"max"`
+}
+
+type ProfileSet struct {
+	Profiles []*Profile `json:"profiles" yaml:"profiles"`
+}
+
+var profiles atomic.Pointer[ProfileSet]
+
+func SetProfiles(set *ProfileSet) {
+	profiles.Store(set)
+}
+
+func LoadProfiles(path string) (*ProfileSet, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read profiles: %w", err)
+	}
+
+	var set ProfileSet
+	switch strings.ToLower(filepath.Ext(path)) {
+	case ".yaml", ".yml":
+		err = yaml.Unmarshal(data, &set)
+	default:
+		err = json.Unmarshal(data, &set)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse profiles %s: %w", path, err)
+	}
+
+	for i, p := range set.Profiles {
+		if p.Name == "" {
+			p.Name = strconv.Itoa(i)
+		}
+		p.patterns = make(map[string]*regexp.Regexp, len(p.Patterns))
+		for field, expr := range p.Patterns {
+			re, err := regexp.Compile(expr)
+			if err != nil {
+				return nil, fmt.Errorf("profile %s: invalid pattern for %s: %w", p.Name, field, err)
+			}
+			p.patterns[field] = re
+		}
+		for field := range p.Relax {
+			if field == "" {
+				return nil, fmt.Errorf("profile %s: relax needs a field path", p.Name)
+			}
+		}
+	}
+	return &set, nil
+}
+
+// Select returns the first profile matching the order, or nil.
+func (s *ProfileSet) Select(order *db.Order) *Profile {
+	if s == nil {
+		return nil
+	}
+	for _, p := range s.Profiles {
+		if matches(p.Match.Entry, order.Entry) && matches(p.Match.DeliveryService, order.DeliveryService) {
+			return p
+		}
+	}
+	return nil
+}
+
+func matches(allowed []string, value string) bool {
+	return len(allowed) == 0 || slices.Contains(allowed, value)
+}
+
+var listIndex = regexp.MustCompile(`\[\d+\]`)
+
+// Relaxes reports whether the profile lifts the struct-tag rule behind v.
+// A nil profile relaxes nothing.
+func (p *Profile) Relaxes(v Violation) bool {
+	if p == nil {
+		return false
+	}
+	tags, ok := p.Relax[listIndex.ReplaceAllString(v.Path, "[]")]
+	return ok && (len(tags) == 0 || slices.Contains(tags, v.Rule))
+}
+
+func (p *Profile) Check(order *db.Order) []Violation {
+	var doc any
+	data, err := json.Marshal(order)
+	if err == nil {
+		err = json.Unmarshal(data, &doc)
+	}
+	if err != nil {
+		return []Violation{{Rule: "profile", Message: fmt.Sprintf("failed to inspect order: %v", err)}}
+	}
+
+	var violations []Violation
+	add := func(rule string, f field, msg string) {
+		violations = append(violations, Violation{
+			Path:    f.path,
+			Rule:    "profile:" + p.Name + ":" + rule,
+			Value:   f.value,
+			Message: msg,
+		})
+	}
+
+	for _, path := range p.Required {
+		for _, f := range resolve(doc, path) {
+			if isZero(f.value) {
+				add("required", f, "is required")
+			}
+		}
+	}
+	// Constraints are checked in path order so violations are reported the
+	// same way every time.
+	for _, path := range slices.Sorted(maps.Keys(p.Ranges)) {
+		r := p.Ranges[path]
+		for _, f := range resolve(doc, path) {
+			n, ok := magnitude(f.value)
+			if !ok {
+				continue
+			}
+			if r.Min != nil && n < *r.Min {
+				add("range", f, fmt.Sprintf("must be at least %v", *r.Min))
+			}
+			if r.Max != nil && n > *r.Max {
+				add("range", f, fmt.Sprintf("must be at most %v", *r.Max))
+			}
+		}
+	}
+	for _, path := range slices.Sorted(maps.Keys(p.patterns)) {
+		re := p.patterns[path]
+		for _, f := range resolve(doc, path) {
+			if !re.MatchString(scalarString(f.value)) {
+				add("pattern", f, fmt.Sprintf("must match %s", re))
+			}
+		}
+	}
+	for _, path := range slices.Sorted(maps.Keys(p.Enums)) {
+		values := p.Enums[path]
+		for _, f := range resolve(doc, path) {
+			if !slices.Contains(values, scalarString(f.value)) {
+				add("enum", f, fmt.Sprintf("must be one of: %s", strings.Join(values, ", ")))
+			}
+		}
+	}
+	return violations
+}
+
+type field struct {
+	path  string
+	value any
+}
+
+func resolve(doc any, path string) []field {
+	current := []field{{value: doc}}
+	for _, seg := range strings.Split(path, ".") {
+		each := strings.HasSuffix(seg, "[]")
+		key := strings.TrimSuffix(seg, "[]")
+
+		var next []field
+		for _, f := range current {
+			obj, ok := f.value.(map[string]any)
+			if !ok {
+				continue
+			}
+			p := key
+			if f.path != "" {
+				p = f.path + "." + key
+			}
+			v := obj[key]
+			if !each {
+				next = append(next, field{path: p, value: v})
+				continue
+			}
+			list, _ := v.([]any)
+			for i, elem := range list {
+				next = append(next, field{path: fmt.Sprintf("%s[%d]", p, i), value: elem})
+			}
+		}
+		current = next
+	}
+	return current
+}
+
+func isZero(v any) bool {
+	switch v := v.(type) {
+	case nil:
+		return true
+	case string:
+		return v == ""
+	case float64:
+		return v == 0
+	case bool:
+		return !v
+	case []any:
+		return len(v) == 0
+	case map[string]any:
+		return len(v) == 0
+	}
+	return false
+}
+
+func magnitude(v any) (float64, bool) {
+	switch v := v.(type) {
+	case float64:
+		return v, true
+	case string:
+		return float64(len([]rune(v))), true
+	case []any:
+		return float64(len(v)), true
+	}
+	return 0, false
+}
+
+func scalarString(v any) string {
+	switch v := v.(type) {
+	case string:
+		return v
+	case float64:
+		return strconv.FormatFloat(v, 'f', -1, 64)
+	case nil:
+		return ""
+	}
+	return fmt.Sprint(v)
+}
+
+// WatchProfiles loads the profiles file and reloads it whenever its
+// modification time changes, until ctx is cancelled. A file that fails to
+// load leaves the previous profiles in place.
+func WatchProfiles(ctx context.Context, path string, interval time.Duration) error {
+	set, err := LoadProfiles(path)
+	if err != nil {
+		return err
+	}
+	SetProfiles(set)
+	log.Printf("Loaded %d validation profiles from %s", len(set.Profiles), path)
+
+	info, err := os.Stat(path)
+	if err != nil {
+		return err
+	}
+	modTime := info.ModTime()
+
+	go func() {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+			}
+
+			info, err := os.Stat(path)
+			if err != nil {
+				log.Printf("Failed to stat validation profiles: %v", err)
+				continue
+			}
+			if info.ModTime().Equal(modTime) {
+				continue
+			}
+			modTime = info.ModTime()
+
+			set, err := LoadProfiles(path)
+			if err != nil {
+				log.Printf("Keeping previous validation profiles: %v", err)
+				continue
+			}
+			SetProfiles(set)
+			log.Printf("Reloaded %d validation profiles from %s", len(set.Profiles), path)
+		}
+	}()
+	return nil
+}
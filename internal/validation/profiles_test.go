@@ -0,0 +1,254 @@
+package validation
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"order-service/internal/db"
+)
+
+func mustLoadProfiles(t *testing.T, name, content string) *ProfileSet {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), name)
+	if err := os.WriteFile(path, []byte(content), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	set, err := LoadProfiles(path)
+	if err != nil {
+		t.Fatalf("LoadProfiles: %v", err)
+	}
+	return set
+}
+
+func TestLoadProfilesExampleFile(t *testing.T) {
+	set, err := LoadProfiles("../../config/validation-profiles.yaml")
+	if err != nil {
+		t.Fatalf("LoadProfiles: %v", err)
+	}
+	if len(set.Profiles) != 2 || set.Profiles[0].Name != "wbil-meest" || set.Profiles[1].Name != "default" {
+		t.Fatalf("profiles = %+v", set.Profiles)
+	}
+	if err := set.Profiles[0].Check(validOrder()); err != nil {
+		t.Errorf("model.json violates the example profile: %v", err)
+	}
+}
+
+func TestLoadProfilesErrors(t *testing.T) {
+	tests := map[string]string{
+		"bad.json":    `{"profiles": [`,
+		"bad.yaml":    "profiles: [",
+		"regexp.json": `{"profiles": [{"patterns": {"entry": "("}}]}`,
+		"relax.json":  `{"profiles": [{"relax": {"": []}}]}`,
+	}
+	for name, content := range tests {
+		path := filepath.Join(t.TempDir(), name)
+		os.WriteFile(path, []byte(content), 0o644)
+		if _, err := LoadProfiles(path); err == nil {
+			t.Errorf("LoadProfiles(%s) accepted %s", name, content)
+		}
+	}
+	if _, err := LoadProfiles(filepath.Join(t.TempDir(), "missing.yaml")); err == nil {
+		t.Error("LoadProfiles accepted a missing file")
+	}
+}
+
+func TestProfileSelect(t *testing.T) {
+	set := mustLoadProfiles(t, "p.yaml", `
+profiles:
+  - name: wbil
+    match: {entry: [WBIL], delivery_service: [meest, dhl]}
+  - name: any-dhl
+    match: {delivery_service: [dhl]}
+`)
+	tests := []struct {
+		entry, service, want string
+	}{
+		{"WBIL", "meest", "wbil"},
+		{"WBIL", "dhl", "wbil"},
+		{"OZON", "dhl", "any-dhl"},
+		{"OZON", "meest", ""},
+	}
+	for _, tt := range tests {
+		o := &db.Order{Entry: tt.entry, DeliveryService: tt.service}
+		got := ""
+		if p := set.Select(o); p != nil {
+			got = p.Name
+		}
+		if got != tt.want {
+			t.Errorf("Select(%s, %s) = %q, want %q", tt.entry, tt.service, got, tt.want)
+		}
+	}
+	if (*ProfileSet)(nil).Select(validOrder()) != nil {
+		t.Error("nil set selected a profile")
+	}
+}
+
+func TestProfileCheck(t *testing.T) {
+	set := mustLoadProfiles(t, "p.json", `{"profiles": [{
+		"name": "p",
+		"required": ["delivery.email", "payment.request_id"],
+		"ranges": {"payment.amount": {"max": 1000}, "items": {"max": 1}, "items[].price": {"min": 500}},
+		"patterns": {"track_number": "^WB", "items[].rid": "^[a-f0-9]+test$"},
+		"enums": {"payment.currency": ["RUB"], "locale": ["en", "ru"]}
+	}]}`)
+	p := set.Profiles[0]
+
+	tests := []struct {
+		name   string
+		modify func(*db.Order)
+		want   []string
+	}{
+		{"model.json", func(o *db.Order) {}, []string{
+			"payment.request_id profile:p:required",
+			"items[0].price profile:p:range",
+			"payment.amount profile:p:range",
+			"payment.currency profile:p:enum",
+		}},
+		{"fixed", func(o *db.Order) {
+			o.Items[0].Price = 500
+			o.Payment.Amount = 1000
+			o.Payment.RequestID = "r1"
+			o.Payment.Currency = "RUB"
+		}, nil},
+		{"every item", func(o *db.Order) {
+			o.Items[0].Price = 500
+			o.Payment.Amount = 1000
+			o.Payment.RequestID = "r1"
+			o.Payment.Currency = "RUB"
+			o.Items = append(o.Items, o.Items[0])
+			o.Items[1].Rid = "XYZ"
+			o.TrackNumber = "OZ1"
+		}, []string{
+			"items profile:p:range",
+			"items[1].rid profile:p:pattern",
+			"track_number profile:p:pattern",
+		}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			o := validOrder()
+			tt.modify(o)
+			// Map iteration order is random; the report must not be.
+			for range 20 {
+				var got []string
+				for _, v := range p.Check(o) {
+					got = append(got, v.Path+" "+v.Rule)
+				}
+				if len(got) != len(tt.want) {
+					t.Fatalf("violations = %q, want %q", got, tt.want)
+				}
+				for i := range got {
+					if got[i] != tt.want[i] {
+						t.Fatalf("violations = %q, want %q", got, tt.want)
+					}
+				}
+			}
+		})
+	}
+}
+
+func TestValidateOrderRelaxedByProfile(t *testing.T) {
+	defer SetProfiles(nil)
+
+	tests := []struct {
+		name    string
+		profile string
+		modify  func(*db.Order)
+		want    []string
+	}{
+		{"tag rule applies without a profile", "", func(o *db.Order) {
+			o.Delivery.Zip = "SW1A 1AA"
+		}, []string{"delivery.zip numeric"}},
+		{"one tag relaxed", `relax: {delivery.zip: [numeric]}`, func(o *db.Order) {
+			o.Delivery.Zip = "SW1A 1AA"
+		}, nil},
+		{"other tags on the field still apply", `relax: {delivery.zip: [numeric]}`, func(o *db.Order) {
+			o.Delivery.Zip = ""
+		}, []string{"delivery.zip required"}},
+		{"every tag relaxed", `relax: {payment.request_id: []}`, func(o *db.Order) {
+			o.Payment.RequestID = "a-request-id-that-is-longer-than-fifty-characters-long"
+		}, nil},
+		{"list elements", `relax: {"items[].size": [max]}`, func(o *db.Order) {
+			o.Items = append(o.Items, o.Items[0])
+			o.Items[1].Size = "extra-extra-large"
+			o.Payment.GoodsTotal = 634
+			o.Payment.Amount = 2134
+		}, nil},
+		{"relaxed field checked by the profile instead", `
+relax: {delivery.zip: [numeric]}
+patterns: {delivery.zip: "^[A-Z0-9 ]+$"}`, func(o *db.Order) {
+			o.Delivery.Zip = "sw1a 1aa"
+		}, []string{"delivery.zip profile:p:pattern"}},
+		{"profile of another source", `
+match: {entry: [OZON]}
+relax: {delivery.zip: [numeric]}`, func(o *db.Order) {
+			o.Delivery.Zip = "SW1A 1AA"
+		}, []string{"delivery.zip numeric"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			SetProfiles(nil)
+			if tt.profile != "" {
+				yaml := "profiles:\n  - name: p\n"
+				for _, line := range strings.Split(strings.TrimSpace(tt.profile), "\n") {
+					yaml += "    " + line + "\n"
+				}
+				SetProfiles(mustLoadProfiles(t, "p.yaml", yaml))
+			}
+
+			o := validOrder()
+			tt.modify(o)
+			var got []string
+			if err := ValidateOrder(o); err != nil {
+				for _, v := range AsValidationError(err, "").Violations {
+					got = append(got, v.Path+" "+v.Rule)
+				}
+			}
+			if len(got) != len(tt.want) {
+				t.Fatalf("violations = %q, want %q", got, tt.want)
+			}
+			for i := range got {
+				if got[i] != tt.want[i] {
+					t.Errorf("violations = %q, want %q", got, tt.want)
+				}
+			}
+		})
+	}
+}
+
+func TestWatchProfilesReloads(t *testing.T) {
+	defer SetProfiles(nil)
+	path := filepath.Join(t.TempDir(), "profiles.yaml")
+	os.WriteFile(path, []byte("profiles: [{name: first}]"), 0o644)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	if err := WatchProfiles(ctx, path, 5*time.Millisecond); err != nil {
+		t.Fatalf("WatchProfiles: %v", err)
+	}
+	if name := profiles.Load().Profiles[0].Name; name != "first" {
+		t.Fatalf("loaded profile %q, want first", name)
+	}
+
+	// A broken file keeps the previous profiles.
+	os.WriteFile(path, []byte("profiles: ["), 0o644)
+	os.Chtimes(path, time.Now(), time.Now().Add(time.Minute))
+	time.Sleep(50 * time.Millisecond)
+	if name := profiles.Load().Profiles[0].Name; name != "first" {
+		t.Fatalf("profile after a broken reload = %q, want first", name)
+	}
+
+	os.WriteFile(path, []byte("profiles: [{name: second}]"), 0o644)
+	os.Chtimes(path, time.Now(), time.Now().Add(2*time.Minute))
+	deadline := time.Now().Add(2 * time.Second)
+	for profiles.Load().Profiles[0].Name != "second" {
+		if time.Now().After(deadline) {
+			t.Fatal("profiles were not reloaded")
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+}
@@ -0,0 +1,176 @@
+package validation
+
+import (
+	"fmt"
+	"order-service/internal/db"
+	"sort"
+	"strings"
+)
+
+type Mode string
+
+const (
+	ModeStrict Mode = "strict"
+	ModeWarn   Mode = "warn"
+	ModeOff    Mode = "off"
+)
+
+type Violation struct {
+	Path    string `json:"path"`
+	Rule    string `json:"rule"`
+	Value   any    `json:"value,omitempty"`
+	Message string `json:"message"`
+}
+
+func (v Violation) String() string {
+	return fmt.Sprintf("%s: %s [%s]", v.Path, v.Message, v.Rule)
+}
+
+type Rule struct {
+	Name  string
+	Check func(order *db.Order) []Violation
+}
+
+// Rules are the cross-field invariants of an order. Amounts are in minor
+// units and sale is a percentage, so item totals are truncated like model.json
+// does (453 with 30% off is 317).
+var Rules = []Rule{
+	{Name: "item_total_price", Check: checkItemTotals},
+	{Name: "goods_total", Check: checkGoodsTotal},
+	{Name: "payment_amount", Check: checkPaymentAmount},
+}
+
+func checkItemTotals(order *db.Order) []Violation {
+	var violations []Violation
+	for i, item := range order.Items {
+		expected := item.Price * (100 - item.Sale) / 100
+		if item.TotalPrice != expected {
+			violations = append(violations, Violation{
+				Path:  fmt.Sprintf("items[%d].total_price", i),
+				Value: item.TotalPrice,
+				Message: fmt.Sprintf("total_price %d does not match price %d with %d%% sale (expected %d)",
+					item.TotalPrice, item.Price, item.Sale, expected),
+			})
+		}
+	}
+	return violations
+}
+
+func checkGoodsTotal(order *db.Order) []Violation {
+	sum := 0
+	for _, item := range order.Items {
+		sum += item.TotalPrice
+	}
+	if order.Payment.GoodsTotal == sum {
+		return nil
+	}
+	return []Violation{{
+		Path:    "payment.goods_total",
+		Value:   order.Payment.GoodsTotal,
+		Message: fmt.Sprintf("goods_total %d does not match sum of items total_price %d", order.Payment.GoodsTotal, sum),
+	}}
+}
+
+func checkPaymentAmount(order *db.Order) []Violation {
+	p := order.Payment
+	expected := p.GoodsTotal + p.DeliveryCost + p.CustomFee
+	if p.Amount == expected {
+		return nil
+	}
+	return []Violation{{
+		Path:  "payment.amount",
+		Value: p.Amount,
+		Message: fmt.Sprintf("amount %d does not match goods_total %d + delivery_cost %d + custom_fee %d (expected %d)",
+			p.Amount, p.GoodsTotal, p.DeliveryCost, p.CustomFee, expected),
+	}}
+}
+
+type RuleEngine struct {
+	rules       []Rule
+	modes       map[string]Mode
+	defaultMode Mode
+}
+
+func NewRuleEngine(rules []Rule, modes map[string]Mode, defaultMode Mode) *RuleEngine {
+	return &RuleEngine{rules: rules, modes: modes, defaultMode: defaultMode}
+}
+
+func (e *RuleEngine) Mode(rule string) Mode {
+	if m, ok := e.modes[rule]; ok {
+		return m
+	}
+	return e.defaultMode
+}
+
+type Report struct {
+	Errors   []Violation `json:"errors,omitempty"`
+	Warnings []Violation `json:"warnings,omitempty"`
+}
+
+func (e *RuleEngine) Check(order *db.Order) Report {
+	var report Report
+	for _, rule := range e.rules {
+		mode := e.Mode(rule.Name)
+		if mode == ModeOff {
+			continue
+		}
+		for _, v := range rule.Check(order) {
+			v.Rule = rule.Name
+			if mode == ModeWarn {
+				report.Warnings = append(report.Warnings, v)
+			} else {
+				report.Errors = append(report.Errors, v)
+			}
+		}
+	}
+	return report
+}
+
+// ParseRuleModes parses "rule=mode" pairs separated by commas, for example
+// "goods_total=strict,payment_amount=warn".
+func ParseRuleModes(s string) (map[string]Mode, error) {
+	known := make(map[string]bool, len(Rules))
+	for _, r := range Rules {
+		known[r.Name] = true
+	}
+
+	modes := make(map[string]Mode)
+	for _, pair := range strings.Split(s, ",") {
+		pair = strings.TrimSpace(pair)
+		if pair == "" {
+			continue
+		}
+		name, mode, ok := strings.Cut(pair, "=")
+		if !ok {
+			return nil, fmt.Errorf("invalid rule setting %q, want rule=mode", pair)
+		}
+		name = strings.TrimSpace(name)
+		if !known[name] {
+			return nil, fmt.Errorf("unknown validation rule %q (known: %s)", name, knownRuleNames())
+		}
+		m, err := ParseMode(mode)
+		if err != nil {
+			return nil, err
+		}
+		modes[name] = m
+	}
+	return modes, nil
+}
+
+func ParseMode(s string) (Mode, error) {
+	switch m := Mode(strings.ToLower(strings.TrimSpace(s))); m {
+	case ModeStrict, ModeWarn, ModeOff:
+		return m, nil
+	default:
+		return "", fmt.Errorf("invalid validation mode %q, want strict, warn or off", s)
+	}
+}
+
+func knownRuleNames() string {
+	names := make([]string, len(Rules))
+	for i, r := range Rules {
+		names[i] = r.Name
+	}
+	sort.Strings(names)
+	return strings.Join(names, ", ")
+}
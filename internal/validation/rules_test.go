@@ -0,0 +1,201 @@
+package validation
+
+import (
+	"order-service/internal/db"
+	"testing"
+	"time"
+)
+
+// validOrder returns the order of model.json, which passes every check.
+func validOrder() *db.Order {
+	return &db.Order{
+		OrderUID:    "b563feb7b2b84b6test",
+		TrackNumber: "WBILMTESTTRACK",
+		Entry:       "WBIL",
+		Delivery: db.Delivery{
+			Name:    "Test Testov",
+			Phone:   "+9720000000",
+			Zip:     "2639809",
+			City:    "Kiryat Mozkin",
+			Address: "Ploshad Mira 15",
+			Region:  "Kraiot",
+			Email:   "test@gmail.com",
+		},
+		Payment: db.Payment{
+			Transaction:  "b563feb7b2b84b6test",
+			Currency:     "USD",
+			Provider:     "wbpay",
+			Amount:       1817,
+			PaymentDt:    1637907727,
+			Bank:         "alpha",
+			DeliveryCost: 1500,
+			GoodsTotal:   317,
+		},
+		Items: []db.Item{{
+			ChrtID:      9934930,
+			TrackNumber: "WBILMTESTTRACK",
+			Price:       453,
+			Rid:         "ab4219087a764ae0btest",
+			Name:        "Mascaras",
+			Sale:        30,
+			Size:        "0",
+			TotalPrice:  317,
+			NmID:        2389212,
+			Brand:       "Vivienne Sabo",
+			Status:      202,
+		}},
+		Locale:          "en",
+		CustomerID:      "test",
+		DeliveryService: "meest",
+		Shardkey:        "9",
+		SmID:            99,
+		DateCreated:     time.Date(2021, 11, 26, 6, 22, 19, 0, time.UTC),
+		OofShard:        "1",
+	}
+}
+
+func violationPaths(violations []Violation) []string {
+	paths := make([]string, len(violations))
+	for i, v := range violations {
+		paths[i] = v.Path
+	}
+	return paths
+}
+
+func TestRules(t *testing.T) {
+	tests := []struct {
+		name   string
+		check  func(*db.Order) []Violation
+		modify func(*db.Order)
+		want   []string
+	}{
+		{"item total valid", checkItemTotals, func(o *db.Order) {}, nil},
+		{"item total without sale", checkItemTotals, func(o *db.Order) {
+			o.Items[0].Sale = 0
+			o.Items[0].TotalPrice = 453
+		}, nil},
+		{"item total truncated", checkItemTotals, func(o *db.Order) {
+			o.Items[0].Price = 99
+			o.Items[0].Sale = 50
+			o.Items[0].TotalPrice = 49
+		}, nil},
+		{"item total wrong", checkItemTotals, func(o *db.Order) {
+			o.Items[0].TotalPrice = 453
+		}, []string{"items[0].total_price"}},
+		{"item total wrong on second item", checkItemTotals, func(o *db.Order) {
+			bad := o.Items[0]
+			bad.TotalPrice = 1
+			o.Items = append(o.Items, bad)
+		}, []string{"items[1].total_price"}},
+
+		{"goods total valid", checkGoodsTotal, func(o *db.Order) {}, nil},
+		{"goods total sums items", checkGoodsTotal, func(o *db.Order) {
+			o.Items = append(o.Items, o.Items[0])
+			o.Payment.GoodsTotal = 634
+		}, nil},
+		{"goods total wrong", checkGoodsTotal, func(o *db.Order) {
+			o.Payment.GoodsTotal = 1232
+		}, []string{"payment.goods_total"}},
+		{"goods total of no items", checkGoodsTotal, func(o *db.Order) {
+			o.Items = nil
+			o.Payment.GoodsTotal = 0
+		}, nil},
+
+		{"amount valid", checkPaymentAmount, func(o *db.Order) {}, nil},
+		{"amount with custom fee", checkPaymentAmount, func(o *db.Order) {
+			o.Payment.CustomFee = 100
+			o.Payment.Amount = 1917
+		}, nil},
+		{"amount wrong", checkPaymentAmount, func(o *db.Order) {
+			o.Payment.Amount = 9
+		}, []string{"payment.amount"}},
+		{"amount ignores custom fee", checkPaymentAmount, func(o *db.Order) {
+			o.Payment.CustomFee = 100
+		}, []string{"payment.amount"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			o := validOrder()
+			tt.modify(o)
+			got := violationPaths(tt.check(o))
+			if len(got) != len(tt.want) {
+				t.Fatalf("violations = %v, want %v", got, tt.want)
+			}
+			for i := range got {
+				if got[i] != tt.want[i] {
+					t.Errorf("violations = %v, want %v", got, tt.want)
+				}
+			}
+		})
+	}
+}
+
+func TestRuleEngineModes(t *testing.T) {
+	o := validOrder()
+	o.Payment.GoodsTotal = 1232 // also breaks payment_amount
+
+	tests := []struct {
+		name         string
+		modes        map[string]Mode
+		defaultMode  Mode
+		wantErrors   int
+		wantWarnings int
+	}{
+		{"strict by default", nil, ModeStrict, 2, 0},
+		{"warn by default", nil, ModeWarn, 0, 2},
+		{"off by default", nil, ModeOff, 0, 0},
+		{"one rule relaxed", map[string]Mode{"payment_amount": ModeWarn}, ModeStrict, 1, 1},
+		{"one rule off", map[string]Mode{"goods_total": ModeOff}, ModeStrict, 1, 0},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			report := NewRuleEngine(Rules, tt.modes, tt.defaultMode).Check(o)
+			if len(report.Errors) != tt.wantErrors || len(report.Warnings) != tt.wantWarnings {
+				t.Errorf("errors %v, warnings %v; want %d and %d",
+					report.Errors, report.Warnings, tt.wantErrors, tt.wantWarnings)
+			}
+			for _, v := range append(report.Errors, report.Warnings...) {
+				if v.Rule == "" {
+					t.Errorf("violation %v has no rule name", v)
+				}
+			}
+		})
+	}
+}
+
+func TestParseRuleModes(t *testing.T) {
+	tests := []struct {
+		in      string
+		want    map[string]Mode
+		wantErr bool
+	}{
+		{"", map[string]Mode{}, false},
+		{"goods_total=warn", map[string]Mode{"goods_total": ModeWarn}, false},
+		{" goods_total = OFF , payment_amount=strict ", map[string]Mode{"goods_total": ModeOff, "payment_amount": ModeStrict}, false},
+		{"goods_total", nil, true},
+		{"unknown=warn", nil, true},
+		{"goods_total=loud", nil, true},
+	}
+	for _, tt := range tests {
+		got, err := ParseRuleModes(tt.in)
+		if (err != nil) != tt.wantErr {
+			t.Errorf("ParseRuleModes(%q) error = %v, want error %t", tt.in, err, tt.wantErr)
+			continue
+		}
+		if len(got) != len(tt.want) {
+			t.Errorf("ParseRuleModes(%q) = %v, want %v", tt.in, got, tt.want)
+			continue
+		}
+		for k, v := range tt.want {
+			if got[k] != v {
+				t.Errorf("ParseRuleModes(%q) = %v, want %v", tt.in, got, tt.want)
+			}
+		}
+	}
+}
+
+func TestValidateOrderAcceptsModelJSON(t *testing.T) {
+	if err := ValidateOrder(validOrder()); err != nil {
+		t.Fatalf("ValidateOrder(model.json) = %v", err)
+	}
+}
@@ -1,13 +1,182 @@
 package validation
 
 import (
+	"errors"
+	"fmt"
+	"log"
 	"order-service/internal/db"
+	"reflect"
+	"strings"
 
 	"github.com/go-playground/validator"
 )
 
-var validate = validator.New()
+var validate = newValidator()
+
+var engine = NewRuleEngine(Rules, nil, ModeStrict)
+
+func newValidator() *validator.Validate {
+	v := validator.New()
+	v.RegisterTagNameFunc(func(fld reflect.StructField) string {
+		name := strings.SplitN(fld.Tag.Get("json"), ",", 2)[0]
+		if name == "-" {
+			return ""
+		}
+		return name
+	})
+	return v
+}
+
+func SetRuleEngine(e *RuleEngine) {
+	engine = e
+}
+
+// ValidationError carries every violation found in an order, both from the
+// struct tags and from the business rules, in a form that can be sent back to
+// producers as JSON.
+type ValidationError struct {
+	Violations []Violation `json:"violations"`
+	Warnings   []Violation `json:"warnings,omitempty"`
+}
+
+func (e *ValidationError) Error() string {
+	msgs := make([]string, len(e.Violations))
+	for i, v := range e.Violations {
+		msgs[i] = v.String()
+	}
+	return fmt.Sprintf("order has %d validation errors: %s", len(e.Violations), strings.Join(msgs, "; "))
+}
+
+// AsValidationError returns err as a *ValidationError, wrapping any other
+// error into a single violation of the given rule.
+func AsValidationError(err error, rule string) *ValidationError {
+	var verr *ValidationError
+	if errors.As(err, &verr) {
+		return verr
+	}
+	return &ValidationError{Violations: []Violation{{Rule: rule, Message: err.Error()}}}
+}
 
 func ValidateOrder(order *db.Order) error {
-	return validate.Struct(order)
+	var result ValidationError
+	profile := profiles.Load().Select(order)
+
+	if err := validate.Struct(order); err != nil {
+		var fieldErrs validator.ValidationErrors
+		if !errors.As(err, &fieldErrs) {
+			return err
+		}
+		for _, fe := range fieldErrs {
+			if v := fieldViolation(fe); !profile.Relaxes(v) {
+				result.Violations = append(result.Violations, v)
+			}
+		}
+	}
+
+	report := engine.Check(order)
+	result.Violations = append(result.Violations, report.Errors...)
+	if profile != nil {
+		result.Violations = append(result.Violations, profile.Check(order)...)
+	}
+	result.Warnings = report.Warnings
+	for _, v := range report.Warnings {
+		log.Printf("Validation warning for order %s: %s", order.OrderUID, v)
+	}
+
+	if len(result.Violations) > 0 {
+		return &result
+	}
+	return nil
+}
+
+// ValidateDeliveryPatch normalizes the phone of a delivery patch and checks
+// every field it sets against the tags of db.Delivery.
+func ValidateDeliveryPatch(patch *db.DeliveryPatch) error {
+	if patch.Phone != nil {
+		phone := NormalizePhone(*patch.Phone, phoneRegion)
+		patch.Phone = &phone
+	}
+
+	var result ValidationError
+	pv := reflect.ValueOf(patch).Elem()
+	dt := reflect.TypeOf(db.Delivery{})
+	for i := 0; i < dt.NumField(); i++ {
+		f := dt.Field(i)
+		value := pv.FieldByName(f.Name)
+		if !value.IsValid() || value.IsNil() {
+			continue
+		}
+		err := validate.Var(value.Elem().Interface(), f.Tag.Get("validate"))
+		var fieldErrs validator.ValidationErrors
+		if !errors.As(err, &fieldErrs) {
+			continue
+		}
+		name := strings.SplitN(f.Tag.Get("json"), ",", 2)[0]
+		for _, fe := range fieldErrs {
+			result.Violations = append(result.Violations, Violation{
+				Path:    "delivery." + name,
+				Rule:    fe.Tag(),
+				Value:   fe.Value(),
+				Message: tagMessage(fe),
+			})
+		}
+	}
+
+	if len(result.Violations) > 0 {
+		return &result
+	}
+	return nil
+}
+
+func fieldViolation(fe validator.FieldError) Violation {
+	path := fe.Namespace()
+	if i := strings.Index(path, "."); i >= 0 {
+		path = path[i+1:]
+	}
+	return Violation{
+		Path:    path,
+		Rule:    fe.Tag(),
+		Value:   fe.Value(),
+		Message: tagMessage(fe),
+	}
+}
+
+func tagMessage(fe validator.FieldError) string {
+	unit := ""
+	switch fe.Kind() {
+	case reflect.String:
+		unit = " characters"
+	case reflect.Slice, reflect.Map, reflect.Array:
+		unit = " elements"
+	}
+
+	switch fe.Tag() {
+	case "required":
+		return "is required"
+	case "min":
+		return fmt.Sprintf("must be at least %s%s", fe.Param(), unit)
+	case "max":
+		return fmt.Sprintf("must be at most %s%s", fe.Param(), unit)
+	case "oneof":
+		return fmt.Sprintf("must be one of: %s", fe.Param())
+	case "alpha":
+		return "must contain only letters"
+	case "alphanum":
+		return "must contain only letters and digits"
+	case "numeric":
+		return "must be numeric"
+	case "email":
+		return "must be a valid email address"
+	case "iso4217":
+		return "must be an ISO 4217 currency code"
+	case "e164":
+		return "must be an E.164 phone number such as +79161234567"
+	case "bcp47":
+		return "must be a BCP 47 locale from the allowed list"
+	default:
+		if fe.Param() != "" {
+			return fmt.Sprintf("failed %s=%s validation", fe.Tag(), fe.Param())
+		}
+		return fmt.Sprintf("failed %s validation", fe.Tag())
+	}
 }
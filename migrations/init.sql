@@ -64,4 +64,89 @@ CREATE TABLE IF NOT EXISTS items (
     nm_id BIGINT NOT NULL,
     brand VARCHAR(255) NOT NULL,
     status INTEGER NOT NULL
-);
\ No newline at end of file
+);
+
+CREATE TABLE IF NOT EXISTS outbox (
+    id BIGSERIAL PRIMARY KEY,
+    order_uid VARCHAR(255) NOT NULL,
+    event_type VARCHAR(50) NOT NULL,
+    payload JSONB NOT NULL,
+    created_at TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT NOW(),
+    published_at TIMESTAMP WITH TIME ZONE
+);
+
+CREATE INDEX IF NOT EXISTS idx_outbox_unpublished ON outbox (id) WHERE published_at IS NULL;
+CREATE INDEX IF NOT EXISTS idx_outbox_published_at ON outbox (published_at) WHERE published_at IS NOT NULL;
+CREATE INDEX IF NOT EXISTS idx_outbox_order_unpublished ON outbox (order_uid, id) WHERE published_at IS NULL;
+
+ALTER TABLE orders ADD COLUMN IF NOT EXISTS deleted_at TIMESTAMP WITH TIME ZONE;
+
+CREATE INDEX IF NOT EXISTS idx_orders_date_created ON orders (date_created);
+
+CREATE TABLE IF NOT EXISTS orders_archive (LIKE orders);
+ALTER TABLE orders_archive ADD COLUMN IF NOT EXISTS archived_at TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT NOW();
+
+CREATE TABLE IF NOT EXISTS deliveries_archive (LIKE deliveries);
+CREATE TABLE IF NOT EXISTS payments_archive (LIKE payments);
+CREATE TABLE IF NOT EXISTS items_archive (LIKE items);
+
+ALTER TABLE orders ADD COLUMN IF NOT EXISTS status VARCHAR(20) NOT NULL DEFAULT 'created';
+ALTER TABLE orders_archive ADD COLUMN IF NOT EXISTS status VARCHAR(20) NOT NULL DEFAULT 'created';
+
+CREATE TABLE IF NOT EXISTS order_status_history (
+    id BIGSERIAL PRIMARY KEY,
+    order_uid VARCHAR(255) NOT NULL REFERENCES orders(order_uid) ON DELETE CASCADE,
+    from_status VARCHAR(20),
+    to_status VARCHAR(20) NOT NULL,
+    reason TEXT NOT NULL DEFAULT '',
+    changed_at TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT NOW()
+);
+
+CREATE INDEX IF NOT EXISTS idx_order_status_history_order ON order_status_history (order_uid, id);
+
+-- Orders stored before statuses existed have no history. Give them the status
+-- db.DeriveStatus would have (the least advanced active item, else returned,
+-- else cancelled, else created) and record it as their first status.
+WITH derived AS (
+    SELECT o.order_uid,
+        CASE
+            WHEN min(r.rank) IS NOT NULL THEN (ARRAY['created', 'paid', 'assembling', 'shipped', 'delivered'])[min(r.rank)]
+            WHEN bool_or(i.status BETWEEN 600 AND 699) THEN 'returned'
+            WHEN bool_or(i.status BETWEEN 500 AND 599) THEN 'cancelled'
+            ELSE 'created'
+        END AS status
+    FROM orders o
+    LEFT JOIN items i ON i.order_uid = o.order_uid
+    CROSS JOIN LATERAL (SELECT CASE
+        WHEN i.status BETWEEN 100 AND 199 THEN 1
+        WHEN i.status = 200 THEN 2
+        WHEN i.status BETWEEN 201 AND 299 THEN 3
+        WHEN i.status BETWEEN 300 AND 399 THEN 4
+        WHEN i.status BETWEEN 400 AND 499 THEN 5
+    END AS rank) r
+    WHERE NOT EXISTS (SELECT 1 FROM order_status_history h WHERE h.order_uid = o.order_uid)
+    GROUP BY o.order_uid
+), backfilled AS (
+    UPDATE orders o SET status = d.status
+    FROM derived d
+    WHERE o.order_uid = d.order_uid
+    RETURNING o.order_uid, o.status
+)
+INSERT INTO order_status_history (order_uid, to_status, reason)
+SELECT order_uid, status, 'backfilled' FROM backfilled;
+
+CREATE TABLE IF NOT EXISTS order_status_history_archive (LIKE order_status_history);
+
+-- Keyset pagination for order listings: newest first, ties broken by uid.
+-- Uids sort bytewise whatever the database collation, as the shard router
+-- merges pages in Go.
+CREATE INDEX IF NOT EXISTS idx_orders_listing ON orders (date_created DESC, order_uid COLLATE "C" DESC) WHERE deleted_at IS NULL;
+
+-- Customer order history: page through and aggregate one customer's orders
+-- without touching other rows; items are joined back by order_uid.
+CREATE INDEX IF NOT EXISTS idx_orders_customer ON orders (customer_id, date_created DESC, order_uid COLLATE "C" DESC) WHERE deleted_at IS NULL;
+CREATE INDEX IF NOT EXISTS idx_items_order_uid ON items (order_uid, brand);
+
+-- Order watchers subscribe by track number and need a snapshot of the orders
+-- shipped under it.
+CREATE INDEX IF NOT EXISTS idx_orders_track_number ON orders (track_number) WHERE deleted_at IS NULL;
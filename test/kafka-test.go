@@ -27,7 +27,7 @@ func Test() {
 		Entry:       "WBIL",
 		Delivery: db.Delivery{
 			Name:    "YURY",
-			Phone:   "8033412412",
+			Phone:   "+375291234567",
 			Zip:     "220001",
 			City:    "Toronto",
 			Address: "test_adress",
@@ -37,13 +37,13 @@ func Test() {
 		Payment: db.Payment{
 			Transaction:  "b2kbm2kn2kbn2k",
 			RequestID:    "",
-			Currency:     "EURO",
+			Currency:     "EUR",
 			Provider:     "wbpay",
-			Amount:       9,
+			Amount:       19658,
 			PaymentDt:    813812,
 			Bank:         "PRIORbank",
 			DeliveryCost: 12,
-			GoodsTotal:   1232,
+			GoodsTotal:   317,
 			CustomFee:    19329,
 		},
 		Items: []db.Item{